@@ -1,70 +1,44 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"crypto/ed25519"
-	"crypto/sha256"
-	"encoding/json"
+	"encoding/hex"
+	"flag"
 	"fmt"
-	"net/http"
 
-	b64 "encoding/base64"
-
-	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/client"
 )
 
 func main() {
-	publ, priv, _ := ed25519.GenerateKey((nil))
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", "http://localhost:3333/signIn", nil)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	resp, err := client.Do(req)
+	// Must match the server's -sign-mode.
+	signMode := flag.String("sign-mode", string(challenge.DefaultMode), "what to sign: sha256, ed25519 or ed25519ph")
+	server := flag.String("server", "http://localhost:3333", "base URL of the server")
+	identity := flag.String("identity", "", "identity to enroll the key under before signing in (default device-<key prefix>)")
+	timeout := flag.Duration("timeout", client.DefaultTimeout, "give up signing in after this long")
+	flag.Parse()
+	mode, err := challenge.ParseMode(*signMode)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
-	defer resp.Body.Close()
-
-	challenge := dto.Challenge{}
-	err = json.NewDecoder(resp.Body).Decode(&challenge)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	fmt.Println(challenge.Message)
-	digest := sha256.Sum256([]byte(challenge.Message))
-	signature := ed25519.Sign(priv, digest[:])
-
-	pk := b64.StdEncoding.EncodeToString(publ)
-	sig := b64.StdEncoding.EncodeToString(signature)
-	challengeResponse := dto.ChallengeResponse{
-		Signature: sig,
-		Message:   challenge.Message,
-		PublicKey: pk,
+	publ, priv, _ := ed25519.GenerateKey((nil))
+	c := client.New(*server)
+	c.Mode = mode
+	c.Timeout = *timeout
+	if *identity == "" {
+		*identity = "device-" + hex.EncodeToString(publ[:4])
 	}
-
-	challengeResponseJson, err := json.Marshal(challengeResponse)
+	err = c.Enroll(context.Background(), *identity, priv, publ)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Println("error enrolling:", err)
 		return
 	}
-
-	req2, _ := http.NewRequest("POST", "http://localhost:3333/signIn", bytes.NewBuffer(challengeResponseJson))
-	req2.Header.Set("Content-Type", "application/json")
-	req2.Header.Set("Accept", "application/json")
-
-	resp2, err := client.Do(req2)
+	_, err = c.SignIn(context.Background(), priv, publ)
 	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	defer resp2.Body.Close()
-	if resp2.StatusCode != http.StatusOK {
-		fmt.Println("error signing in")
+		fmt.Println("error signing in:", err)
 		return
 	}
 
@@ -0,0 +1,97 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/audit"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// memoryAuditLog keeps events in memory for tests.
+type memoryAuditLog struct {
+	mu     sync.Mutex
+	events []audit.AuthEvent
+}
+
+func (l *memoryAuditLog) LogSignIn(event audit.AuthEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+// take returns the events logged so far and forgets them.
+func (l *memoryAuditLog) take() []audit.AuthEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events
+	l.events = nil
+	return events
+}
+
+func TestAuditLog(t *testing.T) {
+	a := newTestApp(t)
+	a.requireEnrollment = true
+	log := &memoryAuditLog{}
+	a.audit = log
+	pub, priv := testutil.DeterministicEd25519(1)
+	publicKey := b64.StdEncoding.EncodeToString(pub)
+	err := a.enrollment.Enroll("alice", pub)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		response     func() *http.Response
+		wantResult   string
+		wantIdentity string
+	}{
+		{"Test successful sign in", func() *http.Response {
+			return postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		}, audit.ResultSuccess, "alice"},
+		{"Test failed sign in", func() *http.Response {
+			response := signChallengeWithKey(t, getChallenge(t, a).Message, priv)
+			response.Message = getChallenge(t, a).Message
+			return postSignIn(t, a, response)
+		}, "invalid_signature", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now()
+			res := tt.response()
+			res.Body.Close()
+
+			events := log.take()
+			if len(events) != 1 {
+				t.Fatalf("expected 1 event got %d", len(events))
+			}
+			event := events[0]
+			if event.Result != tt.wantResult {
+				t.Errorf("expected result to be %s got %s", tt.wantResult, event.Result)
+			}
+			if event.PublicKey != publicKey {
+				t.Errorf("expected public key to be %s got %s", publicKey, event.PublicKey)
+			}
+			if event.Identity != tt.wantIdentity {
+				t.Errorf("expected identity to be %q got %q", tt.wantIdentity, event.Identity)
+			}
+			if event.ClientIP != "192.0.2.1" {
+				t.Errorf("expected client IP to be 192.0.2.1 got %s", event.ClientIP)
+			}
+			if event.Time.Before(before) || event.Time.After(time.Now()) {
+				t.Errorf("expected time to be when the request was served got %s", event.Time)
+			}
+		})
+	}
+
+	t.Run("Test challenges are not audited", func(t *testing.T) {
+		getChallenge(t, a)
+		if events := log.take(); len(events) != 0 {
+			t.Errorf("expected no events got %d", len(events))
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// authorize guards a handler with the server's tokens: requests need a
+// bearer token, or a session cookie, signed by a trusted key that is not expired (401 otherwise)
+// and that carries every one of requiredScopes (403 otherwise). The token's
+// claims are passed on in the request context; see claimsFromContext.
+func (a *app) authorize(requiredScopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := requestToken(r)
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "missing_token", "missing bearer token")
+				return
+			}
+			claims, err := a.verifyToken(token)
+			if errors.Is(err, jws.ErrTokenExpired) {
+				writeError(w, http.StatusUnauthorized, "token_expired", "token is expired")
+				return
+			} else if err != nil {
+				writeError(w, http.StatusUnauthorized, "invalid_token", "token does not verify")
+				return
+			}
+			for _, scope := range requiredScopes {
+				if !claims.HasScope(scope) {
+					writeError(w, http.StatusForbidden, "insufficient_scope", "token lacks scope "+scope)
+					return
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(withClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// loadKeyScopes reads a JSON object mapping base64 client public keys to the
+// space-delimited scopes granted to tokens minted for them. Without a path
+// no key gets any scope.
+func loadKeyScopes(path string) (map[string]string, error) {
+	scopes := map[string]string{}
+	if path == "" {
+		return scopes, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &scopes)
+	if err != nil {
+		return nil, err
+	}
+	return scopes, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestAuthorize(t *testing.T) {
+	a := newTestApp(t)
+	publ, priv := testutil.DeterministicEd25519(2)
+	a.keyScopes[b64.StdEncoding.EncodeToString(publ)] = "read write"
+
+	signInWithKey := func(t *testing.T, priv ed25519.PrivateKey) string {
+		t.Helper()
+		res := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		return decodeToken(t, res)
+	}
+	scopedToken := signInWithKey(t, priv)
+	_, otherPriv := testutil.DeterministicEd25519(3)
+	unscopedToken := signInWithKey(t, otherPriv)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := a.authorize("write")(next)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"Test token with sufficient scopes", scopedToken, http.StatusOK},
+		{"Test token missing a scope", unscopedToken, http.StatusForbidden},
+		{"Test unauthenticated request", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("expected status code to be %d got %d", tt.status, w.Code)
+			}
+		})
+	}
+
+	t.Run("Test minted token carries the key's scopes", func(t *testing.T) {
+		claims, _ := jws.Decode(scopedToken)
+		if claims.Scope != "read write" {
+			t.Errorf("expected scope to be read write got %s", claims.Scope)
+		}
+	})
+}
+
+func TestLoadKeyScopes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scopes.json")
+	os.WriteFile(path, []byte(`{"a2V5":"read"}`), 0o600)
+	scopes, err := loadKeyScopes(path)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if scopes["a2V5"] != "read" {
+		t.Errorf("expected scope to be read got %s", scopes["a2V5"])
+	}
+}
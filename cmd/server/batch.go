@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// maxBatchSize caps the responses in one POST /signIn/batch.
+const maxBatchSize = 32
+
+// batchLimits fits maxBatchSize challenge responses.
+var batchLimits = routeLimits{
+	MaxBodyBytes: maxBatchSize * signInLimits.MaxBodyBytes,
+	Timeout:      signInLimits.Timeout,
+}
+
+// signInBatch answers POST /signIn/batch: it verifies each challenge response
+// on its own, as POST /signIn does, and reports per item whether it passed.
+// It proves possession of the keys; it doesn't mint tokens.
+func (a *app) signInBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.BatchChallengeResponse{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling batch")
+		return
+	}
+	if len(body.Responses) == 0 || len(body.Responses) > maxBatchSize {
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("a batch needs 1 to %d responses", maxBatchSize))
+		return
+	}
+
+	session, sErr := a.postedSession(r)
+	if sErr != nil {
+		writeError(w, sErr.status, sErr.code, sErr.message)
+		return
+	}
+
+	batch := dto.BatchSignIn{AllOk: true}
+	for i, response := range body.Responses {
+		result := dto.BatchResult{Index: i, Ok: true}
+		_, sErr := a.verifyChallengeResponse(response, session)
+		if sErr != nil {
+			result.Ok, result.Error = false, a.disclosed(sErr).code
+			batch.AllOk = false
+		}
+		batch.Results = append(batch.Results, result)
+	}
+
+	res, err := json.Marshal(batch)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling batch results")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
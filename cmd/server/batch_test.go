@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+func postBatch(t *testing.T, a *app, responses []dto.ChallengeResponse) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(dto.BatchChallengeResponse{Responses: responses})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/signIn/batch", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	a.signInBatch(w, req)
+	return w.Result()
+}
+
+func TestSignInBatch(t *testing.T) {
+	a := newTestApp(t)
+	a.detailedErrors = true
+
+	t.Run("Test mixed batch", func(t *testing.T) {
+		badSig := signChallenge(t, getChallenge(t, a).Message)
+		badSig.Signature = b64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+		responses := []dto.ChallengeResponse{
+			signChallenge(t, getChallenge(t, a).Message),
+			signChallenge(t, "not issued by the server"),
+			badSig,
+			signChallenge(t, getChallenge(t, a).Message),
+		}
+		want := []dto.BatchResult{
+			{Index: 0, Ok: true},
+			{Index: 1, Ok: false, Error: "invalid_challenge"},
+			{Index: 2, Ok: false, Error: "invalid_signature"},
+			{Index: 3, Ok: true},
+		}
+
+		res := postBatch(t, a, responses)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		batch := dto.BatchSignIn{}
+		json.NewDecoder(res.Body).Decode(&batch)
+		if batch.AllOk {
+			t.Errorf("expected allOk to be false")
+		}
+		if len(batch.Results) != len(want) {
+			t.Fatalf("expected %d results got %d", len(want), len(batch.Results))
+		}
+		for i, result := range batch.Results {
+			if result != want[i] {
+				t.Errorf("expected result %d to be %+v got %+v", i, want[i], result)
+			}
+		}
+	})
+
+	t.Run("Test all valid", func(t *testing.T) {
+		res := postBatch(t, a, []dto.ChallengeResponse{
+			signChallenge(t, getChallenge(t, a).Message),
+			signChallenge(t, getChallenge(t, a).Message),
+		})
+		defer res.Body.Close()
+		batch := dto.BatchSignIn{}
+		json.NewDecoder(res.Body).Decode(&batch)
+		if !batch.AllOk {
+			t.Errorf("expected allOk to be true got %+v", batch.Results)
+		}
+	})
+
+	t.Run("Test empty batch", func(t *testing.T) {
+		res := postBatch(t, a, nil)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "invalid_request" {
+			t.Errorf("expected error code to be invalid_request got %s", code)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+)
+
+// maxChallengeCount caps the challenges one GET /challenges issues.
+const maxChallengeCount = 1000
+
+// challengesLimits sets no timeout on GET /challenges: http.TimeoutHandler
+// buffers the whole response, which would defeat streaming it. The count
+// cap bounds the work instead.
+var challengesLimits = routeLimits{}
+
+// streamChallenges answers GET /challenges?count=N for provisioning tools that
+// need many challenges: it issues N, each stored with its TTL as GET
+// /signIn stores one, and streams them as newline-delimited JSON, flushing
+// each line so the client can start answering before the last is issued. A
+// failure once streaming has begun ends the stream early. Each challenge
+// takes a slot in the store, so a caller could crowd out everyone else's;
+// it must be wrapped in authorize(adminScope).
+func (a *app) streamChallenges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 1 || count > maxChallengeCount {
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("count must be between 1 and %d", maxChallengeCount))
+		return
+	}
+	session, sErr := a.challengeSession(w, r)
+	if sErr != nil {
+		writeError(w, sErr.status, sErr.code, sErr.message)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	for i := 0; i < count && r.Context().Err() == nil; i++ {
+		ch, sErr := a.issueChallenge(challenge.Binding{Session: session}, "")
+		if sErr != nil {
+			if i == 0 {
+				writeError(w, sErr.status, sErr.code, sErr.message)
+			}
+			return
+		}
+		if i == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+		}
+		if enc.Encode(ch) != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func TestStreamChallenges(t *testing.T) {
+	a := newTestApp(t)
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+	adminToken, err := a.mint(context.Background(), jws.NewClaimSet().Subject("provisioner").Scope(adminScope).TTL(a.tokenTTL))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	get := func(query, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/challenges?"+query, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Test unauthenticated request is refused", func(t *testing.T) {
+		w := get("count=5", "")
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", w.Code)
+		}
+		userToken := signInToken(t, a)
+		if w := get("count=5", userToken); w.Code != http.StatusForbidden {
+			t.Errorf("expected status code to be 403 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test each line is a challenge that verifies", func(t *testing.T) {
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/challenges?count=5", nil)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("expected content type to be application/x-ndjson got %s", ct)
+		}
+
+		var messages []string
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			ch := dto.Challenge{}
+			err := json.Unmarshal(scanner.Bytes(), &ch)
+			if err != nil {
+				t.Fatalf("expected line %d to parse got %v", len(messages), err)
+			}
+			if ch.Message == "" || ch.ExpiresAt == 0 {
+				t.Errorf("expected a message and expiry got %+v", ch)
+			}
+			messages = append(messages, ch.Message)
+		}
+		if len(messages) != 5 {
+			t.Fatalf("expected 5 challenges got %d", len(messages))
+		}
+		for i, message := range messages {
+			signIn := postSignIn(t, a, signChallenge(t, message))
+			signIn.Body.Close()
+			if signIn.StatusCode != http.StatusOK {
+				t.Errorf("expected challenge %d to verify got %d", i, signIn.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Test lines are flushed", func(t *testing.T) {
+		w := get("count=2", adminToken)
+		if !w.Flushed {
+			t.Errorf("expected the stream to be flushed")
+		}
+		if lines := strings.Count(w.Body.String(), "\n"); lines != 2 {
+			t.Errorf("expected 2 lines got %d", lines)
+		}
+	})
+
+	t.Run("Test count is capped", func(t *testing.T) {
+		for _, count := range []string{"", "0", "-1", "many", fmt.Sprint(maxChallengeCount + 1)} {
+			w := get("count="+count, adminToken)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected count %q to get 400 got %d", count, w.Code)
+			}
+		}
+		w := get(fmt.Sprintf("count=%d", maxChallengeCount), adminToken)
+		if lines := strings.Count(w.Body.String(), "\n"); w.Code != http.StatusOK || lines != maxChallengeCount {
+			t.Errorf("expected %d challenges got %d lines, status %d", maxChallengeCount, lines, w.Code)
+		}
+	})
+}
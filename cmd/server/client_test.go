@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/client"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestClientSignIn(t *testing.T) {
+	a := newTestApp(t)
+	srv := httptest.NewServer(newServer(Config{}, a, newReadiness(a.signingProbe)).Handler)
+	defer srv.Close()
+
+	t.Run("Test client signs in end to end", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(4)
+		token, err := client.New(srv.URL).SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = jws.Validate(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test client with mismatched mode is rejected", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(5)
+		c := client.New(srv.URL)
+		c.Mode = challenge.ModeRaw
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+	t.Run("Test encrypted challenge is refused when disabled", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(6)
+		c := client.New(srv.URL)
+		c.EncryptChallenge = true
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test client signs in with an encrypted challenge", func(t *testing.T) {
+		a.encryptChallenges = true
+		defer func() { a.encryptChallenges = false }()
+		pub, priv := testutil.DeterministicEd25519(7)
+		c := client.New(srv.URL)
+		c.EncryptChallenge = true
+		token, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = jws.Validate(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+	t.Run("Test client signs in with a timestamp", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(8)
+		c := client.New(srv.URL)
+		c.Timestamp = true
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+	t.Run("Test client enrolls then signs in", func(t *testing.T) {
+		a.requireEnrollment = true
+		defer func() { a.requireEnrollment = false }()
+		pub, priv := testutil.DeterministicEd25519(9)
+		c := client.New(srv.URL)
+		err := c.Enroll(context.Background(), "dave", priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		token, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		claims, _ := jws.Decode(token)
+		if claims.Sub != "dave" {
+			t.Errorf("expected sub to be dave got %s", claims.Sub)
+		}
+	})
+	t.Run("Test client signs under the advertised digest", func(t *testing.T) {
+		a.digests = []challenge.Digest{challenge.DigestSHA512}
+		defer func() { a.digests = defaultDigests }()
+		pub, priv := testutil.DeterministicEd25519(10)
+		_, err := client.New(srv.URL).SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+	t.Run("Test client verifies the server signature", func(t *testing.T) {
+		a.signChallenges = true
+		defer func() { a.signChallenges = false }()
+		pub, priv := testutil.DeterministicEd25519(11)
+		c := client.New(srv.URL)
+		c.VerifyServer = true
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test client detects a tampered challenge", func(t *testing.T) {
+		a.signChallenges = true
+		defer func() { a.signChallenges = false }()
+		// A man in the middle swapping in a challenge of its own.
+		mitm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			srv.Config.Handler.ServeHTTP(rec, r)
+			if r.Method == http.MethodGet && r.URL.Path == "/signIn" {
+				ch := dto.Challenge{}
+				json.Unmarshal(rec.Body.Bytes(), &ch)
+				ch.Message = getChallenge(t, a).Message
+				json.NewEncoder(w).Encode(ch)
+				return
+			}
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes())
+		}))
+		defer mitm.Close()
+
+		pub, priv := testutil.DeterministicEd25519(12)
+		c := client.New(mitm.URL)
+		c.VerifyServer = true
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, client.ErrBadServerSignature) {
+			t.Errorf("expected error to be %v got %v", client.ErrBadServerSignature, err)
+		}
+	})
+
+	t.Run("Test client refuses an unsigned challenge", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(13)
+		c := client.New(srv.URL)
+		c.VerifyServer = true
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, client.ErrUnsignedChallenge) {
+			t.Errorf("expected error to be %v got %v", client.ErrUnsignedChallenge, err)
+		}
+	})
+}
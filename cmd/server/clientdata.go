@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// clientDataType is the type a sign-in assertion's client data must carry, as
+// in WebAuthn, so a signature made for another purpose can't be replayed here.
+const clientDataType = "webauthn.get"
+
+var (
+	// errInvalidClientData is returned for client data that isn't a JSON
+	// object of the expected type.
+	errInvalidClientData = errors.New("invalid client data")
+	// errOriginNotAllowed is returned for client data from an origin outside
+	// the allow-list.
+	errOriginNotAllowed = errors.New("client data origin is not allowed")
+)
+
+// parseClientData decodes raw and checks its type and that its origin is one
+// of allowedOrigins.
+func parseClientData(raw json.RawMessage, allowedOrigins []string) (dto.ClientData, error) {
+	cd := dto.ClientData{}
+	err := json.Unmarshal(raw, &cd)
+	if err != nil || cd.Type != clientDataType || cd.Challenge == "" {
+		return dto.ClientData{}, errInvalidClientData
+	}
+	for _, origin := range allowedOrigins {
+		if cd.Origin == origin {
+			return cd, nil
+		}
+	}
+	return dto.ClientData{}, errOriginNotAllowed
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// signClientData builds a client data assertion for message from origin and
+// signs it the way the default sign mode expects.
+func signClientData(t *testing.T, message, origin string) dto.ChallengeResponse {
+	t.Helper()
+	pub, priv := testutil.DeterministicEd25519(14)
+	raw, err := json.Marshal(dto.ClientData{Type: clientDataType, Origin: origin, Challenge: message})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	digest := sha256.Sum256(auth.ClientDataHash(raw))
+	return dto.ChallengeResponse{
+		Signature:  b64.StdEncoding.EncodeToString(ed25519.Sign(priv, digest[:])),
+		PublicKey:  b64.StdEncoding.EncodeToString(pub),
+		ClientData: raw,
+	}
+}
+
+func TestSignInClientData(t *testing.T) {
+	a := newTestApp(t)
+	a.clientDataOrigins = []string{"https://app.example.com"}
+
+	t.Run("Test valid client data assertion", func(t *testing.T) {
+		res := postSignIn(t, a, signClientData(t, getChallenge(t, a).Message, "https://app.example.com"))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test mismatched origin", func(t *testing.T) {
+		res := postSignIn(t, a, signClientData(t, getChallenge(t, a).Message, "https://evil.example.com"))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "origin_not_allowed" {
+			t.Errorf("expected error code to be origin_not_allowed got %s", code)
+		}
+	})
+
+	t.Run("Test message must match the embedded challenge", func(t *testing.T) {
+		challengeResponse := signClientData(t, getChallenge(t, a).Message, "https://app.example.com")
+		challengeResponse.Message = getChallenge(t, a).Message
+		res := postSignIn(t, a, challengeResponse)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "invalid_client_data" {
+			t.Errorf("expected error code to be invalid_client_data got %s", code)
+		}
+	})
+
+	t.Run("Test signature over the bare message is rejected", func(t *testing.T) {
+		message := getChallenge(t, a).Message
+		challengeResponse := signChallenge(t, message)
+		challengeResponse.ClientData, _ = json.Marshal(dto.ClientData{Type: clientDataType, Origin: "https://app.example.com", Challenge: message})
+		res := postSignIn(t, a, challengeResponse)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"crypto/subtle"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+)
+
+// constantTimeEqual reports whether a and b are equal in time that depends
+// only on their lengths. Comparing secrets or key material with == or
+// bytes.Equal returns at the first differing byte, which lets an attacker
+// probing with guesses learn how long a prefix they got right.
+func constantTimeEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
+
+// sameKey reports whether the base64 or base64url public key a challenge was
+// bound to is pk. It compares the decoded bytes, so two encodings of the same key
+// match, and does so in constant time.
+func sameKey(bound string, pk []byte) bool {
+	boundKey, err := auth.DecodeKeyMaterial(bound)
+	if err != nil {
+		return false
+	}
+	return constantTimeEqual(boundKey, pk)
+}
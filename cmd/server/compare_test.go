@@ -0,0 +1,36 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"testing"
+)
+
+func TestConstantTimeEqual(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  []byte
+		equal bool
+	}{
+		{"Test equal slices", []byte("public-key"), []byte("public-key"), true},
+		{"Test different slices", []byte("public-key"), []byte("public-kez"), false},
+		{"Test different lengths", []byte("public-key"), []byte("public"), false},
+		{"Test empty slices", []byte{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := constantTimeEqual(tt.a, tt.b); got != tt.equal {
+				t.Errorf("expected equal to be %v got %v", tt.equal, got)
+			}
+		})
+	}
+
+	t.Run("Test bound key compares decoded bytes", func(t *testing.T) {
+		pk := []byte("0123456789abcdef0123456789abcdef")
+		if !sameKey(b64.StdEncoding.EncodeToString(pk), pk) {
+			t.Errorf("expected bound key to match")
+		}
+		if sameKey("%%% not base64 %%%", pk) {
+			t.Errorf("expected undecodable bound key not to match")
+		}
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/introspect"
+)
+
+// defaultAddr is the listen address used when neither -addr nor SERVER_ADDR is set.
+const defaultAddr = ":3333"
+
+// Config is the server configuration resolved from flags, the -config file
+// and the environment.
+type Config struct {
+	Addr          string
+	Debug         bool
+	SigningKey    string
+	RefreshWindow time.Duration
+	TokenTTL      time.Duration
+	SignMode      challenge.Mode
+	CORSOrigins   []string
+	TLSCert       string
+	TLSKey        string
+	KeyScopes     string
+	Identities    string
+	// RequireEnrollment only signs in keys enrolled through /enroll.
+	RequireEnrollment bool
+	Challenge         challenge.ChallengeConfig
+	// EncryptChallenges allows clients to get challenges sealed to their
+	// X25519 key.
+	EncryptChallenges bool
+	// SignChallenges has the server sign challenges so clients can tell they
+	// came from it.
+	SignChallenges bool
+	// ClientDataOrigins are the origins client data assertions may name.
+	ClientDataOrigins []string
+	// Digests are the digests accepted in sha256 sign mode, the one clients
+	// are asked for first.
+	Digests []challenge.Digest
+	// TimestampWindow bounds how far a timestamped response may be from
+	// server time.
+	TimestampWindow time.Duration
+	// Store is where challenges live: "memory" or "redis", or "stateless"
+	// for challenges MACed with ChallengeSecret that live nowhere.
+	Store           string
+	RedisAddr       string
+	ChallengeSecret string
+	// SharedUsed marks consumed stateless challenges in Redis at RedisAddr,
+	// so a challenge answers once across instances.
+	SharedUsed bool
+	// ChallengeCap bounds the in-memory store. The redis and stateless
+	// stores ignore it.
+	ChallengeCap challenge.Cap
+	// Introspect sizes the cache of /introspect results.
+	Introspect introspect.Config
+	// Server timeouts, as in http.Server.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// TokenDelivery is how sign-in hands over tokens: "body" or "cookie".
+	TokenDelivery string
+	// Difficulty is the proof of work, in leading zero bits, every
+	// challenge response must carry. Zero disables it.
+	Difficulty int
+	// BindSession ties each challenge to a session cookie set on GET
+	// /signIn, which the response must be posted with.
+	BindSession bool
+	// EnrollCA is a PEM file of trust anchors. When set, /enroll takes
+	// certificates that chain to one of them instead of raw keys.
+	EnrollCA string
+	// AuditLog is the file sign-in attempts are appended to as JSON lines.
+	// Empty means no audit log.
+	AuditLog string
+	// MaxBodyBytes caps every JSON request body.
+	MaxBodyBytes int64
+	// GRPCAddr is where the gRPC Auth service listens. Empty means it isn't
+	// served.
+	GRPCAddr string
+	// H2C serves HTTP/2 over cleartext to clients that ask for it. Over TLS
+	// HTTP/2 is always negotiated.
+	H2C bool
+}
+
+// Default server timeouts. WriteTimeout must outlast the longest route
+// timeout in defaultLimits, or slow handlers lose their 503.
+const (
+	defaultReadHeaderTimeout = 5 * time.Second
+	defaultReadTimeout       = 15 * time.Second
+	defaultWriteTimeout      = 15 * time.Second
+	defaultIdleTimeout       = 60 * time.Second
+)
+
+// defaultDigests accepts SHA-512 but keeps asking for SHA-256, which every
+// existing client signs under.
+var defaultDigests = []challenge.Digest{challenge.DigestSHA256, challenge.DigestSHA512}
+
+// parseConfig resolves the configuration from args, the -config file they
+// name, if any, and getenv, in that order of precedence: an -addr flag takes
+// precedence over addr in the file, then SERVER_ADDR, then defaultAddr.
+func parseConfig(args []string, getenv func(string) string) (Config, error) {
+	cfg := Config{}
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", "", "YAML or JSON file of flag settings, keyed by flag name; flags given on the command line override it, and it overrides the environment (SERVER_ADDR, CHALLENGE_SECRET) for the settings it sets")
+	fs.StringVar(&cfg.Addr, "addr", defaultAddr, "address to listen on (overrides SERVER_ADDR)")
+	fs.BoolVar(&cfg.Debug, "debug", false, "enable debug endpoints and tell clients why their sign-in didn't verify")
+	fs.StringVar(&cfg.SigningKey, "signing-key", "", "PEM private key used to sign tokens (Ed25519 PKCS#8, or RSA PKCS#1/PKCS#8); reloaded on SIGHUP")
+	fs.DurationVar(&cfg.TokenTTL, "token-ttl", defaultTokenTTL, "how long minted tokens stay valid")
+	fs.DurationVar(&cfg.TimestampWindow, "timestamp-window", defaultTimestampWindow, "how far a signed response's timestamp may be from server time, either way")
+	fs.DurationVar(&cfg.RefreshWindow, "refresh-window", defaultRefreshWindow, "how close to expiry a token must be for /refresh to renew it")
+	signMode := fs.String("sign-mode", string(challenge.DefaultMode), "what clients sign: sha256, ed25519 or ed25519ph")
+	fs.Func("cors-origins", "comma-separated origins allowed to call the API from a browser", func(s string) error {
+		cfg.CORSOrigins = append(cfg.CORSOrigins, splitList(s)...)
+		return nil
+	})
+	fs.Func("client-data-origins", "comma-separated origins accepted in signed client data", func(s string) error {
+		cfg.ClientDataOrigins = append(cfg.ClientDataOrigins, splitList(s)...)
+		return nil
+	})
+	fs.Func("digests", "comma-separated digests accepted in sha256 sign mode, preferred first: SHA-256, SHA-512", func(s string) error {
+		for _, name := range splitList(s) {
+			d, err := challenge.ParseDigest(name)
+			if err != nil {
+				return err
+			}
+			cfg.Digests = append(cfg.Digests, d)
+		}
+		return nil
+	})
+	fs.StringVar(&cfg.TLSCert, "tls-cert", "", "PEM certificate to serve TLS with (requires -tls-key)")
+	fs.StringVar(&cfg.TLSKey, "tls-key", "", "PEM private key for -tls-cert")
+	fs.StringVar(&cfg.KeyScopes, "key-scopes", "", "JSON file mapping client public keys to the scopes their tokens get")
+	fs.BoolVar(&cfg.RequireEnrollment, "require-enrollment", true, "only sign in public keys enrolled through /enroll; false signs in any key as itself")
+	fs.StringVar(&cfg.Identities, "identities", "", "JSON file mapping identities to the device keys and threshold for /signIn/multi")
+	fs.IntVar(&cfg.Challenge.ByteLen, "challenge-bytes", challenge.DefaultChallengeConfig.ByteLen, "random bytes per challenge (at least 16)")
+	fs.StringVar(&cfg.Challenge.Encoding, "challenge-encoding", challenge.DefaultChallengeConfig.Encoding, "challenge encoding: hex or base64url")
+	fs.StringVar(&cfg.Store, "store", "memory", "challenge store: memory, redis to share challenges between instances, or stateless for MACed challenges kept nowhere")
+	fs.StringVar(&cfg.RedisAddr, "redis-addr", "localhost:6379", "Redis address for -store redis and -shared-used-challenges")
+	fs.BoolVar(&cfg.SharedUsed, "shared-used-challenges", false, "with -store stateless, mark consumed challenges in Redis at -redis-addr so instances reject each other's replays")
+	fs.StringVar(&cfg.ChallengeSecret, "challenge-secret", "", "secret of at least 32 bytes that -store stateless MACs challenges with (overrides CHALLENGE_SECRET)")
+	fs.IntVar(&cfg.ChallengeCap.Max, "max-challenges", challenge.DefaultCap.Max, "most unanswered challenges kept in memory; 0 means no limit")
+	fs.BoolVar(&cfg.ChallengeCap.Reject, "strict-challenge-cap", false, "answer 503 at -max-challenges instead of evicting the oldest challenge")
+	fs.DurationVar(&cfg.Introspect.TTL, "introspect-ttl", introspect.DefaultConfig.TTL, "how long /introspect caches an active result, at most until the token expires")
+	fs.DurationVar(&cfg.Introspect.NegativeTTL, "introspect-negative-ttl", introspect.DefaultConfig.NegativeTTL, "how long /introspect caches an inactive result")
+	fs.IntVar(&cfg.Introspect.MaxEntries, "introspect-max-entries", introspect.DefaultConfig.MaxEntries, "most /introspect results cached; the oldest is evicted past it")
+	fs.DurationVar(&cfg.ReadHeaderTimeout, "read-header-timeout", defaultReadHeaderTimeout, "how long a client may take to send request headers")
+	fs.DurationVar(&cfg.ReadTimeout, "read-timeout", defaultReadTimeout, "how long a client may take to send a whole request")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", defaultWriteTimeout, "how long writing a response may take")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", defaultIdleTimeout, "how long an idle keep-alive connection is kept open")
+	fs.StringVar(&cfg.TokenDelivery, "token-delivery", tokenDeliveryBody, "how sign-in returns tokens: body (JSON) or cookie (HttpOnly session cookie, 204)")
+	fs.IntVar(&cfg.Difficulty, "pow-difficulty", 0, fmt.Sprintf("leading zero bits of SHA-256(challenge || nonce) a response must prove, up to %d; 0 disables proof of work", challenge.MaxDifficulty))
+	fs.BoolVar(&cfg.BindSession, "bind-session", false, "set a challenge_session cookie on GET /signIn and only accept a challenge's response posted with it")
+	fs.StringVar(&cfg.EnrollCA, "enroll-ca", "", "PEM trust anchors; /enroll then requires an Ed25519 certificate chaining to one of them")
+	fs.StringVar(&cfg.AuditLog, "audit-log", "", "file to append a JSON line to for every sign-in attempt")
+	fs.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", defaultMaxBodyBytes, "largest JSON request body accepted, in bytes")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", "", "address to serve the gRPC Auth service on, alongside HTTP; empty disables it")
+	fs.BoolVar(&cfg.H2C, "h2c", false, "serve HTTP/2 over cleartext (prior knowledge or Upgrade) when not serving TLS")
+	fs.BoolVar(&cfg.SignChallenges, "sign-challenges", false, "sign challenges with the token signing key so clients can verify them against the JWKS")
+	fs.BoolVar(&cfg.EncryptChallenges, "encrypted-challenges", false, "seal challenges to clients that send an x25519PublicKey")
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	if *configFile != "" {
+		err = applyConfigFile(fs, *configFile)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	cfg.SignMode, err = challenge.ParseMode(*signMode)
+	if err != nil {
+		return Config{}, err
+	}
+	if len(cfg.Digests) == 0 {
+		cfg.Digests = defaultDigests
+	}
+	err = cfg.Challenge.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+	err = cfg.Introspect.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+	if cfg.TokenTTL <= 0 {
+		return Config{}, fmt.Errorf("token ttl must be positive, got %s", cfg.TokenTTL)
+	}
+	if cfg.TimestampWindow <= 0 {
+		return Config{}, fmt.Errorf("timestamp window must be positive, got %s", cfg.TimestampWindow)
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		return Config{}, fmt.Errorf("max body bytes must be positive, got %d", cfg.MaxBodyBytes)
+	}
+	if cfg.TokenDelivery != tokenDeliveryBody && cfg.TokenDelivery != tokenDeliveryCookie {
+		return Config{}, fmt.Errorf("unknown token delivery %q, want body or cookie", cfg.TokenDelivery)
+	}
+	if cfg.Difficulty < 0 || cfg.Difficulty > challenge.MaxDifficulty {
+		return Config{}, fmt.Errorf("pow difficulty must be between 0 and %d, got %d", challenge.MaxDifficulty, cfg.Difficulty)
+	}
+	// The gRPC messages have no field for the nonce.
+	if cfg.Difficulty > 0 && cfg.GRPCAddr != "" {
+		return Config{}, fmt.Errorf("-pow-difficulty can't be combined with -grpc-addr")
+	}
+	if cfg.ChallengeCap.Max < 0 {
+		return Config{}, fmt.Errorf("max challenges must not be negative, got %d", cfg.ChallengeCap.Max)
+	}
+	if cfg.Store != "memory" && cfg.Store != "redis" && cfg.Store != "stateless" {
+		return Config{}, fmt.Errorf("unknown store %q, want memory, redis or stateless", cfg.Store)
+	}
+	if !isFlagSet(fs, "challenge-secret") {
+		cfg.ChallengeSecret = getenv("CHALLENGE_SECRET")
+	}
+	if cfg.Store == "stateless" && len(cfg.ChallengeSecret) < challenge.MinSecretSize {
+		return Config{}, fmt.Errorf("-store stateless needs a -challenge-secret of at least %d bytes", challenge.MinSecretSize)
+	}
+	if cfg.SharedUsed && cfg.Store != "stateless" {
+		return Config{}, fmt.Errorf("-shared-used-challenges needs -store stateless")
+	}
+
+	if !isFlagSet(fs, "addr") {
+		if env := getenv("SERVER_ADDR"); env != "" {
+			cfg.Addr = env
+		}
+	}
+	return cfg, nil
+}
+
+// isFlagSet reports whether the named flag was given on the command line or
+// in the config file.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,635 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/client"
+	"github.com/martinsaporiti/ed25519-poc/internal/introspect"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+	"golang.org/x/net/http2"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		addr string
+	}{
+		{"Test default address", nil, nil, ":3333"},
+		{"Test address from env", nil, map[string]string{"SERVER_ADDR": "127.0.0.1:8080"}, "127.0.0.1:8080"},
+		{"Test address from flag", []string{"-addr", ":9090"}, nil, ":9090"},
+		{"Test flag takes precedence over env", []string{"-addr", ":9090"}, map[string]string{"SERVER_ADDR": ":8080"}, ":9090"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseConfig(tt.args, func(key string) string { return tt.env[key] })
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if cfg.Addr != tt.addr {
+				t.Errorf("expected addr to be %s got %s", tt.addr, cfg.Addr)
+			}
+		})
+	}
+
+	t.Run("Test CORS origins", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-cors-origins", "https://a.example.com, https://b.example.com"}, func(string) string { return "" })
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "https://b.example.com" {
+			t.Errorf("expected two origins got %v", cfg.CORSOrigins)
+		}
+	})
+
+	t.Run("Test client data origins", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-client-data-origins", "https://a.example.com,,"}, func(string) string { return "" })
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(cfg.ClientDataOrigins) != 1 || cfg.ClientDataOrigins[0] != "https://a.example.com" {
+			t.Errorf("expected one origin got %v", cfg.ClientDataOrigins)
+		}
+	})
+
+	t.Run("Test short challenge fails fast", func(t *testing.T) {
+		_, err := parseConfig([]string{"-challenge-bytes", "8"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test token ttl", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.TokenTTL != time.Hour {
+			t.Errorf("expected default token ttl to be 1h got %s, %v", cfg.TokenTTL, err)
+		}
+		_, err = parseConfig([]string{"-token-ttl", "0s"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test timestamp window", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.TimestampWindow != 30*time.Second {
+			t.Errorf("expected default timestamp window to be 30s got %s, %v", cfg.TimestampWindow, err)
+		}
+		_, err = parseConfig([]string{"-timestamp-window", "-1s"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test require enrollment", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || !cfg.RequireEnrollment {
+			t.Errorf("expected enrollment to be required by default got %v, %v", cfg.RequireEnrollment, err)
+		}
+		cfg, err = parseConfig([]string{"-require-enrollment=false"}, func(string) string { return "" })
+		if err != nil || cfg.RequireEnrollment {
+			t.Errorf("expected enrollment not to be required got %v, %v", cfg.RequireEnrollment, err)
+		}
+	})
+
+	t.Run("Test digests", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-digests", "SHA-512"}, func(string) string { return "" })
+		if err != nil || len(cfg.Digests) != 1 || cfg.Digests[0] != challenge.DigestSHA512 {
+			t.Errorf("expected digests to be [SHA-512] got %v, %v", cfg.Digests, err)
+		}
+		_, err = parseConfig([]string{"-digests", "MD5"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test sign challenges", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.SignChallenges {
+			t.Errorf("expected challenges not to be signed by default got %v, %v", cfg.SignChallenges, err)
+		}
+		cfg, err = parseConfig([]string{"-sign-challenges"}, func(string) string { return "" })
+		if err != nil || !cfg.SignChallenges {
+			t.Errorf("expected challenges to be signed got %v, %v", cfg.SignChallenges, err)
+		}
+	})
+
+	t.Run("Test max body bytes", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.MaxBodyBytes != 64<<10 {
+			t.Errorf("expected default max body bytes to be 65536 got %d, %v", cfg.MaxBodyBytes, err)
+		}
+		_, err = parseConfig([]string{"-max-body-bytes", "0"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test audit log", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-audit-log", "/var/log/signin.jsonl"}, func(string) string { return "" })
+		if err != nil || cfg.AuditLog != "/var/log/signin.jsonl" {
+			t.Errorf("expected audit log to be /var/log/signin.jsonl got %s, %v", cfg.AuditLog, err)
+		}
+	})
+
+	t.Run("Test token delivery", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.TokenDelivery != "body" {
+			t.Errorf("expected token delivery to be body got %s, %v", cfg.TokenDelivery, err)
+		}
+		cfg, err = parseConfig([]string{"-token-delivery", "cookie"}, func(string) string { return "" })
+		if err != nil || cfg.TokenDelivery != "cookie" {
+			t.Errorf("expected token delivery to be cookie got %s, %v", cfg.TokenDelivery, err)
+		}
+		_, err = parseConfig([]string{"-token-delivery", "header"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test challenge cap", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-max-challenges", "10", "-strict-challenge-cap"}, func(string) string { return "" })
+		if err != nil || cfg.ChallengeCap.Max != 10 || !cfg.ChallengeCap.Reject {
+			t.Errorf("expected a strict cap of 10 got %+v, %v", cfg.ChallengeCap, err)
+		}
+		_, err = parseConfig([]string{"-max-challenges", "-1"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test introspection cache", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.Introspect != introspect.DefaultConfig {
+			t.Errorf("expected the default introspection cache got %+v, %v", cfg.Introspect, err)
+		}
+		cfg, err = parseConfig([]string{"-introspect-ttl", "1m", "-introspect-negative-ttl", "5s", "-introspect-max-entries", "10"}, func(string) string { return "" })
+		want := introspect.Config{TTL: time.Minute, NegativeTTL: 5 * time.Second, MaxEntries: 10}
+		if err != nil || cfg.Introspect != want {
+			t.Errorf("expected introspection cache %+v got %+v, %v", want, cfg.Introspect, err)
+		}
+		path := writeConfigFile(t, "server.json", `{"introspect-ttl": "2m", "introspect-max-entries": 20}`)
+		cfg, err = parseConfig([]string{"-config", path}, func(string) string { return "" })
+		if err != nil || cfg.Introspect.TTL != 2*time.Minute || cfg.Introspect.MaxEntries != 20 {
+			t.Errorf("expected the file's introspection cache got %+v, %v", cfg.Introspect, err)
+		}
+		for _, args := range [][]string{
+			{"-introspect-ttl", "0s"},
+			{"-introspect-negative-ttl", "-1s"},
+			{"-introspect-max-entries", "0"},
+		} {
+			_, err = parseConfig(args, func(string) string { return "" })
+			if err == nil {
+				t.Errorf("expected %v to fail", args)
+			}
+		}
+	})
+
+	t.Run("Test enrollment CA", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-enroll-ca", "ca.pem"}, func(string) string { return "" })
+		if err != nil || cfg.EnrollCA != "ca.pem" {
+			t.Errorf("expected enrollment CA to be ca.pem got %s, %v", cfg.EnrollCA, err)
+		}
+	})
+
+	t.Run("Test stateless store needs a secret", func(t *testing.T) {
+		_, err := parseConfig([]string{"-store", "stateless", "-challenge-secret", "short"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+		secret := strings.Repeat("s", challenge.MinSecretSize)
+		cfg, err := parseConfig([]string{"-store", "stateless", "-challenge-secret", secret}, func(string) string { return "" })
+		if err != nil || cfg.ChallengeSecret != secret {
+			t.Errorf("expected challenge secret to be %s got %s, %v", secret, cfg.ChallengeSecret, err)
+		}
+	})
+
+	t.Run("Test shared used challenges need the stateless store", func(t *testing.T) {
+		_, err := parseConfig([]string{"-shared-used-challenges"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test gRPC address", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.GRPCAddr != "" {
+			t.Errorf("expected gRPC to be off got %q, %v", cfg.GRPCAddr, err)
+		}
+		cfg, err = parseConfig([]string{"-grpc-addr", ":9090"}, func(string) string { return "" })
+		if err != nil || cfg.GRPCAddr != ":9090" {
+			t.Errorf("expected gRPC address to be :9090 got %q, %v", cfg.GRPCAddr, err)
+		}
+	})
+
+	t.Run("Test pow difficulty", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil || cfg.Difficulty != 0 {
+			t.Errorf("expected proof of work to be off got %d, %v", cfg.Difficulty, err)
+		}
+		cfg, err = parseConfig([]string{"-pow-difficulty", "20"}, func(string) string { return "" })
+		if err != nil || cfg.Difficulty != 20 {
+			t.Errorf("expected difficulty to be 20 got %d, %v", cfg.Difficulty, err)
+		}
+		for _, args := range [][]string{
+			{"-pow-difficulty", "-1"},
+			{"-pow-difficulty", "33"},
+			{"-pow-difficulty", "8", "-grpc-addr", ":9090"},
+		} {
+			_, err = parseConfig(args, func(string) string { return "" })
+			if err == nil {
+				t.Errorf("expected error not to be nil for %v", args)
+			}
+		}
+	})
+
+	t.Run("Test unknown store", func(t *testing.T) {
+		_, err := parseConfig([]string{"-store", "etcd"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test unknown sign mode", func(t *testing.T) {
+		_, err := parseConfig([]string{"-sign-mode", "ed448"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test unknown flag", func(t *testing.T) {
+		_, err := parseConfig([]string{"-nope"}, func(string) string { return "" })
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+// writeConfigFile writes content to a file called name in a temporary
+// directory and returns its path.
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return path
+}
+
+func TestConfigFile(t *testing.T) {
+	yamlFile := `
+addr: ":7070"
+token-ttl: 2h
+require-enrollment: false
+max-challenges: 50
+cors-origins:
+  - https://a.example.com
+  - https://b.example.com
+`
+	noenv := func(string) string { return "" }
+
+	t.Run("Test YAML file", func(t *testing.T) {
+		cfg, err := parseConfig([]string{"-config", writeConfigFile(t, "server.yaml", yamlFile)}, noenv)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if cfg.Addr != ":7070" || cfg.TokenTTL != 2*time.Hour || cfg.RequireEnrollment || cfg.ChallengeCap.Max != 50 {
+			t.Errorf("expected the file's settings got %+v", cfg)
+		}
+		if len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "https://b.example.com" {
+			t.Errorf("expected 2 CORS origins got %v", cfg.CORSOrigins)
+		}
+	})
+
+	t.Run("Test JSON file", func(t *testing.T) {
+		path := writeConfigFile(t, "server.json", `{"addr": ":7070", "token-ttl": "2h", "max-challenges": 50}`)
+		cfg, err := parseConfig([]string{"-config", path}, noenv)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if cfg.Addr != ":7070" || cfg.TokenTTL != 2*time.Hour || cfg.ChallengeCap.Max != 50 {
+			t.Errorf("expected the file's settings got %+v", cfg)
+		}
+	})
+
+	t.Run("Test precedence", func(t *testing.T) {
+		path := writeConfigFile(t, "server.yaml", yamlFile)
+		env := func(key string) string {
+			return map[string]string{"SERVER_ADDR": ":8080", "CHALLENGE_SECRET": "from-env"}[key]
+		}
+		cfg, err := parseConfig([]string{"-config", path, "-addr", ":9090"}, env)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if cfg.Addr != ":9090" {
+			t.Errorf("expected the flag to override the file got %s", cfg.Addr)
+		}
+		if cfg.TokenTTL != 2*time.Hour {
+			t.Errorf("expected token ttl from the file got %s", cfg.TokenTTL)
+		}
+		cfg, err = parseConfig([]string{"-config", path}, env)
+		if err != nil || cfg.Addr != ":7070" {
+			t.Errorf("expected the file to override the env got %s, %v", cfg.Addr, err)
+		}
+		if cfg.ChallengeSecret != "from-env" {
+			t.Errorf("expected the env to fill what the file leaves out got %q", cfg.ChallengeSecret)
+		}
+	})
+
+	t.Run("Test LoadConfig", func(t *testing.T) {
+		t.Setenv("SERVER_ADDR", ":8080")
+		t.Setenv("CHALLENGE_SECRET", "from-env")
+		cfg, err := LoadConfig(writeConfigFile(t, "server.yaml", yamlFile))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if cfg.Addr != ":7070" || cfg.TokenTTL != 2*time.Hour || cfg.ChallengeCap.Max != 50 {
+			t.Errorf("expected the file's settings got %+v", cfg)
+		}
+		if cfg.ChallengeSecret != "from-env" {
+			t.Errorf("expected the env to fill what the file leaves out got %q", cfg.ChallengeSecret)
+		}
+		if cfg.RefreshWindow != defaultRefreshWindow {
+			t.Errorf("expected the default refresh window got %s", cfg.RefreshWindow)
+		}
+		_, err = LoadConfig(writeConfigFile(t, "server.json", `{"store": "stateless", "challenge-secret": "short"}`))
+		if err == nil || !strings.Contains(err.Error(), "challenge-secret") {
+			t.Errorf("expected a challenge-secret error got %v", err)
+		}
+	})
+
+	t.Run("Test invalid files", func(t *testing.T) {
+		tests := []struct {
+			name, file, content, want string
+		}{
+			{"unknown setting", "server.yaml", "adress: :7070", `unknown setting "adress"`},
+			{"bad value", "server.yaml", "token-ttl: soon", "token-ttl"},
+			{"failed validation", "server.json", `{"token-ttl": "0s"}`, "token ttl must be positive"},
+			{"unknown format", "server.toml", "addr = ':7070'", "unknown format"},
+			{"nested value", "server.yaml", "addr:\n  port: 7070", "addr"},
+		}
+		for _, tt := range tests {
+			_, err := parseConfig([]string{"-config", writeConfigFile(t, tt.file, tt.content)}, noenv)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %s error to mention %q got %v", tt.name, tt.want, err)
+			}
+		}
+		_, err := parseConfig([]string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, noenv)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected error to be %v got %v", os.ErrNotExist, err)
+		}
+	})
+}
+
+func TestNewServer(t *testing.T) {
+	a := newTestApp(t)
+	ready := newReadiness(a.signingProbe)
+
+	t.Run("Test server uses the resolved address", func(t *testing.T) {
+		cfg, _ := parseConfig([]string{"-addr", "127.0.0.1:4444"}, func(string) string { return "" })
+		server := newServer(cfg, a, ready)
+		if server.Addr != "127.0.0.1:4444" {
+			t.Errorf("expected addr to be 127.0.0.1:4444 got %s", server.Addr)
+		}
+	})
+
+	t.Run("Test debug routes depend on the flag", func(t *testing.T) {
+		for _, debug := range []bool{false, true} {
+			server := newServer(Config{Debug: debug}, a, ready)
+			req := httptest.NewRequest(http.MethodGet, "/debug/signing-input", nil)
+			w := httptest.NewRecorder()
+			server.Handler.ServeHTTP(w, req)
+			registered := w.Code != http.StatusNotFound
+			if registered != debug {
+				t.Errorf("expected debug route registered to be %v got status %d", debug, w.Code)
+			}
+		}
+	})
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Test graceful shutdown", func(t *testing.T) {
+		a := newTestApp(t)
+		server := newServer(Config{}, a, newReadiness(a.signingProbe))
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+
+		shutdown := make(chan os.Signal, 1)
+		done := make(chan error, 1)
+		go func() {
+			done <- serve(server, ln, "", "", shutdown)
+		}()
+
+		res, err := http.Get("http://" + ln.Addr().String() + "/signIn")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+
+		shutdown <- syscall.SIGTERM
+		select {
+		case err := <-done:
+			if !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("expected error to be %v got %v", http.ErrServerClosed, err)
+			}
+		case <-time.After(shutdownTimeout + time.Second):
+			t.Fatalf("expected serve to return after shutdown")
+		}
+	})
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 and its
+// key to dir and returns their paths and a pool trusting the certificate.
+func writeSelfSignedCert(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestServeTLS(t *testing.T) {
+	certFile, keyFile, pool := writeSelfSignedCert(t, t.TempDir())
+	a := newTestApp(t)
+	server := newServer(Config{}, a, newReadiness(a.signingProbe))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(server, ln, certFile, keyFile, shutdown)
+	}()
+
+	t.Run("Test sign in over HTTPS", func(t *testing.T) {
+		c := client.New("https://" + ln.Addr().String())
+		c.HTTPClient = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		pub, priv := testutil.DeterministicEd25519(15)
+		token, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if token == "" {
+			t.Errorf("expected token not to be empty")
+		}
+	})
+
+	t.Run("Test HTTP/2 is negotiated", func(t *testing.T) {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}}
+		res, err := c.Get("https://" + ln.Addr().String() + "/healthz")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		res.Body.Close()
+		if res.ProtoMajor != 2 {
+			t.Errorf("expected protocol to be HTTP/2 got %s", res.Proto)
+		}
+	})
+
+	t.Run("Test plain HTTP is refused", func(t *testing.T) {
+		res, err := http.Get("http://" + ln.Addr().String() + "/signIn")
+		if err == nil {
+			defer res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				t.Errorf("expected plain HTTP request to fail")
+			}
+		}
+	})
+
+	shutdown <- syscall.SIGTERM
+	err = <-done
+	if !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected error to be %v got %v", http.ErrServerClosed, err)
+	}
+}
+
+// startServer serves newServer(cfg) on a loopback port until the test ends.
+func startServer(t *testing.T, cfg Config) string {
+	t.Helper()
+	a := newTestApp(t)
+	server := newServer(cfg, a, newReadiness(a.signingProbe))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	shutdown := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(server, ln, "", "", shutdown)
+	}()
+	t.Cleanup(func() {
+		shutdown <- syscall.SIGTERM
+		<-done
+	})
+	return ln.Addr().String()
+}
+
+func TestServerTimeouts(t *testing.T) {
+	t.Run("Test timeout defaults", func(t *testing.T) {
+		cfg, err := parseConfig(nil, func(string) string { return "" })
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		server := newServer(cfg, newTestApp(t), nil)
+		if server.ReadHeaderTimeout != defaultReadHeaderTimeout || server.WriteTimeout != defaultWriteTimeout {
+			t.Errorf("expected default timeouts got %s and %s", server.ReadHeaderTimeout, server.WriteTimeout)
+		}
+		if server.WriteTimeout <= defaultLimits.Timeout {
+			t.Errorf("expected write timeout %s to outlast route timeout %s", server.WriteTimeout, defaultLimits.Timeout)
+		}
+	})
+
+	t.Run("Test slow headers are cut off", func(t *testing.T) {
+		addr := startServer(t, Config{ReadHeaderTimeout: 100 * time.Millisecond})
+		start := time.Now()
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("GET /healthz HTTP/1.1\r\nHost: localhost\r\n"))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = io.ReadAll(conn)
+		if err != nil {
+			t.Fatalf("expected the server to close the connection got %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 2*time.Second {
+			t.Errorf("expected the connection to close after about 100ms took %s", elapsed)
+		}
+	})
+
+	t.Run("Test h2c", func(t *testing.T) {
+		addr := startServer(t, Config{H2C: true})
+		c := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		}}
+		res, err := c.Get("http://" + addr + "/healthz")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		res.Body.Close()
+		if res.ProtoMajor != 2 {
+			t.Errorf("expected protocol to be HTTP/2 got %s", res.Proto)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfig resolves the configuration from the YAML or JSON file at path,
+// as -config would with no other flags: the environment fills in what the
+// file leaves out, and the defaults the rest. It fails, naming the setting,
+// on unknown settings, bad values and settings that don't validate.
+func LoadConfig(path string) (Config, error) {
+	return parseConfig([]string{"-config", path}, os.Getenv)
+}
+
+// loadConfigFile reads the settings in the YAML or JSON file at path, picked
+// by its extension. Each key is the name of a flag and each value what the
+// flag would be given: a string, number or bool, or a list for the flags
+// that take comma-separated values.
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format %q, want .json, .yaml or .yml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]string, len(raw))
+	for name, v := range raw {
+		value, err := settingValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+		settings[name] = value
+	}
+	return settings, nil
+}
+
+// settingValue renders a value decoded from a config file as a flag value.
+func settingValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+// applyConfigFile sets every flag in fs from the file at path unless it was
+// already given on the command line, so flags override the file.
+func applyConfigFile(fs *flag.FlagSet, path string) error {
+	settings, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	// Sorted so the first bad setting reported doesn't vary.
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		err = fs.Set(name, settings[name])
+		if err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+)
+
+// How a successful sign-in hands over its token: in the JSON body, or in an
+// HttpOnly cookie browser scripts can't read.
+const (
+	tokenDeliveryBody   = "body"
+	tokenDeliveryCookie = "cookie"
+)
+
+// sessionCookie is the cookie tokens are delivered in with -token-delivery
+// cookie.
+const sessionCookie = "session"
+
+// setSessionCookie sets the session cookie to token, expiring with it.
+func (a *app) setSessionCookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(a.tokenTTL.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// requestToken returns the token from an "Authorization: Bearer" header or,
+// failing that, from the session cookie.
+func requestToken(r *http.Request) (string, bool) {
+	token, ok := bearerToken(r)
+	if ok {
+		return token, true
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// sessionCookieOf returns the session cookie res sets, or nil.
+func sessionCookieOf(res *http.Response) *http.Cookie {
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == sessionCookie {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestTokenDelivery(t *testing.T) {
+	t.Run("Test body delivery", func(t *testing.T) {
+		a := newTestApp(t)
+		res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		if cookie := sessionCookieOf(res); cookie != nil {
+			t.Errorf("expected no session cookie got %v", cookie)
+		}
+		token := decodeToken(t, res)
+		if a.keys.Verify(token) != nil {
+			t.Errorf("expected token in the body to verify got %q", token)
+		}
+	})
+
+	a := newTestApp(t)
+	a.tokenDelivery = tokenDeliveryCookie
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+	var session *http.Cookie
+
+	t.Run("Test cookie delivery", func(t *testing.T) {
+		res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status code to be 204 got %d", res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if len(body) != 0 {
+			t.Errorf("expected empty body got %s", body)
+		}
+		session = sessionCookieOf(res)
+		if session == nil {
+			t.Fatalf("expected a session cookie")
+		}
+		if !session.Secure || !session.HttpOnly || session.SameSite != http.SameSiteStrictMode {
+			t.Errorf("expected a Secure, HttpOnly, SameSite=Strict cookie got %s", session)
+		}
+		if session.MaxAge != int(a.tokenTTL.Seconds()) {
+			t.Errorf("expected max age to be %d got %d", int(a.tokenTTL.Seconds()), session.MaxAge)
+		}
+		if a.keys.Verify(session.Value) != nil {
+			t.Errorf("expected token in the cookie to verify got %q", session.Value)
+		}
+	})
+
+	t.Run("Test authorized by the cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.AddCookie(session)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test header wins over the cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.AddCookie(session)
+		req.Header.Set("Authorization", "Bearer not.a.token")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test refresh with the cookie", func(t *testing.T) {
+		now := time.Now()
+		expiring, err := a.signClaims(context.Background(), &jws.ClaimSet{Sub: "device", Iat: now.Add(-a.tokenTTL).Unix(), Exp: now.Add(time.Minute).Unix()})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: expiring})
+		w := httptest.NewRecorder()
+		a.refresh(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status code to be 204 got %d", res.StatusCode)
+		}
+		renewed := sessionCookieOf(res)
+		if renewed == nil || renewed.Value == expiring || a.keys.Verify(renewed.Value) != nil {
+			t.Errorf("expected a new verifying token in the session cookie got %v", renewed)
+		}
+	})
+	t.Run("Test revoke with the cookie", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/revoke", nil)
+		req.AddCookie(session)
+		w := httptest.NewRecorder()
+		a.revoke(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code to be 204 got %d", w.Code)
+		}
+		res := postVerify(t, a, session.Value)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "token_revoked" {
+			t.Errorf("expected error code to be token_revoked got %s", code)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+// cors lets browsers on allowedOrigins call the API cross-origin. It answers
+// preflight requests itself and adds Access-Control-Allow-Origin to other
+// responses when the request's Origin is allowed. "*" allows any origin.
+func cors(allowedOrigins []string) func(http.Handler) http.Handler {
+	allowed := func(origin string) bool {
+		return origin != "" && (slices.Contains(allowedOrigins, origin) || slices.Contains(allowedOrigins, "*"))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+			if allowed(origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if allowed(origin) {
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
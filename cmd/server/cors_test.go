@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := cors([]string{"https://app.example.com"})(next)
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		status      int
+		allowOrigin string
+		allowMethod string
+	}{
+		{"Test allowed origin", http.MethodGet, "https://app.example.com", false, http.StatusOK, "https://app.example.com", ""},
+		{"Test disallowed origin", http.MethodGet, "https://evil.example.com", false, http.StatusOK, "", ""},
+		{"Test preflight from allowed origin", http.MethodOptions, "https://app.example.com", true, http.StatusNoContent, "https://app.example.com", "GET, POST"},
+		{"Test preflight from disallowed origin", http.MethodOptions, "https://evil.example.com", true, http.StatusNoContent, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/signIn", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			res := w.Result()
+			if res.StatusCode != tt.status {
+				t.Errorf("expected status code to be %d got %d", tt.status, res.StatusCode)
+			}
+			if got := res.Header.Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("expected allow origin to be %q got %q", tt.allowOrigin, got)
+			}
+			if got := res.Header.Get("Access-Control-Allow-Methods"); got != tt.allowMethod {
+				t.Errorf("expected allow methods to be %q got %q", tt.allowMethod, got)
+			}
+		})
+	}
+}
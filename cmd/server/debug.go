@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// registerDebug adds the debug endpoints to mux. They expose server internals
+// and must only be registered when the server runs with -debug.
+func registerDebug(mux *http.ServeMux, a *app) {
+	handle(mux, "/debug/signing-input", http.HandlerFunc(a.signingInput))
+	handle(mux, "/debug/decode", http.HandlerFunc(a.debugDecode))
+	handle(mux, "/debug/selftest", http.HandlerFunc(a.selfTest))
+}
+
+// marshalDebug marshals v compactly, or indented when the request asks for
+// ?pretty=true.
+func marshalDebug(r *http.Request, v interface{}) ([]byte, error) {
+	pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty"))
+	if pretty {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// writeDebug writes body, from marshalDebug, as a 200 JSON response.
+func writeDebug(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// signingInput returns the exact bytes the server verifies a signature over
+// for a JWS header and payload, or for a sign-in challenge response, along
+// with their SHA-256. A JWS input is the header and payload base64url-encoded
+// as sent. A challenge input is built as verifyChallengeResponse builds it,
+// in the requested or the server's sign mode, and Verified is what Ed25519
+// is then called on. ?pretty=true indents the response.
+func (a *app) signingInput(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.SigningInputRequest{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling signing input request")
+		return
+	}
+
+	var result dto.SigningInput
+	if body.Challenge != "" || len(body.ClientData) > 0 {
+		result, err = a.challengeSigningInput(body)
+	} else {
+		result, err = jwsSigningInput(body)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	res, err := marshalDebug(r, result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling signing input")
+		return
+	}
+	writeDebug(w, res)
+}
+
+// jwsSigningInput encodes body's header and payload byte for byte; decoding
+// and re-encoding them would reorder claims and fill in iat and exp.
+func jwsSigningInput(body dto.SigningInputRequest) (dto.SigningInput, error) {
+	var header, payload map[string]json.RawMessage
+	if json.Unmarshal(body.Header, &header) != nil || header == nil ||
+		json.Unmarshal(body.Payload, &payload) != nil || payload == nil {
+		return dto.SigningInput{}, errors.New("header and payload must be JSON objects")
+	}
+	input := b64.RawURLEncoding.EncodeToString(body.Header) + "." + b64.RawURLEncoding.EncodeToString(body.Payload)
+	digest := sha256.Sum256([]byte(input))
+	return dto.SigningInput{Input: input, SHA256: hex.EncodeToString(digest[:])}, nil
+}
+
+// challengeSigningInput builds what a response with body's fields is verified
+// over.
+func (a *app) challengeSigningInput(body dto.SigningInputRequest) (dto.SigningInput, error) {
+	mode := a.signMode
+	if body.Mode != "" {
+		m, err := challenge.ParseMode(body.Mode)
+		if err != nil {
+			return dto.SigningInput{}, err
+		}
+		mode = m
+	}
+	if mode != challenge.ModeDigest && body.Digest != "" {
+		return dto.SigningInput{}, errors.New("digest is only used in sha256 sign mode")
+	}
+	digest, err := challenge.ParseDigest(body.Digest)
+	if err != nil {
+		return dto.SigningInput{}, err
+	}
+	if body.Timestamp != 0 && len(body.ClientData) > 0 {
+		return dto.SigningInput{}, errors.New("timestamp can't be combined with client data")
+	}
+
+	resp := dto.ChallengeResponse{
+		Message:    body.Challenge,
+		ClientData: body.ClientData,
+		Timestamp:  body.Timestamp,
+		Digest:     body.Digest,
+	}
+	input := string(challenge.SignedMessage(resp.Message, resp.Timestamp))
+	if len(resp.ClientData) > 0 {
+		input = string(resp.ClientData)
+	}
+	sum := sha256.Sum256([]byte(input))
+	return dto.SigningInput{
+		Input:    input,
+		SHA256:   hex.EncodeToString(sum[:]),
+		Mode:     string(mode),
+		Verified: hex.EncodeToString(mode.Input(auth.SignedBytes(resp), digest)),
+	}, nil
+}
+
+// debugDecode returns the header and claims of the token in the body without
+// validating it, indented with ?pretty=true. When the token's kid names one
+// of the server's keys it also says whether the signature verifies; expiry
+// and revocation are not checked.
+func (a *app) debugDecode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.Jws{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling decode request")
+		return
+	}
+	header, err := jws.DecodeHeader(body.Token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_token", err.Error())
+		return
+	}
+	claims, err := jws.Decode(body.Token)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_token", err.Error())
+		return
+	}
+
+	decoded := dto.DecodedToken{}
+	decoded.Header, err = json.Marshal(header)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling header")
+		return
+	}
+	decoded.Claims, err = json.Marshal(claims.AllClaims())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling claims")
+		return
+	}
+	keys := a.keys.Keys()
+	if _, ok := keys[header.KeyID]; ok && header.KeyID != "" {
+		valid := jws.VerifyAny(body.Token, keys) == nil
+		decoded.SignatureValid = &valid
+	}
+
+	res, err := marshalDebug(r, decoded)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling decoded token")
+		return
+	}
+	writeDebug(w, res)
+}
+
+// selfTest mints a throwaway token with the active signing key and verifies
+// it with the trusted public key, answering 500 when that fails, e.g. because
+// the key doesn't match the certificate it was loaded with.
+func (a *app) selfTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	result := dto.SelfTest{Ok: true}
+	status := http.StatusOK
+	err := a.signingProbe()
+	if err != nil {
+		result = dto.SelfTest{Error: err.Error()}
+		status = http.StatusInternalServerError
+	}
+	res, err := json.Marshal(result)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling self-test")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(res)
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	b64 "encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func postSigningInput(t *testing.T, a *app, reqBody dto.SigningInputRequest) dto.SigningInput {
+	t.Helper()
+	b, _ := json.Marshal(reqBody)
+	return postSigningInputBody(t, a, b)
+}
+
+// postSigningInputBody posts b as is; json.Marshal would compact the raw
+// header and payload in a SigningInputRequest.
+func postSigningInputBody(t *testing.T, a *app, b []byte) dto.SigningInput {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/debug/signing-input", bytes.NewBuffer(b))
+	w := httptest.NewRecorder()
+	a.signingInput(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	body := dto.SigningInput{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+	return body
+}
+
+func TestSigningInput(t *testing.T) {
+	a := newTestApp(t)
+
+	t.Run("Test header and payload match the signed token", func(t *testing.T) {
+		header := &jws.Header{Algorithm: "RS256", Typ: "JWT"}
+		claims := &jws.ClaimSet{
+			Iss:           "issuer",
+			Exp:           time.Now().Add(time.Hour).Unix(),
+			Iat:           time.Now().Unix(),
+			PrivateClaims: map[string]interface{}{"role": "admin"},
+		}
+		var signed []byte
+		token, err := jws.EncodeWithSigner(header, claims, func(data []byte) ([]byte, error) {
+			signed = data
+			return []byte("sig"), nil
+		})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		parts := strings.Split(token, ".")
+		headerJson, _ := b64.RawURLEncoding.DecodeString(parts[0])
+		payloadJson, _ := b64.RawURLEncoding.DecodeString(parts[1])
+
+		body := postSigningInput(t, a, dto.SigningInputRequest{Header: headerJson, Payload: payloadJson})
+		if body.Input != string(signed) {
+			t.Errorf("expected input to be %s got %s", signed, body.Input)
+		}
+		digest := sha256.Sum256(signed)
+		if body.SHA256 != hex.EncodeToString(digest[:]) {
+			t.Errorf("expected sha256 to be %x got %s", digest, body.SHA256)
+		}
+	})
+
+	t.Run("Test header and payload are encoded as sent", func(t *testing.T) {
+		headerJson := []byte(`{"typ":"JWT", "alg":"EdDSA"}`)
+		payloadJson := []byte(`{"sub": "alice", "iat": 0}`)
+		body := postSigningInputBody(t, a, []byte(`{"header":`+string(headerJson)+`,"payload":`+string(payloadJson)+`}`))
+
+		expected := b64.RawURLEncoding.EncodeToString(headerJson) + "." + b64.RawURLEncoding.EncodeToString(payloadJson)
+		if body.Input != expected {
+			t.Errorf("expected input to be %s got %s", expected, body.Input)
+		}
+	})
+
+	t.Run("Test challenge digest matches sign in", func(t *testing.T) {
+		message := "8ce8129fad2ed163736b562819f6fed5fd72e072e30b0c354a4a9a8497a4c6dc"
+		body := postSigningInput(t, a, dto.SigningInputRequest{Challenge: message})
+
+		digest := sha256.Sum256([]byte(message))
+		if body.Input != message {
+			t.Errorf("expected input to be %s got %s", message, body.Input)
+		}
+		if body.Verified != hex.EncodeToString(digest[:]) {
+			t.Errorf("expected verified to be %x got %s", digest, body.Verified)
+		}
+	})
+}
+
+// TestSigningInputVerifies signs exactly what /debug/signing-input says is
+// verified and checks verifyChallengeResponse accepts it.
+func TestSigningInputVerifies(t *testing.T) {
+	a := newTestApp(t)
+	a.digests = []challenge.Digest{challenge.DigestSHA256, challenge.DigestSHA512}
+	a.clientDataOrigins = []string{"https://app.example.com"}
+	pub, priv := testutil.DeterministicEd25519(57)
+
+	type variant struct {
+		name   string
+		mode   challenge.Mode
+		digest challenge.Digest
+		stamp  bool
+		client bool
+	}
+	variants := []variant{}
+	for _, mode := range []challenge.Mode{challenge.ModeDigest, challenge.ModeRaw, challenge.ModePrehash} {
+		variants = append(variants,
+			variant{name: string(mode), mode: mode},
+			variant{name: string(mode) + " timestamped", mode: mode, stamp: true},
+			variant{name: string(mode) + " client data", mode: mode, client: true},
+		)
+	}
+	variants = append(variants, variant{name: "sha256 SHA-512", mode: challenge.ModeDigest, digest: challenge.DigestSHA512})
+
+	for _, v := range variants {
+		t.Run("Test "+v.name, func(t *testing.T) {
+			a.signMode = v.mode
+			defer func() { a.signMode = challenge.DefaultMode }()
+
+			message := getChallenge(t, a).Message
+			resp := dto.ChallengeResponse{
+				Message:   message,
+				PublicKey: b64.StdEncoding.EncodeToString(pub),
+				Digest:    string(v.digest),
+			}
+			if v.stamp {
+				resp.Timestamp = time.Now().Unix()
+			}
+			if v.client {
+				resp.ClientData, _ = json.Marshal(dto.ClientData{Type: clientDataType, Origin: "https://app.example.com", Challenge: message})
+			}
+			body := postSigningInput(t, a, dto.SigningInputRequest{
+				Challenge:  resp.Message,
+				ClientData: resp.ClientData,
+				Timestamp:  resp.Timestamp,
+				Digest:     resp.Digest,
+			})
+			if body.Mode != string(v.mode) {
+				t.Errorf("expected mode to be %s got %s", v.mode, body.Mode)
+			}
+
+			verified, err := hex.DecodeString(body.Verified)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			sig := ed25519.Sign(priv, verified)
+			if v.mode == challenge.ModePrehash {
+				sig, _ = priv.Sign(nil, verified, &ed25519.Options{Hash: crypto.SHA512})
+			}
+			resp.Signature = b64.StdEncoding.EncodeToString(sig)
+			if _, e := a.verifyChallengeResponse(resp, ""); e != nil {
+				t.Errorf("expected response to verify got %s", e.code)
+			}
+		})
+	}
+}
+
+func TestDebugDecode(t *testing.T) {
+	a := newTestApp(t)
+	token := signInToken(t, a)
+	body, _ := json.Marshal(dto.Jws{Token: token})
+
+	t.Run("Test not found without -debug", func(t *testing.T) {
+		handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+		req := httptest.NewRequest(http.MethodPost, "/debug/decode", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code to be 404 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test decoded fields with -debug", func(t *testing.T) {
+		handler := newServer(Config{Debug: true}, a, newReadiness(a.signingProbe)).Handler
+		req := httptest.NewRequest(http.MethodPost, "/debug/decode", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", w.Code)
+		}
+
+		decoded := dto.DecodedToken{}
+		err := json.Unmarshal(w.Body.Bytes(), &decoded)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		header := jws.Header{}
+		json.Unmarshal(decoded.Header, &header)
+		if header.KeyID != tokenHeader(t, token).KeyID {
+			t.Errorf("expected kid to be %s got %s", tokenHeader(t, token).KeyID, header.KeyID)
+		}
+		claims := map[string]interface{}{}
+		json.Unmarshal(decoded.Claims, &claims)
+		if claims["sub"] == nil || claims["exp"] == nil {
+			t.Errorf("expected sub and exp claims got %v", claims)
+		}
+		if decoded.SignatureValid == nil || !*decoded.SignatureValid {
+			t.Errorf("expected signature to be valid got %v", decoded.SignatureValid)
+		}
+	})
+
+	t.Run("Test signature of an unknown key is not judged", func(t *testing.T) {
+		other := signInToken(t, newTestApp(t))
+		otherBody, _ := json.Marshal(dto.Jws{Token: other})
+		req := httptest.NewRequest(http.MethodPost, "/debug/decode", bytes.NewReader(otherBody))
+		w := httptest.NewRecorder()
+		a.debugDecode(w, req)
+		decoded := dto.DecodedToken{}
+		json.Unmarshal(w.Body.Bytes(), &decoded)
+		if decoded.SignatureValid != nil {
+			t.Errorf("expected signatureValid to be unset got %v", *decoded.SignatureValid)
+		}
+	})
+}
+
+// mismatchedSigner signs with its embedded key but claims another public key,
+// like a private key loaded alongside the wrong certificate.
+type mismatchedSigner struct {
+	crypto.Signer
+	pub crypto.PublicKey
+}
+
+func (s mismatchedSigner) Public() crypto.PublicKey { return s.pub }
+
+func TestPrettyDebugJSON(t *testing.T) {
+	a := newTestApp(t)
+	body, _ := json.Marshal(dto.Jws{Token: signInToken(t, a)})
+	handler := newServer(Config{Debug: true}, a, newReadiness(a.signingProbe)).Handler
+
+	tests := []struct {
+		name   string
+		target string
+		pretty bool
+	}{
+		{"Test compact verify", "/verify", false},
+		{"Test pretty verify", "/verify?pretty=true", true},
+		{"Test compact decode", "/debug/decode", false},
+		{"Test pretty decode", "/debug/decode?pretty=true", true},
+		{"Test pretty false decode", "/debug/decode?pretty=false", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+			}
+			if res.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("expected content type to be application/json got %s", res.Header.Get("Content-Type"))
+			}
+			if res.Header.Get("Content-Length") != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("expected content length to be %d got %s", w.Body.Len(), res.Header.Get("Content-Length"))
+			}
+			if indented := strings.Contains(w.Body.String(), "\n  \""); indented != tt.pretty {
+				t.Errorf("expected indented to be %v got %s", tt.pretty, w.Body.String())
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("expected valid JSON got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	header := jws.Header{Algorithm: "RS256", Typ: "JWT"}
+
+	tests := []struct {
+		name       string
+		key        crypto.Signer
+		wantStatus int
+		wantOk     bool
+	}{
+		{"Test matching key", key, http.StatusOK, true},
+		{"Test mismatched public key", mismatchedSigner{Signer: key, pub: &other.PublicKey}, http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAppWithKey(t, tt.key, header)
+			handler := newServer(Config{Debug: true}, a, newReadiness(a.signingProbe)).Handler
+			req := httptest.NewRequest(http.MethodGet, "/debug/selftest", nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status code to be %d got %d", tt.wantStatus, w.Code)
+			}
+
+			result := dto.SelfTest{}
+			err := json.Unmarshal(w.Body.Bytes(), &result)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if result.Ok != tt.wantOk {
+				t.Errorf("expected ok to be %v got %v", tt.wantOk, result.Ok)
+			}
+			if !tt.wantOk && result.Error == "" {
+				t.Errorf("expected an error got none")
+			}
+		})
+	}
+
+	t.Run("Test not served without -debug", func(t *testing.T) {
+		a := newTestApp(t)
+		handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+		req := httptest.NewRequest(http.MethodGet, "/debug/selftest", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status code to be 404 got %d", w.Code)
+		}
+	})
+}
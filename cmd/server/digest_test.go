@@ -0,0 +1,91 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// signDigest answers message in sha256 sign mode under signed, naming named.
+func signDigest(t *testing.T, message string, signed challenge.Digest, named string) dto.ChallengeResponse {
+	t.Helper()
+	pub, priv := testutil.DeterministicEd25519(16)
+	sig, err := challenge.ModeDigest.SignDigest(priv, []byte(message), signed)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return dto.ChallengeResponse{
+		Signature: b64.StdEncoding.EncodeToString(sig),
+		Message:   message,
+		PublicKey: b64.StdEncoding.EncodeToString(pub),
+		Digest:    named,
+	}
+}
+
+func TestSignInDigest(t *testing.T) {
+	a := newTestApp(t)
+
+	t.Run("Test challenge advertises the preferred digest", func(t *testing.T) {
+		if digest := getChallenge(t, a).Digest; digest != "SHA-256" {
+			t.Errorf("expected digest to be SHA-256 got %s", digest)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		signed challenge.Digest
+		named  string
+		code   string
+	}{
+		{"Test SHA-512 on both sides", challenge.DigestSHA512, "SHA-512", ""},
+		{"Test SHA-256 named explicitly", challenge.DigestSHA256, "SHA-256", ""},
+		{"Test SHA-512 signature named as default", challenge.DigestSHA512, "", "invalid_signature"},
+		{"Test SHA-256 signature named as SHA-512", challenge.DigestSHA256, "SHA-512", "invalid_signature"},
+		{"Test digest outside the allow-list", challenge.DigestSHA256, "MD5", "unsupported_digest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := postSignIn(t, a, signDigest(t, getChallenge(t, a).Message, tt.signed, tt.named))
+			defer res.Body.Close()
+			if tt.code == "" {
+				if res.StatusCode != http.StatusOK {
+					t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+				}
+				return
+			}
+			if code := errorCode(t, res); code != tt.code {
+				t.Errorf("expected error code to be %s got %s", tt.code, code)
+			}
+		})
+	}
+
+	t.Run("Test digest the server doesn't allow", func(t *testing.T) {
+		a.digests = []challenge.Digest{challenge.DigestSHA512}
+		defer func() { a.digests = defaultDigests }()
+		if digest := getChallenge(t, a).Digest; digest != "SHA-512" {
+			t.Errorf("expected digest to be SHA-512 got %s", digest)
+		}
+		res := postSignIn(t, a, signDigest(t, getChallenge(t, a).Message, challenge.DigestSHA256, ""))
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "unsupported_digest" {
+			t.Errorf("expected error code to be unsupported_digest got %s", code)
+		}
+	})
+
+	t.Run("Test digest in another sign mode", func(t *testing.T) {
+		a.signMode = challenge.ModeRaw
+		defer func() { a.signMode = challenge.DefaultMode }()
+		if digest := getChallenge(t, a).Digest; digest != "" {
+			t.Errorf("expected no digest got %s", digest)
+		}
+		res := postSignIn(t, a, signDigest(t, getChallenge(t, a).Message, challenge.DigestSHA512, "SHA-512"))
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "unsupported_digest" {
+			t.Errorf("expected error code to be unsupported_digest got %s", code)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/enrollment"
+)
+
+// enroll answers POST /enroll: it lets a public key sign in as an identity.
+// The first key of an identity is enrolled on trust; once the identity is
+// claimed, adding more takes a bearer token whose sub is that identity, so
+// only its holder can, or that has adminScope. With -enroll-ca the key comes
+// from a certificate chaining to the CA instead. Either way the request must
+// carry a proof: a challenge response signed by the key, so nobody can enroll
+// a public key they don't hold. DELETE /enroll is unenroll.
+func (a *app) enroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		a.unenroll(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost, http.MethodDelete)
+		return
+	}
+
+	body := dto.Enrollment{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling enrollment")
+		return
+	}
+	if body.Identity == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "identity is required")
+		return
+	}
+	pk, ok := a.enrollmentKey(w, body)
+	if !ok {
+		return
+	}
+
+	if a.enrollment.Claimed(body.Identity) {
+		token, ok := bearerToken(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "missing_token", "identity is taken; enrolling another key needs its bearer token")
+			return
+		}
+		claims, err := a.verifyToken(token)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "invalid_token", "token does not verify")
+			return
+		}
+		if claims.Sub != body.Identity && !claims.HasScope(adminScope) {
+			writeError(w, http.StatusForbidden, "identity_mismatch", "token is for another identity")
+			return
+		}
+	}
+
+	if !a.provePossession(w, r, body.Proof, pk) {
+		return
+	}
+
+	err = a.enrollment.Enroll(body.Identity, pk)
+	if errors.Is(err, enrollment.ErrAlreadyEnrolled) {
+		writeError(w, http.StatusConflict, "already_enrolled", "public key is already enrolled")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error enrolling public key")
+		return
+	}
+
+	enrolled := dto.Enrollment{Identity: body.Identity}
+	enrolled.SetPublicKey(pk)
+	res, err := json.Marshal(enrolled)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling enrollment")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(res)
+}
+
+// unenroll answers DELETE /enroll: it stops a public key signing in as an
+// identity, as when a device is lost. It takes a bearer token whose sub is
+// that identity or that has adminScope. Tokens don't say which key signed in
+// for them, so every token already minted for the identity is revoked; its
+// other keys have to sign in again. Without -require-enrollment tokens name
+// the key itself, so those are revoked too. The identity stays claimed after its
+// last key goes, so only an admin can enroll a key for it then.
+func (a *app) unenroll(w http.ResponseWriter, r *http.Request) {
+	body := dto.Enrollment{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling enrollment")
+		return
+	}
+	if body.Identity == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "identity is required")
+		return
+	}
+	pk, err := auth.DecodeKeyMaterial(body.PublicKey)
+	if err != nil || len(pk) != ed25519.PublicKeySize {
+		writeError(w, http.StatusBadRequest, "invalid_public_key", "invalid public key")
+		return
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing_token", "unenrolling a key needs a bearer token")
+		return
+	}
+	claims, err := a.verifyToken(token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_token", "token does not verify")
+		return
+	}
+	if claims.Sub != body.Identity && !claims.HasScope(adminScope) {
+		writeError(w, http.StatusForbidden, "identity_mismatch", "token is for another identity")
+		return
+	}
+
+	err = a.enrollment.Unenroll(body.Identity, pk)
+	if errors.Is(err, enrollment.ErrNotEnrolled) {
+		writeError(w, http.StatusNotFound, "not_enrolled", "public key is not enrolled under identity")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error unenrolling public key")
+		return
+	}
+	exp := time.Now().Add(a.tokenTTL).Unix()
+	a.revoked.RevokeSubject(body.Identity, exp)
+	if !a.requireEnrollment {
+		a.revoked.RevokeSubject(b64.StdEncoding.EncodeToString(pk), exp)
+	}
+
+	unenrolled := dto.Enrollment{Identity: body.Identity}
+	unenrolled.SetPublicKey(pk)
+	res, err := json.Marshal(unenrolled)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling enrollment")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// adminScope is the scope a token needs to administer enrollments.
+const adminScope = "admin"
+
+// enrolledKeys answers GET /enroll/{identity} with the keys enrolled under
+// identity and when. An unknown identity just has no keys. It must be
+// wrapped in authorize(adminScope).
+func (a *app) enrolledKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	identity := strings.TrimPrefix(r.URL.Path, "/enroll/")
+	if identity == "" {
+		writeError(w, http.StatusBadRequest, "invalid_request", "identity is required")
+		return
+	}
+
+	list := dto.EnrolledKeys{Identity: identity, Keys: []dto.EnrolledKey{}}
+	for _, k := range a.enrollment.Enrolled(identity) {
+		list.Keys = append(list.Keys, dto.EnrolledKey{PublicKey: dto.EncodeBinary(k.PublicKey), EnrolledAt: k.EnrolledAt.Unix()})
+	}
+	res, err := json.Marshal(list)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling enrolled keys")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// enrollmentKey returns the key body enrolls: its certificate's when the
+// server has an enrollment CA, its raw public key otherwise. On failure it
+// writes the error response and returns false.
+func (a *app) enrollmentKey(w http.ResponseWriter, body dto.Enrollment) (ed25519.PublicKey, bool) {
+	if a.enrollCA == nil {
+		if body.Certificate != "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "server does not enroll certificates")
+			return nil, false
+		}
+		pk, err := auth.DecodeKeyMaterial(body.PublicKey)
+		if err != nil || len(pk) != ed25519.PublicKeySize {
+			writeError(w, http.StatusBadRequest, "invalid_public_key", "invalid public key")
+			return nil, false
+		}
+		return pk, true
+	}
+
+	if body.Certificate == "" {
+		writeError(w, http.StatusBadRequest, "certificate_required", "enrollment needs a certificate from the enrollment CA")
+		return nil, false
+	}
+	pk, err := enrollment.CertifiedKey([]byte(body.Certificate), a.enrollCA, time.Now())
+	if errors.Is(err, enrollment.ErrUnsupportedKeyType) {
+		writeError(w, http.StatusBadRequest, "unsupported_key_type", err.Error())
+		return nil, false
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_certificate", err.Error())
+		return nil, false
+	}
+	// A public key sent along must be the certified one.
+	if body.PublicKey != "" {
+		raw, err := auth.DecodeKeyMaterial(body.PublicKey)
+		if err != nil || !pk.Equal(ed25519.PublicKey(raw)) {
+			writeError(w, http.StatusBadRequest, "invalid_public_key", "public key does not match the certificate")
+			return nil, false
+		}
+	}
+	return pk, true
+}
+
+// provePossession checks that proof answers a challenge issued to r's
+// session with a signature by pk, as a sign-in with pk would. On failure it
+// writes the error response and returns false.
+func (a *app) provePossession(w http.ResponseWriter, r *http.Request, proof *dto.ChallengeResponse, pk ed25519.PublicKey) bool {
+	if proof == nil {
+		writeError(w, http.StatusUnauthorized, "proof_required", "enrollment needs a challenge response signed by the key")
+		return false
+	}
+	session, sErr := a.postedSession(r)
+	if sErr != nil {
+		writeError(w, sErr.status, sErr.code, sErr.message)
+		return false
+	}
+	signer, sErr := a.verifyChallengeResponse(*proof, session)
+	if sErr != nil {
+		sErr = a.disclosed(sErr)
+		writeError(w, sErr.status, sErr.code, sErr.message)
+		return false
+	}
+	if !pk.Equal(ed25519.PublicKey(signer)) {
+		writeError(w, http.StatusUnauthorized, "proof_key_mismatch", "proof is signed by a different key")
+		return false
+	}
+	return true
+}
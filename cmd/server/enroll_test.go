@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	b64 "encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/client"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// postEnroll enrolls priv's public key under identity, proving possession
+// by answering a fresh challenge with priv.
+func postEnroll(t *testing.T, a *app, identity string, priv ed25519.PrivateKey, token string) *http.Response {
+	t.Helper()
+	proof := signChallengeWithKey(t, getChallenge(t, a).Message, priv)
+	enrollment := dto.Enrollment{Identity: identity, PublicKey: proof.PublicKey, Proof: &proof}
+	return postEnrollment(t, a, enrollment, token)
+}
+
+func postEnrollment(t *testing.T, a *app, enrollment dto.Enrollment, token string) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(enrollment)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.enroll(w, req)
+	return w.Result()
+}
+
+func TestEnroll(t *testing.T) {
+	a := newTestApp(t)
+	a.requireEnrollment = true
+	_, priv := testutil.DeterministicEd25519(17)
+	var aliceToken string
+
+	t.Run("Test enroll then sign in", func(t *testing.T) {
+		res := postEnroll(t, a, "alice", priv, "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status code to be 201 got %d", res.StatusCode)
+		}
+
+		res2 := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res2.StatusCode)
+		}
+		aliceToken = decodeToken(t, res2)
+		claims, err := jws.Decode(aliceToken)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Sub != "alice" {
+			t.Errorf("expected sub to be alice got %s", claims.Sub)
+		}
+	})
+
+	t.Run("Test unenrolled key can't sign in", func(t *testing.T) {
+		res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "unknown_key" {
+			t.Errorf("expected error code to be unknown_key got %s", code)
+		}
+	})
+
+	t.Run("Test key enrolled twice", func(t *testing.T) {
+		res := postEnroll(t, a, "bob", priv, "")
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "already_enrolled" {
+			t.Errorf("expected error code to be already_enrolled got %s", code)
+		}
+	})
+
+	t.Run("Test another key for a taken identity", func(t *testing.T) {
+		_, priv2 := testutil.DeterministicEd25519(18)
+		res := postEnroll(t, a, "alice", priv2, "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+
+		res2 := postEnroll(t, a, "alice", priv2, aliceToken)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusCreated {
+			t.Errorf("expected status code to be 201 got %d", res2.StatusCode)
+		}
+	})
+
+	t.Run("Test token for another identity", func(t *testing.T) {
+		_, priv3 := testutil.DeterministicEd25519(19)
+		postEnroll(t, a, "carol", priv3, "").Body.Close()
+		_, priv4 := testutil.DeterministicEd25519(20)
+		res := postEnroll(t, a, "carol", priv4, aliceToken)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "identity_mismatch" {
+			t.Errorf("expected error code to be identity_mismatch got %s", code)
+		}
+	})
+
+	victimPub, _ := testutil.DeterministicEd25519(54)
+	_, attackerPriv := testutil.DeterministicEd25519(55)
+	forgeries := []struct {
+		name  string
+		proof func(message string) *dto.ChallengeResponse
+		code  string
+	}{
+		{"Test enrollment without a proof", func(string) *dto.ChallengeResponse {
+			return nil
+		}, "proof_required"},
+		{"Test proof signed by another key", func(message string) *dto.ChallengeResponse {
+			proof := signChallengeWithKey(t, message, attackerPriv)
+			return &proof
+		}, "proof_key_mismatch"},
+		{"Test proof forged for the key", func(message string) *dto.ChallengeResponse {
+			proof := signChallengeWithKey(t, message, attackerPriv)
+			proof.PublicKey = dto.EncodeBinary(victimPub)
+			return &proof
+		}, "invalid_signature"},
+		{"Test proof for a challenge the server didn't issue", func(string) *dto.ChallengeResponse {
+			proof := signChallengeWithKey(t, "not issued by the server", attackerPriv)
+			proof.PublicKey = dto.EncodeBinary(victimPub)
+			return &proof
+		}, "invalid_signature"},
+	}
+	for _, tt := range forgeries {
+		t.Run(tt.name, func(t *testing.T) {
+			enrollment := dto.Enrollment{Identity: "mallory", Proof: tt.proof(getChallenge(t, a).Message)}
+			enrollment.SetPublicKey(victimPub)
+			res := postEnrollment(t, a, enrollment, "")
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+			}
+			if code := errorCode(t, res); code != tt.code {
+				t.Errorf("expected error code to be %s got %s", tt.code, code)
+			}
+			if _, ok := a.enrollment.Identity(victimPub); ok {
+				t.Errorf("expected the key not to be enrolled")
+			}
+		})
+	}
+}
+
+func TestEnrollBindSession(t *testing.T) {
+	a := newTestApp(t)
+	a.requireEnrollment = true
+	a.bindSession = true
+	srv := httptest.NewTLSServer(newServer(Config{}, a, newReadiness(a.signingProbe)).Handler)
+	defer srv.Close()
+	// The jar sends cookies only where their Path and Secure let a
+	// browser send them.
+	jar, _ := cookiejar.New(nil)
+	httpClient := srv.Client()
+	httpClient.Jar = jar
+	c := client.New(srv.URL)
+	c.HTTPClient = httpClient
+
+	pub, priv := testutil.DeterministicEd25519(56)
+	err := c.Enroll(context.Background(), "erin", priv, pub)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	token, err := c.SignIn(context.Background(), priv, pub)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	claims, _ := jws.Decode(token)
+	if claims.Sub != "erin" {
+		t.Errorf("expected sub to be erin got %s", claims.Sub)
+	}
+}
+
+// newDeviceCert returns a PEM certificate for pub signed by caKey, or
+// self-signed when ca is nil.
+func newDeviceCert(t *testing.T, pub ed25519.PublicKey, ca *x509.Certificate, caKey ed25519.PrivateKey) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "device"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  ca == nil,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if ca == nil {
+		ca = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, pub, caKey)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestEnrollCertificate(t *testing.T) {
+	caPub, caKey := testutil.DeterministicEd25519(21)
+	caBlock, _ := pem.Decode(newDeviceCert(t, caPub, nil, caKey))
+	ca, _ := x509.ParseCertificate(caBlock.Bytes)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	postCert := func(t *testing.T, a *app, enrollment dto.Enrollment) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(enrollment)
+		req := httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		a.enroll(w, req)
+		return w.Result()
+	}
+
+	t.Run("Test certificate from the CA enrolls its key", func(t *testing.T) {
+		a := newTestApp(t)
+		a.requireEnrollment = true
+		a.enrollCA = roots
+		pub, priv := testutil.DeterministicEd25519(22)
+		proof := signChallengeWithKey(t, getChallenge(t, a).Message, priv)
+		res := postCert(t, a, dto.Enrollment{Identity: "alice", Certificate: string(newDeviceCert(t, pub, ca, caKey)), Proof: &proof})
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status code to be 201 got %d", res.StatusCode)
+		}
+
+		res2 := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res2.StatusCode)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		enrollCA *x509.CertPool
+		body     func(pub ed25519.PublicKey, priv ed25519.PrivateKey) dto.Enrollment
+		code     string
+	}{
+		{"Test self-signed certificate", roots, func(pub ed25519.PublicKey, priv ed25519.PrivateKey) dto.Enrollment {
+			return dto.Enrollment{Identity: "bob", Certificate: string(newDeviceCert(t, pub, nil, priv))}
+		}, "invalid_certificate"},
+		{"Test raw key when a CA is required", roots, func(pub ed25519.PublicKey, _ ed25519.PrivateKey) dto.Enrollment {
+			e := dto.Enrollment{Identity: "bob"}
+			e.SetPublicKey(pub)
+			return e
+		}, "certificate_required"},
+		{"Test public key other than the certified one", roots, func(pub ed25519.PublicKey, _ ed25519.PrivateKey) dto.Enrollment {
+			other, _ := testutil.DeterministicEd25519(23)
+			e := dto.Enrollment{Identity: "bob", Certificate: string(newDeviceCert(t, pub, ca, caKey))}
+			e.SetPublicKey(other)
+			return e
+		}, "invalid_public_key"},
+		{"Test certificate without a CA", nil, func(pub ed25519.PublicKey, _ ed25519.PrivateKey) dto.Enrollment {
+			return dto.Enrollment{Identity: "bob", Certificate: string(newDeviceCert(t, pub, ca, caKey))}
+		}, "invalid_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			a.enrollCA = tt.enrollCA
+			pub, priv := testutil.DeterministicEd25519(24)
+			res := postCert(t, a, tt.body(pub, priv))
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusBadRequest {
+				t.Errorf("expected status code to be 400 got %d", res.StatusCode)
+			}
+			if code := errorCode(t, res); code != tt.code {
+				t.Errorf("expected error code to be %s got %s", tt.code, code)
+			}
+		})
+	}
+}
+
+// newIntermediateCert returns an intermediate CA certificate for pub signed
+// by parentKey, with the distribution points, policies and name constraints
+// a production intermediate carries.
+func newIntermediateCert(t *testing.T, name string, pub ed25519.PublicKey, parent *x509.Certificate, parentKey ed25519.PrivateKey) (*x509.Certificate, []byte) {
+	t.Helper()
+	var domains []string
+	for i := 0; i < 32; i++ {
+		domains = append(domains, fmt.Sprintf("devices-%02d.%s.example.com", i, name))
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"Example Devices"}, Country: []string{"AR"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		CRLDistributionPoints: []string{"http://crl.example.com/" + name + ".crl"},
+		OCSPServer:            []string{"http://ocsp.example.com/" + name},
+		IssuingCertificateURL: []string{"http://ca.example.com/" + name + ".crt"},
+		PolicyIdentifiers:     []asn1.ObjectIdentifier{{2, 23, 140, 1, 2, 1}},
+		PermittedDNSDomains:   domains,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, parentKey)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestEnrollCertificateChain(t *testing.T) {
+	caPub, caKey := testutil.DeterministicEd25519(59)
+	caBlock, _ := pem.Decode(newDeviceCert(t, caPub, nil, caKey))
+	ca, _ := x509.ParseCertificate(caBlock.Bytes)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	firstPub, firstKey := testutil.DeterministicEd25519(60)
+	first, firstPEM := newIntermediateCert(t, "first", firstPub, ca, caKey)
+	secondPub, secondKey := testutil.DeterministicEd25519(61)
+	second, secondPEM := newIntermediateCert(t, "second", secondPub, first, firstKey)
+
+	a := newTestApp(t)
+	a.requireEnrollment = true
+	a.enrollCA = roots
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+
+	pub, priv := testutil.DeterministicEd25519(62)
+	chain := append(newDeviceCert(t, pub, second, secondKey), secondPEM...)
+	chain = append(chain, firstPEM...)
+	proof := signChallengeWithKey(t, getChallenge(t, a).Message, priv)
+	body, _ := json.Marshal(dto.Enrollment{Identity: "alice", Certificate: string(chain), Proof: &proof})
+	if int64(len(body)) <= signInLimits.MaxBodyBytes {
+		t.Fatalf("expected the body to be larger than %d bytes got %d", signInLimits.MaxBodyBytes, len(body))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/enroll", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status code to be 201 got %d", res.StatusCode)
+	}
+
+	res2 := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+	defer res2.Body.Close()
+	if res2.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res2.StatusCode)
+	}
+}
+
+func TestEnrolledKeys(t *testing.T) {
+	a := newTestApp(t)
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+	signInWithKey := func(t *testing.T, priv ed25519.PrivateKey) string {
+		t.Helper()
+		res := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		defer res.Body.Close()
+		return decodeToken(t, res)
+	}
+	adminPub, adminPriv := testutil.DeterministicEd25519(43)
+	a.keyScopes[b64.StdEncoding.EncodeToString(adminPub)] = adminScope
+	adminToken := signInWithKey(t, adminPriv)
+	_, userPriv := testutil.DeterministicEd25519(44)
+	userToken := signInWithKey(t, userPriv)
+
+	pub1, _ := testutil.DeterministicEd25519(45)
+	pub2, _ := testutil.DeterministicEd25519(46)
+	a.enrollment.Enroll("alice", pub1)
+	a.enrollment.Enroll("alice", pub2)
+
+	getEnrolled := func(t *testing.T, identity, token string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/enroll/"+identity, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	t.Run("Test both keys are listed", func(t *testing.T) {
+		res := getEnrolled(t, "alice", adminToken)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		list := dto.EnrolledKeys{}
+		json.NewDecoder(res.Body).Decode(&list)
+		if list.Identity != "alice" || len(list.Keys) != 2 {
+			t.Fatalf("expected alice's 2 keys got %+v", list)
+		}
+		for i, pub := range []ed25519.PublicKey{pub1, pub2} {
+			if list.Keys[i].PublicKey != dto.EncodeBinary(pub) {
+				t.Errorf("expected key %d to be %s got %s", i, dto.EncodeBinary(pub), list.Keys[i].PublicKey)
+			}
+			if list.Keys[i].EnrolledAt == 0 {
+				t.Errorf("expected key %d to have an enrollment time", i)
+			}
+		}
+	})
+
+	t.Run("Test unknown identity has no keys", func(t *testing.T) {
+		res := getEnrolled(t, "nobody", adminToken)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		body := map[string]interface{}{}
+		json.NewDecoder(res.Body).Decode(&body)
+		if keys, ok := body["keys"].([]interface{}); !ok || len(keys) != 0 {
+			t.Errorf("expected an empty keys list got %v", body["keys"])
+		}
+	})
+
+	t.Run("Test non-admin is rejected", func(t *testing.T) {
+		res := getEnrolled(t, "alice", userToken)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status code to be 403 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "insufficient_scope" {
+			t.Errorf("expected code insufficient_scope got %s", code)
+		}
+	})
+
+	t.Run("Test missing token is rejected", func(t *testing.T) {
+		res := getEnrolled(t, "alice", "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+	})
+}
+
+func deleteEnroll(t *testing.T, a *app, identity string, pub ed25519.PublicKey, token string) *http.Response {
+	t.Helper()
+	body, err := json.Marshal(dto.Enrollment{Identity: identity, PublicKey: dto.EncodeBinary(pub)})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodDelete, "/enroll", bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.enroll(w, req)
+	return w.Result()
+}
+
+// backdatedToken mints a token for sub with scope issued a second ago, so a
+// subject revocation made now covers it.
+func backdatedToken(t *testing.T, a *app, sub, scope string) string {
+	t.Helper()
+	iss, err := issuer(a.keys.Active())
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	claims, err := jws.NewClaimSet().Issuer(iss).Subject(sub).Scope(scope).TTL(a.tokenTTL).Build()
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	claims.Iat--
+	claims.Nbf--
+	token, err := a.signClaims(context.Background(), claims)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return token
+}
+
+func TestUnenroll(t *testing.T) {
+	a := newTestApp(t)
+	a.requireEnrollment = true
+	signInWithKey := func(t *testing.T, priv ed25519.PrivateKey) string {
+		t.Helper()
+		res := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, priv))
+		defer res.Body.Close()
+		return decodeToken(t, res)
+	}
+	pub, priv := testutil.DeterministicEd25519(47)
+	lostPub, lostPriv := testutil.DeterministicEd25519(48)
+	a.enrollment.Enroll("alice", pub)
+	a.enrollment.Enroll("alice", lostPub)
+	token := backdatedToken(t, a, "alice", "")
+	adminPub, adminPriv := testutil.DeterministicEd25519(52)
+	a.enrollment.Enroll("admin", adminPub)
+	a.keyScopes[b64.StdEncoding.EncodeToString(adminPub)] = adminScope
+	adminToken := signInWithKey(t, adminPriv)
+
+	t.Run("Test token for another identity", func(t *testing.T) {
+		bobPub, _ := testutil.DeterministicEd25519(49)
+		a.enrollment.Enroll("bob", bobPub)
+		res := deleteEnroll(t, a, "bob", bobPub, token)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "identity_mismatch" {
+			t.Errorf("expected code identity_mismatch got %s", code)
+		}
+	})
+
+	t.Run("Test missing token", func(t *testing.T) {
+		res := deleteEnroll(t, a, "alice", pub, "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test unenrolled key can't sign in", func(t *testing.T) {
+		res := deleteEnroll(t, a, "alice", lostPub, token)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		signIn := postSignIn(t, a, signChallengeWithKey(t, getChallenge(t, a).Message, lostPriv))
+		defer signIn.Body.Close()
+		if signIn.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", signIn.StatusCode)
+		}
+		if code := errorCode(t, signIn); code != "unknown_key" {
+			t.Errorf("expected code unknown_key got %s", code)
+		}
+	})
+
+	t.Run("Test identity's tokens are revoked", func(t *testing.T) {
+		res := postVerify(t, a, token)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "token_revoked" {
+			t.Errorf("expected code token_revoked got %s", code)
+		}
+		res2 := postVerify(t, a, adminToken)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Errorf("expected another identity's token to verify got %d", res2.StatusCode)
+		}
+	})
+
+	t.Run("Test token minted right after unenrolling verifies", func(t *testing.T) {
+		res := postVerify(t, a, signInWithKey(t, priv))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test key that isn't enrolled", func(t *testing.T) {
+		res := deleteEnroll(t, a, "alice", lostPub, adminToken)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Errorf("expected status code to be 404 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "not_enrolled" {
+			t.Errorf("expected code not_enrolled got %s", code)
+		}
+	})
+
+	t.Run("Test identity stays claimed without keys", func(t *testing.T) {
+		res := deleteEnroll(t, a, "alice", pub, adminToken)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		_, newPriv := testutil.DeterministicEd25519(53)
+		res2 := postEnroll(t, a, "alice", newPriv, "")
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res2.StatusCode)
+		}
+		res3 := postEnroll(t, a, "alice", newPriv, adminToken)
+		defer res3.Body.Close()
+		if res3.StatusCode != http.StatusCreated {
+			t.Errorf("expected an admin to re-open the identity got %d", res3.StatusCode)
+		}
+	})
+}
+
+func TestUnenrollWithoutEnrollment(t *testing.T) {
+	a := newTestApp(t)
+	pub, _ := testutil.DeterministicEd25519(58)
+	a.enrollment.Enroll("carol", pub)
+	keyToken := backdatedToken(t, a, b64.StdEncoding.EncodeToString(pub), "")
+	adminToken := backdatedToken(t, a, "admin", adminScope)
+
+	res := deleteEnroll(t, a, "carol", pub, adminToken)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	t.Run("Test tokens naming the key are revoked", func(t *testing.T) {
+		res := postVerify(t, a, keyToken)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "token_revoked" {
+			t.Errorf("expected code token_revoked got %s", code)
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// expiredStore refuses every challenge as expired, as a store does once a
+// challenge outlives its TTL.
+type expiredStore struct {
+	challenge.Store
+}
+
+func (expiredStore) ConsumeReason(string) (challenge.Binding, error) {
+	return challenge.Binding{}, challenge.ErrExpiredChallenge
+}
+
+// captureLog sends the default logger's output to a buffer for the rest of
+// the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return buf
+}
+
+func TestVerificationErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		expire bool
+		// response answers the live challenge message.
+		response func(message string) dto.ChallengeResponse
+		status   int
+		code     string
+	}{
+		{"Test unknown challenge", false, func(string) dto.ChallengeResponse {
+			return signChallenge(t, "unknown")
+		}, http.StatusBadRequest, "invalid_challenge"},
+		{"Test expired challenge", true, func(message string) dto.ChallengeResponse {
+			return signChallenge(t, message)
+		}, http.StatusBadRequest, "expired_challenge"},
+		{"Test malformed key", false, func(message string) dto.ChallengeResponse {
+			response := signChallenge(t, message)
+			response.PublicKey = "not a key"
+			return response
+		}, http.StatusBadRequest, "invalid_public_key"},
+		{"Test malformed signature", false, func(message string) dto.ChallengeResponse {
+			response := signChallenge(t, message)
+			response.Signature = "not a signature"
+			return response
+		}, http.StatusBadRequest, "malformed_signature"},
+		{"Test signature mismatch", false, func(message string) dto.ChallengeResponse {
+			response := signChallenge(t, "another message")
+			response.Message = message
+			return response
+		}, http.StatusUnauthorized, "invalid_signature"},
+	}
+
+	// postFailure answers a fresh challenge from a with response, expiring
+	// it first if expire.
+	postFailure := func(t *testing.T, a *app, expire bool, response func(string) dto.ChallengeResponse) *http.Response {
+		t.Helper()
+		message := getChallenge(t, a).Message
+		if expire {
+			a.challenges = expiredStore{a.challenges}
+		}
+		return postSignIn(t, a, response(message))
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" in debug mode", func(t *testing.T) {
+			logs := captureLog(t)
+			a := newTestApp(t)
+			a.detailedErrors = true
+			res := postFailure(t, a, tt.expire, tt.response)
+			defer res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("expected status code to be %d got %d", tt.status, res.StatusCode)
+			}
+			if code := errorCode(t, res); code != tt.code {
+				t.Errorf("expected code %s got %s", tt.code, code)
+			}
+			if !strings.Contains(logs.String(), "code="+tt.code) {
+				t.Errorf("expected %s to be logged got %q", tt.code, logs.String())
+			}
+		})
+
+		t.Run(tt.name+" in production mode", func(t *testing.T) {
+			logs := captureLog(t)
+			a := newTestApp(t)
+			res := postFailure(t, a, tt.expire, tt.response)
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+			}
+			body := dto.ErrorResponse{}
+			json.NewDecoder(res.Body).Decode(&body)
+			if body.Code != "invalid_signature" || body.Message != "signature does not verify" {
+				t.Errorf("expected the generic failure got %s: %s", body.Code, body.Message)
+			}
+			if !strings.Contains(logs.String(), "code="+tt.code) {
+				t.Errorf("expected %s to be logged got %q", tt.code, logs.String())
+			}
+		})
+	}
+
+	t.Run("Test other failures are not collapsed", func(t *testing.T) {
+		a := newTestApp(t)
+		response := signChallenge(t, getChallenge(t, a).Message)
+		response.Timestamp = 1
+		res := postSignIn(t, a, response)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "stale_timestamp" {
+			t.Errorf("expected code stale_timestamp got %s", code)
+		}
+	})
+}
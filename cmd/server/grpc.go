@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/audit"
+	"github.com/martinsaporiti/ed25519-poc/internal/authpb"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// authServer serves the sign-in flow over gRPC with the same challenge
+// store, verification and minting as the HTTP handlers. Tokens always come
+// back in the response, whatever -token-delivery says.
+type authServer struct {
+	authpb.UnimplementedAuthServer
+	a *app
+}
+
+// newGRPCServer returns a gRPC server with the Auth service registered.
+func newGRPCServer(a *app) *grpc.Server {
+	s := grpc.NewServer()
+	authpb.RegisterAuthServer(s, &authServer{a: a})
+	return s
+}
+
+// GetChallenge issues a challenge as GET /signIn does.
+func (s *authServer) GetChallenge(ctx context.Context, req *authpb.GetChallengeRequest) (*authpb.Challenge, error) {
+	ch, sErr := s.a.issueChallenge(challenge.Binding{PublicKey: req.GetPublicKey()}, req.GetX25519PublicKey())
+	if sErr != nil {
+		return nil, sErr.grpcStatus()
+	}
+	return &authpb.Challenge{
+		Message:            ch.Message,
+		ExpiresAt:          ch.ExpiresAt,
+		TtlSeconds:         int32(ch.TTLSeconds),
+		Digest:             ch.Digest,
+		ServerSignature:    ch.ServerSignature,
+		Kid:                ch.Kid,
+		EphemeralPublicKey: ch.EphemeralPublicKey,
+		Nonce:              ch.Nonce,
+		Ciphertext:         ch.Ciphertext,
+	}, nil
+}
+
+// SignIn exchanges a signed challenge for a token as POST /signIn does, and
+// is audited the same way.
+func (s *authServer) SignIn(ctx context.Context, req *authpb.ChallengeResponse) (*authpb.Token, error) {
+	event := audit.AuthEvent{Time: time.Now(), Result: audit.ResultSuccess}
+	if p, ok := peer.FromContext(ctx); ok {
+		event.ClientIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(event.ClientIP); err == nil {
+			event.ClientIP = host
+		}
+	}
+	defer func() { s.a.audit.LogSignIn(event) }()
+
+	body := dto.ChallengeResponse{
+		Signature:  req.GetSignature(),
+		Message:    req.GetMessage(),
+		PublicKey:  req.GetPublicKey(),
+		ClientData: req.GetClientData(),
+		Timestamp:  req.GetTimestamp(),
+		Digest:     req.GetDigest(),
+	}
+	token, sErr := s.a.completeSignIn(ctx, body, "", &event)
+	if sErr != nil {
+		event.Result = sErr.code
+		return nil, s.a.disclosed(sErr).grpcStatus()
+	}
+	return &authpb.Token{Token: token}, nil
+}
+
+// grpcStatus returns e as a gRPC status whose code corresponds to e's HTTP
+// status. The message keeps e's error code, so callers can still tell, say,
+// an expired challenge from a bad signature.
+func (e *signInError) grpcStatus() error {
+	code := codes.Unknown
+	switch e.status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusInternalServerError:
+		code = codes.Internal
+	}
+	return status.Errorf(code, "%s: %s", e.code, e.message)
+}
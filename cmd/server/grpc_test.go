@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/authpb"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newGRPCClient serves a's Auth service on an in-process connection and
+// returns a client for it.
+func newGRPCClient(t *testing.T, a *app) authpb.AuthClient {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	server := newGRPCServer(a)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return authpb.NewAuthClient(conn)
+}
+
+func TestGRPCSignIn(t *testing.T) {
+	a := newTestApp(t)
+	a.detailedErrors = true
+	client := newGRPCClient(t, a)
+	ctx := context.Background()
+
+	t.Run("Test challenge and response", func(t *testing.T) {
+		ch, err := client.GetChallenge(ctx, &authpb.GetChallengeRequest{})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if ch.Message == "" || ch.ExpiresAt == 0 {
+			t.Errorf("expected a message and expiry got %v", ch)
+		}
+
+		resp := signChallenge(t, ch.Message)
+		token, err := client.SignIn(ctx, &authpb.ChallengeResponse{
+			Signature: resp.Signature,
+			Message:   resp.Message,
+			PublicKey: resp.PublicKey,
+		})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		claims, err := a.verifyToken(token.Token)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Sub != resp.PublicKey {
+			t.Errorf("expected sub to be %s got %s", resp.PublicKey, claims.Sub)
+		}
+	})
+
+	t.Run("Test HTTP challenge answered over gRPC", func(t *testing.T) {
+		resp := signChallenge(t, getChallenge(t, a).Message)
+		token, err := client.SignIn(ctx, &authpb.ChallengeResponse{
+			Signature: resp.Signature,
+			Message:   resp.Message,
+			PublicKey: resp.PublicKey,
+		})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		_, err = jws.Decode(token.Token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test replayed challenge", func(t *testing.T) {
+		ch, _ := client.GetChallenge(ctx, &authpb.GetChallengeRequest{})
+		resp := signChallenge(t, ch.Message)
+		req := &authpb.ChallengeResponse{Signature: resp.Signature, Message: resp.Message, PublicKey: resp.PublicKey}
+		client.SignIn(ctx, req)
+		_, err := client.SignIn(ctx, req)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("expected code to be %v got %v", codes.InvalidArgument, err)
+		}
+	})
+
+	t.Run("Test bad signature", func(t *testing.T) {
+		ch, _ := client.GetChallenge(ctx, &authpb.GetChallengeRequest{})
+		resp := signChallenge(t, ch.Message)
+		_, otherKey := testutil.DeterministicEd25519(41)
+		other := signChallengeWithKey(t, ch.Message, otherKey)
+		_, err := client.SignIn(ctx, &authpb.ChallengeResponse{Signature: other.Signature, Message: resp.Message, PublicKey: resp.PublicKey})
+		if status.Code(err) != codes.Unauthenticated {
+			t.Errorf("expected code to be %v got %v", codes.Unauthenticated, err)
+		}
+	})
+}
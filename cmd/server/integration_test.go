@@ -0,0 +1,90 @@
+//go:build integration
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/client"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// TestIntegration runs the server as main configures it, with default flags,
+// on an ephemeral port and signs in through the client over real HTTP. Run it
+// with go test -tags integration ./cmd/server.
+func TestIntegration(t *testing.T) {
+	cfg, err := parseConfig([]string{"-addr", "127.0.0.1:0"}, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	signingKey, header, err := loadSigningKey(cfg.SigningKey)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	challenges := challenge.NewChallengeStore(challenge.DefaultTTL)
+	defer challenges.Close()
+	a := newApp(challenges, signingKey, header)
+	server := newServer(cfg, a, newReadiness(a.signingProbe))
+
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	shutdown := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(server, ln, "", "", shutdown)
+	}()
+	c := client.New("http://" + ln.Addr().String())
+
+	t.Run("Test enroll, sign in and validate", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(25)
+		err := c.Enroll(context.Background(), "integration", priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		token, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = jws.Validate(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		claims, _ := jws.Decode(token)
+		if claims.Sub != "integration" {
+			t.Errorf("expected sub to be integration got %s", claims.Sub)
+		}
+	})
+
+	t.Run("Test signing with a mismatched key", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(26)
+		_, other := testutil.DeterministicEd25519(27)
+		err := c.Enroll(context.Background(), "mismatched", priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		_, err = c.SignIn(context.Background(), other, pub)
+		statusErr := &client.StatusError{}
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected a StatusError got %v", err)
+		}
+		if statusErr.StatusCode != http.StatusUnauthorized || statusErr.Code != "invalid_signature" {
+			t.Errorf("expected 401 invalid_signature got %d %s", statusErr.StatusCode, statusErr.Code)
+		}
+	})
+
+	shutdown <- syscall.SIGTERM
+	err = <-done
+	if !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("expected error to be %v got %v", http.ErrServerClosed, err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/introspect"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// introspector answers POST /introspect. Results, including inactive ones,
+// are cached so repeated calls with the same token skip verification; an
+// active one is still checked against revocations on every call.
+type introspector struct {
+	cache     *introspect.Cache
+	validate  func(token string) (*jws.ClaimSet, error)
+	isRevoked func(claims *jws.ClaimSet) bool
+	// maxBodyBytes caps the request body; zero means no limit.
+	maxBodyBytes int64
+}
+
+// newIntrospector returns an introspector that checks tokens with
+// verifyToken, so only tokens signed by one of a's trusted keys are active.
+func (a *app) newIntrospector(cfg introspect.Config) *introspector {
+	return &introspector{
+		cache:        introspect.NewCache(cfg),
+		validate:     a.verifyToken,
+		isRevoked:    a.isRevoked,
+		maxBodyBytes: a.maxBodyBytes,
+	}
+}
+
+func (in *introspector) introspect(token string) introspect.Result {
+	if res, ok := in.cache.Get(token); ok {
+		// A token revoked since it was cached must not stay active until
+		// the entry expires.
+		if res.Active && in.isRevoked(res.Claims) {
+			logRevoked(token)
+			res = introspect.Result{}
+			in.cache.Put(token, res)
+		}
+		return res
+	}
+
+	res := introspect.Result{}
+	claims, err := in.validate(token)
+	if err == nil && (claims.Exp == 0 || claims.Exp > time.Now().Unix()) {
+		res.Active = true
+		res.Claims = claims
+	}
+	in.cache.Put(token, res)
+	return res
+}
+
+func (in *introspector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.Jws{}
+	err := decodeJSON(w, r, &body, in.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling introspection request")
+		return
+	}
+
+	res := in.introspect(body.Token)
+	out := dto.Introspection{
+		Active: res.Active,
+	}
+	if res.Active {
+		out.Iss = res.Claims.Iss
+		out.Sub = res.Claims.Sub
+		out.Aud = res.Claims.Aud
+		out.Scope = res.Claims.Scope
+		out.Exp = res.Claims.Exp
+		out.Iat = res.Claims.Iat
+	}
+
+	json, err := json.Marshal(out)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling introspection")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(json)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/introspect"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// mintToken returns a token that jws.Validate accepts, carrying its own
+// public key in iss, with the given exp. The key is not the server's, so the
+// server must refuse it.
+func mintToken(t *testing.T, exp int64) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	pub, err := json.Marshal(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	claims := &jws.ClaimSet{
+		Iss: base64.StdEncoding.EncodeToString(pub),
+		Sub: "device",
+		Exp: exp,
+		Iat: exp - int64(time.Hour.Seconds()),
+	}
+	token, err := jws.Encode(&jws.Header{Algorithm: "RS256", Typ: "JWT"}, claims, key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return token
+}
+
+// serverToken returns a token for sub "device" signed with a's signing key,
+// with the given exp.
+func serverToken(t *testing.T, a *app, exp int64) string {
+	t.Helper()
+	token, err := a.signClaims(context.Background(), &jws.ClaimSet{
+		Sub: "device",
+		Exp: exp,
+		Iat: exp - int64(time.Hour.Seconds()),
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return token
+}
+
+func postIntrospect(t *testing.T, in *introspector, token string) dto.Introspection {
+	t.Helper()
+	reqBody, _ := json.Marshal(dto.Jws{Token: token})
+	req := httptest.NewRequest(http.MethodPost, "/introspect", bytes.NewBuffer(reqBody))
+	w := httptest.NewRecorder()
+	in.ServeHTTP(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	body := dto.Introspection{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+	return body
+}
+
+func TestIntrospect(t *testing.T) {
+	a := newTestApp(t)
+	newCountingIntrospector := func(calls *int) *introspector {
+		in := a.newIntrospector(introspect.DefaultConfig)
+		in.validate = func(token string) (*jws.ClaimSet, error) {
+			*calls++
+			return a.verifyToken(token)
+		}
+		return in
+	}
+
+	t.Run("Test repeated introspect is served from cache", func(t *testing.T) {
+		calls := 0
+		in := newCountingIntrospector(&calls)
+		token := serverToken(t, a, time.Now().Add(time.Hour).Unix())
+
+		for i := 0; i < 3; i++ {
+			body := postIntrospect(t, in, token)
+			if !body.Active {
+				t.Errorf("expected token to be active")
+			}
+			if body.Sub != "device" {
+				t.Errorf("expected sub to be device got %s", body.Sub)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("expected token to be verified once got %d", calls)
+		}
+	})
+
+	t.Run("Test garbage token is negatively cached", func(t *testing.T) {
+		calls := 0
+		in := newCountingIntrospector(&calls)
+
+		for i := 0; i < 3; i++ {
+			body := postIntrospect(t, in, "not.a.token")
+			if body.Active {
+				t.Errorf("expected token to be inactive")
+			}
+		}
+		if calls != 1 {
+			t.Errorf("expected token to be verified once got %d", calls)
+		}
+	})
+
+	t.Run("Test expired token is inactive", func(t *testing.T) {
+		calls := 0
+		in := newCountingIntrospector(&calls)
+		token := serverToken(t, a, time.Now().Add(-time.Minute).Unix())
+
+		body := postIntrospect(t, in, token)
+		if body.Active {
+			t.Errorf("expected token to be inactive")
+		}
+		if body.Iss != "" {
+			t.Errorf("expected no claims for an inactive token got iss %s", body.Iss)
+		}
+	})
+
+	t.Run("Test token from a foreign key is inactive", func(t *testing.T) {
+		calls := 0
+		in := newCountingIntrospector(&calls)
+		body := postIntrospect(t, in, mintToken(t, time.Now().Add(time.Hour).Unix()))
+		if body.Active {
+			t.Errorf("expected token to be inactive")
+		}
+	})
+
+	t.Run("Test token from a rotated-out key stays active", func(t *testing.T) {
+		b := newTestApp(t)
+		token := serverToken(t, b, time.Now().Add(time.Hour).Unix())
+		pub, priv := testutil.DeterministicEd25519(51)
+		header, _ := jws.HeaderForKey(pub)
+		b.keys.Promote(priv, header)
+
+		body := postIntrospect(t, b.newIntrospector(introspect.DefaultConfig), token)
+		if !body.Active {
+			t.Errorf("expected token to be active")
+		}
+		res := postVerify(t, b, token)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test revoked token is inactive even when cached", func(t *testing.T) {
+		b := newTestApp(t)
+		in := b.newIntrospector(introspect.DefaultConfig)
+		token := signInToken(t, b)
+		if body := postIntrospect(t, in, token); !body.Active {
+			t.Fatalf("expected token to be active")
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/revoke", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		b.revoke(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected status code to be 204 got %d", w.Code)
+		}
+		if body := postIntrospect(t, in, token); body.Active {
+			t.Errorf("expected revoked token to be inactive")
+		}
+		if body := postIntrospect(t, b.newIntrospector(introspect.DefaultConfig), token); body.Active {
+			t.Errorf("expected revoked token to be inactive uncached")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// routeLimits bounds the request body size and handler run time of a route.
+// A zero field means no limit.
+type routeLimits struct {
+	MaxBodyBytes int64
+	Timeout      time.Duration
+}
+
+// defaultLimits applies to routes registered without limits of their own.
+var defaultLimits = routeLimits{
+	MaxBodyBytes: 64 << 10,
+	Timeout:      10 * time.Second,
+}
+
+// defaultMaxBodyBytes caps a JSON request body unless -max-body-bytes says
+// otherwise.
+const defaultMaxBodyBytes = 64 << 10
+
+// signInLimits fits a challenge response, which is a few hundred bytes.
+var signInLimits = routeLimits{
+	MaxBodyBytes: 4 << 10,
+	Timeout:      5 * time.Second,
+}
+
+// enrollLimits fits an -enroll-ca certificate chain, a few KiB per
+// certificate, plus the proof of possession.
+var enrollLimits = routeLimits{
+	MaxBodyBytes: 32 << 10,
+	Timeout:      5 * time.Second,
+}
+
+// wsLimits sets no limits on the WebSocket handshake: http.TimeoutHandler
+// can't hand over the connection, and the challenge's expiry bounds the
+// exchange instead. Frames are capped at -max-body-bytes.
+var wsLimits = routeLimits{}
+
+// handle registers h on mux for pattern, wrapped with the given limits or
+// defaultLimits when none are given.
+func handle(mux *http.ServeMux, pattern string, h http.Handler, limits ...routeLimits) {
+	l := defaultLimits
+	if len(limits) > 0 {
+		l = limits[0]
+	}
+	mux.Handle(pattern, withLimits(h, l))
+}
+
+// withLimits rejects bodies larger than l.MaxBodyBytes with 413 and cuts off
+// handlers running longer than l.Timeout with 503.
+func withLimits(h http.Handler, l routeLimits) http.Handler {
+	if l.Timeout > 0 {
+		h = http.TimeoutHandler(h, l.Timeout, "handler timeout")
+	}
+	if l.MaxBodyBytes <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > l.MaxBodyBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, "request_too_large", "request body too large")
+			return
+		}
+		// Bodies without a declared length are still cut off while reading.
+		r.Body = http.MaxBytesReader(w, r.Body, l.MaxBodyBytes)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the request body into v, reading at most maxBytes of it
+// when maxBytes is positive. Fields v doesn't have are rejected rather than
+// ignored.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, maxBytes int64) error {
+	body := r.Body
+	if maxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// writeDecodeError answers a decodeJSON error with 400 and msg, or with 413
+// naming the limit when the body was too large, as withLimits does for a
+// declared length over it.
+func writeDecodeError(w http.ResponseWriter, err error, msg string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request_too_large", fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, "invalid_request", msg)
+}
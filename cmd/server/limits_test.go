@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/introspect"
+)
+
+func TestRouteLimits(t *testing.T) {
+	uploadLimits := routeLimits{MaxBodyBytes: 8 << 20, Timeout: time.Minute}
+	upload := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mux := http.NewServeMux()
+	handle(mux, "/signIn", http.HandlerFunc(newTestApp(t).signIn), signInLimits)
+	handle(mux, "/upload", upload, uploadLimits)
+	handle(mux, "/slow", slow, routeLimits{Timeout: 10 * time.Millisecond})
+
+	large := bytes.Repeat([]byte("a"), 1<<20)
+
+	t.Run("Test sign in rejects large body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewReader(large))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code to be 413 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test sign in rejects large body without content length", func(t *testing.T) {
+		// Valid JSON up to the limit, so only its size can fail the decode.
+		padded := append([]byte("{"), bytes.Repeat([]byte(" "), 1<<20)...)
+		req := httptest.NewRequest(http.MethodPost, "/signIn", io.NopCloser(bytes.NewReader(padded)))
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code to be 413 got %d", w.Code)
+		}
+		if code := errorCode(t, w.Result()); code != "request_too_large" {
+			t.Errorf("expected error code to be request_too_large got %s", code)
+		}
+	})
+
+	t.Run("Test upload allows large body", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(large))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", w.Code)
+		}
+	})
+
+	t.Run("Test slow handler times out", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("expected status code to be 503 got %d", w.Code)
+		}
+	})
+}
+
+func TestDecodeJSON(t *testing.T) {
+	a := newTestApp(t)
+	a.maxBodyBytes = 1 << 10
+	handlers := map[string]http.HandlerFunc{
+		"/signIn":       a.signIn,
+		"/signIn/batch": a.signInBatch,
+		"/signIn/multi": a.signInMulti,
+		"/enroll":       a.enroll,
+		"/verify":       a.verify,
+	}
+	// Valid JSON for every handler but for its size.
+	large := "{" + strings.Repeat(" ", 2<<10) + "}"
+
+	for path, h := range handlers {
+		t.Run("Test "+path+" rejects a body over the limit", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(large))
+			w := httptest.NewRecorder()
+			h(w, req)
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("expected status code to be 413 got %d", w.Code)
+			}
+			body := dto.ErrorResponse{}
+			json.NewDecoder(w.Body).Decode(&body)
+			if body.Code != "request_too_large" || !strings.Contains(body.Message, "larger than 1024 bytes") {
+				t.Errorf("expected a request_too_large naming the limit got %s: %s", body.Code, body.Message)
+			}
+		})
+
+		t.Run("Test "+path+" rejects unknown fields", func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"unexpected":true}`))
+			w := httptest.NewRecorder()
+			h(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status code to be 400 got %d", w.Code)
+			}
+		})
+	}
+
+	t.Run("Test introspect rejects a body over the limit", func(t *testing.T) {
+		in := newTestApp(t).newIntrospector(introspect.DefaultConfig)
+		in.maxBodyBytes = 1 << 10
+		req := httptest.NewRequest(http.MethodPost, "/introspect", strings.NewReader(large))
+		w := httptest.NewRecorder()
+		in.ServeHTTP(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status code to be 413 got %d", w.Code)
+		}
+	})
+}
@@ -1,90 +1,558 @@
 package main
 
 import (
-	"crypto/ed25519"
+	"bufio"
+	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/sha256"
+	"crypto/rsa"
+	"crypto/x509"
 	b64 "encoding/base64"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/martinsaporiti/ed25519-poc/internal/audit"
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
 	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/enrollment"
 	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/revoke"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// defaultTokenTTL is how long minted tokens stay valid unless -token-ttl says
+// otherwise.
+const defaultTokenTTL = time.Hour
+
+// defaultTimestampWindow is how far a signed response's timestamp may be from
+// server time unless -timestamp-window says otherwise.
+const defaultTimestampWindow = 30 * time.Second
+
 func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/signIn", signIn)
-	fmt.Println("server started at port 3333")
-	err := http.ListenAndServe(":3333", mux)
+	cfg, err := parseConfig(os.Args[1:], os.Getenv)
+	if err != nil {
+		fmt.Printf("error parsing flags: %s\n", err)
+		os.Exit(2)
+	}
+
+	signingKey, header, err := loadSigningKey(cfg.SigningKey)
+	if err != nil {
+		fmt.Printf("error loading signing key: %s\n", err)
+		os.Exit(1)
+	}
+
+	challenges, err := newChallengeStore(cfg)
+	if err != nil {
+		fmt.Printf("error creating challenge store: %s\n", err)
+		os.Exit(1)
+	}
+	defer challenges.Close()
+	a := newApp(challenges, signingKey, header)
+	a.refreshWindow = cfg.RefreshWindow
+	a.tokenTTL = cfg.TokenTTL
+	a.signMode = cfg.SignMode
+	a.encryptChallenges = cfg.EncryptChallenges
+	a.clientDataOrigins = cfg.ClientDataOrigins
+	a.timestampWindow = cfg.TimestampWindow
+	a.requireEnrollment = cfg.RequireEnrollment
+	a.digests = cfg.Digests
+	a.signChallenges = cfg.SignChallenges
+	a.maxBodyBytes = cfg.MaxBodyBytes
+	a.tokenDelivery = cfg.TokenDelivery
+	a.bindSession = cfg.BindSession
+	a.difficulty = cfg.Difficulty
+	a.detailedErrors = cfg.Debug
+	a.keyScopes, err = loadKeyScopes(cfg.KeyScopes)
+	if err != nil {
+		fmt.Printf("error loading key scopes: %s\n", err)
+		os.Exit(1)
+	}
+	a.identities, err = loadIdentities(cfg.Identities)
+	if err != nil {
+		fmt.Printf("error loading identities: %s\n", err)
+		os.Exit(1)
+	}
+	if cfg.EnrollCA != "" {
+		a.enrollCA, err = enrollment.LoadCA(cfg.EnrollCA)
+		if err != nil {
+			fmt.Printf("error loading enrollment CA: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if cfg.AuditLog != "" {
+		auditLog, err := audit.NewFileLogger(cfg.AuditLog)
+		if err != nil {
+			fmt.Printf("error opening audit log: %s\n", err)
+			os.Exit(1)
+		}
+		defer auditLog.Close()
+		a.audit = auditLog
+	}
+
+	// Rotate keys without a restart: replace the key file and send SIGHUP.
+	if cfg.SigningKey != "" {
+		hangup := make(chan os.Signal, 1)
+		signal.Notify(hangup, syscall.SIGHUP)
+		defer signal.Stop(hangup)
+		go reloadOnHangup(a, cfg.SigningKey, hangup)
+	}
+
+	ready := newReadiness(a.signingProbe)
+	ready.check()
+	stop := make(chan struct{})
+	defer close(stop)
+	go ready.run(readinessInterval, stop)
+
+	server := newServer(cfg, a, ready)
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		fmt.Printf("error starting server: %s\n", err)
+		os.Exit(1)
+	}
+	if cfg.TLSCert != "" && cfg.TLSKey != "" {
+		fmt.Printf("server started at %s (TLS)\n", server.Addr)
+	} else {
+		fmt.Println("warning: no -tls-cert and -tls-key given, serving plain HTTP; challenge responses and tokens travel in cleartext")
+		fmt.Printf("server started at %s\n", server.Addr)
+	}
+
+	if cfg.GRPCAddr != "" {
+		grpcLn, err := net.Listen("tcp", cfg.GRPCAddr)
+		if err != nil {
+			fmt.Printf("error starting gRPC server: %s\n", err)
+			os.Exit(1)
+		}
+		grpcServer := newGRPCServer(a)
+		defer grpcServer.GracefulStop()
+		go grpcServer.Serve(grpcLn)
+		fmt.Printf("gRPC server started at %s\n", grpcLn.Addr())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	err = serve(server, ln, cfg.TLSCert, cfg.TLSKey, signals)
 	if errors.Is(err, http.ErrServerClosed) {
 		fmt.Printf("server closed\n")
 	} else if err != nil {
-		fmt.Printf("error starting server: %s\n", err)
+		fmt.Printf("error running server: %s\n", err)
 		os.Exit(1)
 	}
 
 }
 
-func signIn(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		w.Header().Set("Content-Type", "application/json")
+// shutdownTimeout bounds how long in-flight requests get to finish once a
+// shutdown signal arrives.
+const shutdownTimeout = 5 * time.Second
+
+// serve runs server on ln until it fails or a value arrives on shutdown, in
+// which case in-flight requests are drained before returning. With both
+// certFile and keyFile set it serves TLS, otherwise plain HTTP. A clean
+// shutdown returns http.ErrServerClosed.
+func serve(server *http.Server, ln net.Listener, certFile, keyFile string, shutdown <-chan os.Signal) error {
+	errc := make(chan error, 1)
+	go func() {
+		if certFile != "" && keyFile != "" {
+			errc <- server.ServeTLS(ln, certFile, keyFile)
+			return
+		}
+		errc <- server.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-shutdown:
+		fmt.Printf("received %s, shutting down\n", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		return err
+	}
+	return <-errc
+}
+
+// newServer builds the HTTP server for cfg with every route registered.
+func newServer(cfg Config, a *app, ready *readiness) *http.Server {
+	mux := http.NewServeMux()
+	handle(mux, "/signIn", http.HandlerFunc(a.signIn), signInLimits)
+	handle(mux, "/signIn/batch", http.HandlerFunc(a.signInBatch), batchLimits)
+	handle(mux, "/signIn/multi", http.HandlerFunc(a.signInMulti), multiLimits)
+	handle(mux, "/ws/signIn", a.wsSignIn(), wsLimits)
+	handle(mux, "/challenges", a.authorize(adminScope)(http.HandlerFunc(a.streamChallenges)), challengesLimits)
+	handle(mux, "/enroll", http.HandlerFunc(a.enroll), enrollLimits)
+	handle(mux, "/enroll/", a.authorize(adminScope)(http.HandlerFunc(a.enrolledKeys)))
+	handle(mux, "/refresh", http.HandlerFunc(a.refresh))
+	handle(mux, "/.well-known/jwks.json", http.HandlerFunc(a.jwks))
+	handle(mux, "/readyz", ready)
+	handle(mux, "/healthz", http.HandlerFunc(a.healthz))
+	handle(mux, "/metrics", http.HandlerFunc(a.metrics))
+	handle(mux, "/introspect", a.newIntrospector(cfg.Introspect))
+	handle(mux, "/verify", http.HandlerFunc(a.verify))
+	handle(mux, "/revoke", http.HandlerFunc(a.revoke))
+	handle(mux, "/me", a.authorize()(http.HandlerFunc(a.me)))
+	if cfg.Debug {
+		registerDebug(mux, a)
+	}
+	var handler http.Handler = logging(cors(cfg.CORSOrigins)(mux))
+	if cfg.H2C {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: cfg.IdleTimeout})
+	}
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// requestLogger returns the logger requests are logged to.
+var requestLogger = slog.Default
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack hands the connection over to the handler, as the WebSocket
+// handshake needs, when the underlying writer allows it.
+func (sr *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := sr.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer can't be hijacked")
+	}
+	sr.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+// Flush sends what has been written so far to the client, as streamed
+// responses need, when the underlying writer allows it.
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
-		var clave [32]byte
-		_, err := io.ReadFull(rand.Reader, clave[:])
+// logging logs one line per request with its method, path, status and
+// duration.
+func logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		requestLogger().LogAttrs(r.Context(), slog.LevelInfo, "http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// clientIP returns the IP the request came from. X-Forwarded-For is ignored
+// as any client can set it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// app holds the state shared by the sign-in handlers.
+type app struct {
+	challenges    challenge.Store
+	keys          *jws.KeySet
+	refreshWindow time.Duration
+	tokenTTL      time.Duration
+	signMode      challenge.Mode
+	keyScopes     map[string]string // client public key -> scopes
+	identities    map[string]identity
+	enrollment    enrollment.Store
+	// enrollCA, when set, is what enrollment certificates must chain to;
+	// raw keys are then refused.
+	enrollCA *x509.CertPool
+	// requireEnrollment only signs in enrolled keys, as their identity.
+	// Otherwise any key signs in as itself.
+	requireEnrollment bool
+	revoked           *revoke.TokenBlacklist
+	// encryptChallenges lets clients ask for the challenge sealed to an
+	// X25519 key.
+	encryptChallenges bool
+	// clientDataOrigins are the origins accepted in client data assertions.
+	clientDataOrigins []string
+	// timestampWindow bounds how far a response's timestamp may be from
+	// server time, either way.
+	timestampWindow time.Duration
+	// digests are the digests the sha256 sign mode accepts, the preferred
+	// one first.
+	digests []challenge.Digest
+	// signChallenges signs every challenge with the active signing key.
+	signChallenges bool
+	// maxBodyBytes caps every JSON request body.
+	maxBodyBytes int64
+	// audit records every sign-in attempt.
+	audit audit.Logger
+	// tokenDelivery is how minted tokens reach the client: tokenDeliveryBody
+	// or tokenDeliveryCookie.
+	tokenDelivery string
+	// bindSession ties challenges from GET /signIn to a session cookie that
+	// the response must be posted with.
+	bindSession bool
+	// detailedErrors tells clients why their challenge response didn't
+	// verify, for debugging. Otherwise they only learn that it didn't.
+	detailedErrors bool
+	// difficulty is the proof of work challenge responses must carry, in
+	// leading zero bits; 0 asks for none.
+	difficulty int
+	// tracer starts the spans traced through signing in. It comes from the
+	// global OpenTelemetry provider, a no-op unless one is installed.
+	tracer trace.Tracer
+}
+
+func newApp(challenges challenge.Store, signingKey crypto.Signer, header jws.Header) *app {
+	return &app{
+		challenges:        challenges,
+		keys:              jws.NewKeySet(signingKey, header),
+		refreshWindow:     defaultRefreshWindow,
+		tokenTTL:          defaultTokenTTL,
+		timestampWindow:   defaultTimestampWindow,
+		signMode:          challenge.DefaultMode,
+		keyScopes:         map[string]string{},
+		identities:        map[string]identity{},
+		enrollment:        enrollment.NewMemoryStore(),
+		requireEnrollment: true,
+		digests:           defaultDigests,
+		revoked:           revoke.NewTokenBlacklist(),
+		maxBodyBytes:      defaultMaxBodyBytes,
+		audit:             audit.Nop{},
+		tokenDelivery:     tokenDeliveryBody,
+		tracer:            otel.Tracer(tracerName),
+	}
+}
+
+// newChallengeStore returns the challenge store cfg.Store selects: in memory,
+// in Redis at cfg.RedisAddr so several instances can share challenges, or
+// stateless, MACed with cfg.ChallengeSecret and, with cfg.SharedUsed, marked
+// used in Redis at cfg.RedisAddr.
+func newChallengeStore(cfg Config) (challenge.Store, error) {
+	switch cfg.Store {
+	case "redis":
+		client := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+		return challenge.NewRedisStore(client, challenge.DefaultTTL, cfg.Challenge)
+	case "stateless":
+		store, err := challenge.NewStatelessStore(challenge.DefaultTTL, cfg.Challenge, []byte(cfg.ChallengeSecret))
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("error generating challenge"))
+			return nil, err
+		}
+		if cfg.SharedUsed {
+			marker, err := challenge.NewRedisUsedMarker(redis.NewClient(&redis.Options{Addr: cfg.RedisAddr}))
+			if err != nil {
+				store.Close()
+				return nil, err
+			}
+			store.SetUsedMarker(marker)
+		}
+		return store, nil
+	}
+	store, err := challenge.NewChallengeStoreWithConfig(challenge.DefaultTTL, cfg.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	store.SetCap(cfg.ChallengeCap)
+	return store, nil
+}
+
+// loadSigningKey loads the server's signing key from path. Without a path an
+// ephemeral RSA key is generated, so tokens don't survive a restart.
+func loadSigningKey(path string) (crypto.Signer, jws.Header, error) {
+	if path != "" {
+		return jws.LoadSigningKey(path)
+	}
+	fmt.Println("no -signing-key given, generating an ephemeral RSA key")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, jws.Header{}, err
+	}
+	header, err := jws.HeaderForKey(key.Public())
+	return key, header, err
+}
+
+// mint builds claims with the server's issuer and signs them with the active
+// signing key, giving up once ctx is done if the key can be interrupted.
+func (a *app) mint(ctx context.Context, b *jws.ClaimSetBuilder) (string, error) {
+	iss, err := issuer(a.keys.Active())
+	if err != nil {
+		return "", err
+	}
+	claims, err := b.Issuer(iss).Build()
+	if err != nil {
+		return "", err
+	}
+	return a.signClaims(ctx, claims)
+}
+
+// issuer returns the iss of tokens signed with signingKey. For RSA keys the
+// public key is embedded in iss so the token can be checked with
+// jws.Validate; otherwise iss names the key's kid.
+func issuer(signingKey crypto.Signer, header jws.Header) (string, error) {
+	if pub, ok := signingKey.Public().(*rsa.PublicKey); ok {
+		return jws.EmbeddedIssuer(pub)
+	}
+	return header.KeyID, nil
+}
+
+// signClaims signs claims as they are, apart from iss and a missing jti, with
+// the server's active signing key, named by the kid in the header. Use mint
+// unless the times must be set by hand.
+func (a *app) signClaims(ctx context.Context, claims *jws.ClaimSet) (string, error) {
+	signingKey, header := a.keys.Active()
+	if claims.Jti == "" {
+		jti, err := newTokenID()
+		if err != nil {
+			return "", err
+		}
+		claims.Jti = jti
+	}
+	iss, err := issuer(signingKey, header)
+	if err != nil {
+		return "", err
+	}
+	claims.Iss = iss
+	return jws.EncodeWithKeyContext(ctx, &header, claims, signingKey)
+}
+
+// signingProbe mints a token with the signing key, exactly as signIn does,
+// and verifies it, so a broken signing key is detected before real traffic.
+func (a *app) signingProbe() error {
+	token, err := a.mint(context.Background(), jws.NewClaimSet().Subject("readiness").TTL(a.tokenTTL))
+	if err != nil {
+		return err
+	}
+	return a.keys.Verify(token)
+}
+
+func (a *app) signIn(w http.ResponseWriter, r *http.Request) {
+	r, span := a.traceRequest(r, "signIn")
+	defer span.End()
+	if r.Method == http.MethodGet {
+		// format=qr picks its own media type, so it skips negotiation.
+		qr := r.URL.Query().Get("format") == "qr"
+		if qr && r.URL.Query().Get("x25519PublicKey") != "" {
+			writeError(w, http.StatusBadRequest, "invalid_request", "a sealed challenge can't be rendered as a QR code")
+			return
+		}
+		media, ok := negotiate(r)
+		if !ok && !qr {
+			notAcceptable(w)
 			return
 		}
-		challengeStr := hex.EncodeToString(clave[:])
 
-		challenge := dto.Challenge{
-			Message: challengeStr,
+		session, sErr := a.challengeSession(w, r)
+		if sErr != nil {
+			writeError(w, sErr.status, sErr.code, sErr.message)
+			return
+		}
+		bound := challenge.Binding{PublicKey: r.URL.Query().Get("publicKey"), Session: session}
+		_, issueSpan := a.startSpan(r.Context(), "challenge.issue")
+		challenge, sErr := a.issueChallenge(bound, r.URL.Query().Get("x25519PublicKey"))
+		if sErr != nil {
+			endSpan(issueSpan, sErr.code)
+			writeError(w, sErr.status, sErr.code, sErr.message)
+			return
+		}
+		endSpan(issueSpan, audit.ResultSuccess)
+		if qr {
+			writeChallengeQR(w, r, challenge)
+			return
+		}
+		// A bare message can't carry its expiry, server signature or
+		// sealing, so only a plain challenge goes out as text.
+		if media == mediaText && challenge.Message != "" {
+			writeText(w, challenge.Message)
+			return
 		}
 
 		json, err := json.Marshal(challenge)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("error marshalling challenge"))
+			writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling challenge")
 			return
 		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write(json)
 	} else if r.Method == http.MethodPost {
+		// Every attempt is audited with the code it was answered with.
+		event := audit.AuthEvent{Time: time.Now(), Result: audit.ResultSuccess, ClientIP: clientIP(r)}
+		defer func() {
+			recordResult(span, event.Result)
+			a.audit.LogSignIn(event)
+		}()
+		// The audit log gets the detailed code, whatever the client gets.
+		fail := func(status int, code, message string) {
+			event.Result = code
+			e := a.disclosed(&signInError{status, code, message})
+			writeError(w, e.status, e.code, e.message)
+		}
+		media, ok := negotiate(r)
+		if !ok {
+			event.Result = "not_acceptable"
+			notAcceptable(w)
+			return
+		}
+
 		body := dto.ChallengeResponse{}
-		err := json.NewDecoder(r.Body).Decode(&body)
+		err := decodeJSON(w, r, &body, a.maxBodyBytes)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("error unmarshalling challenge response"))
+			event.Result = "invalid_request"
+			writeDecodeError(w, err, "error unmarshalling challenge response")
 			return
 		}
-
-		fmt.Println(body)
-
-		m := []byte(body.Message)
-		digest := sha256.Sum256(m)
-
-		pk, _ := b64.StdEncoding.DecodeString(body.PublicKey)
-		sig, _ := b64.StdEncoding.DecodeString(body.Signature)
-		ok := ed25519.Verify(pk, digest[:], sig)
-		if !ok {
-			fmt.Println("signature does not verify")
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("signature does not verify"))
+		session, sErr := a.postedSession(r)
+		if sErr != nil {
+			fail(sErr.status, sErr.code, sErr.message)
 			return
 		}
 
-		fmt.Println("signature verifies")
-		token, err := jws.Generate()
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("error generating token"))
+		// A client that disconnects cancels r's context, and with it a
+		// signature the key is still working on.
+		token, sErr := a.completeSignIn(r.Context(), body, session, &event)
+		if sErr != nil {
+			fail(sErr.status, sErr.code, sErr.message)
+			return
+		}
+		if a.tokenDelivery == tokenDeliveryCookie {
+			a.setSessionCookie(w, token)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if media == mediaText {
+			writeText(w, token)
 			return
 		}
 
@@ -94,16 +562,283 @@ func signIn(w http.ResponseWriter, r *http.Request) {
 
 		res, err := json.Marshal(jws)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("error marshalling token"))
+			fail(http.StatusInternalServerError, "internal_error", "error marshalling token")
 			return
 
 		}
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write(res)
 
 	} else {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte("method not allowed"))
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// issueChallenge issues a challenge, bound to bound and sealed to x25519Key
+// when it isn't empty, as both GET /signIn and the gRPC GetChallenge answer
+// it.
+func (a *app) issueChallenge(bound challenge.Binding, x25519Key string) (dto.Challenge, *signInError) {
+	// A client that sends its public key gets a challenge only that key
+	// can answer. The expiry is taken before issuing so it never runs
+	// later than the store's.
+	ttl := a.challenges.TTL()
+	expiresAt := time.Now().Add(ttl)
+	challengeStr, err := a.challenges.IssueFor(bound)
+	if errors.Is(err, challenge.ErrStoreFull) {
+		return dto.Challenge{}, &signInError{http.StatusServiceUnavailable, "too_many_challenges", "too many unanswered challenges, try again later"}
+	}
+	if err != nil {
+		return dto.Challenge{}, &signInError{http.StatusInternalServerError, "internal_error", "error generating challenge"}
+	}
+
+	res := dto.Challenge{
+		Message: challengeStr,
+	}
+	if x25519Key != "" {
+		if !a.encryptChallenges {
+			return dto.Challenge{}, &signInError{http.StatusBadRequest, "encryption_disabled", "encrypted challenges are disabled"}
+		}
+		res, err = sealChallenge(x25519Key, challengeStr)
+		if err != nil {
+			return dto.Challenge{}, &signInError{http.StatusBadRequest, "invalid_x25519_key", "invalid X25519 public key"}
+		}
+	}
+	res.ExpiresAt = expiresAt.Unix()
+	res.Digest = a.advertisedDigest()
+	res.Difficulty = a.difficulty
+	if a.signChallenges {
+		res.ServerSignature, res.Kid, err = a.serverSignature(challengeStr)
+		if err != nil {
+			return dto.Challenge{}, &signInError{http.StatusInternalServerError, "internal_error", "error signing challenge"}
+		}
+	}
+	res.TTLSeconds = int(ttl / time.Second)
+	return res, nil
+}
+
+// completeSignIn verifies body, posted in session, and mints the token it
+// earns, as both POST /signIn and the gRPC SignIn answer it. It fills in
+// event's key and identity; the caller sets the result and logs it.
+func (a *app) completeSignIn(ctx context.Context, body dto.ChallengeResponse, session string, event *audit.AuthEvent) (string, *signInError) {
+	event.PublicKey = body.PublicKey
+
+	_, verifySpan := a.startSpan(ctx, "auth.verify", attribute.String("auth.sign_mode", string(a.signMode)))
+	pk, sErr := a.verifyChallengeResponse(body, session)
+	if sErr != nil {
+		endSpan(verifySpan, sErr.code)
+		return "", sErr
+	}
+	endSpan(verifySpan, audit.ResultSuccess)
+
+	// iss identifies the server's key, so sub is the identity the
+	// client's key is enrolled under or, without enrollment, the key
+	// itself, re-encoded as std base64 whatever encoding it arrived in.
+	key := b64.StdEncoding.EncodeToString(pk)
+	event.PublicKey = key
+	subject := key
+	identity, enrolled := a.enrollment.Identity(pk)
+	event.Identity = identity
+	if a.requireEnrollment {
+		if !enrolled {
+			return "", &signInError{http.StatusUnauthorized, "unknown_key", "public key is not enrolled"}
+		}
+		subject = identity
+	}
+	_, header := a.keys.Active()
+	mintCtx, mintSpan := a.startSpan(ctx, "jws.sign", attribute.String("jws.alg", header.Algorithm))
+	token, err := a.mint(mintCtx, jws.NewClaimSet().Subject(subject).Scope(a.keyScopes[key]).TTL(a.tokenTTL))
+	if err != nil {
+		endSpan(mintSpan, "internal_error")
+		return "", &signInError{http.StatusInternalServerError, "internal_error", "error generating token"}
+	}
+	endSpan(mintSpan, audit.ResultSuccess)
+	return token, nil
+}
+
+// jwks publishes the server's trusted verification keys as a JWKS document,
+// including keys rotated out but not yet retired. Each kid matches the one in
+// the header of the tokens that key signed.
+func (a *app) jwks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	set, err := a.keys.JWKS()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error encoding verification key")
+		return
+	}
+	res, err := json.Marshal(set)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling jwks")
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// signInError is why a challenge response was rejected, as the HTTP status
+// and error code to answer with.
+type signInError struct {
+	status  int
+	code    string
+	message string
+}
+
+// verifyChallengeResponse checks a challenge response posted in session:
+// that it carries the proof of work asked for and answers a live challenge
+// issued to that session, once, with a valid signature by the key the
+// challenge was bound to, if any, and, when timestamped, recently. It returns
+// the client's public key.
+func (a *app) verifyChallengeResponse(body dto.ChallengeResponse, session string) ([]byte, *signInError) {
+	message := body.Message
+	if !a.digestAllowed(body.Digest) {
+		return nil, &signInError{http.StatusBadRequest, "unsupported_digest", "digest is not supported"}
+	}
+	if body.Timestamp != 0 {
+		if len(body.ClientData) > 0 {
+			return nil, &signInError{http.StatusBadRequest, "invalid_request", "timestamp can't be combined with client data"}
+		}
+		skew := time.Since(time.Unix(body.Timestamp, 0))
+		if skew > a.timestampWindow || skew < -a.timestampWindow {
+			return nil, &signInError{http.StatusUnauthorized, "stale_timestamp", "timestamp is outside the accepted window"}
+		}
+	}
+	if len(body.ClientData) > 0 {
+		cd, err := parseClientData(body.ClientData, a.clientDataOrigins)
+		if errors.Is(err, errOriginNotAllowed) {
+			return nil, &signInError{http.StatusUnauthorized, "origin_not_allowed", err.Error()}
+		}
+		if err != nil || (body.Message != "" && body.Message != cd.Challenge) {
+			return nil, &signInError{http.StatusBadRequest, "invalid_client_data", "invalid client data"}
+		}
+		message = cd.Challenge
+	}
+	// The work is checked first so that failing it costs the server a
+	// single hash.
+	if !challenge.SolvesWork(message, body.Nonce, a.difficulty) {
+		return nil, &signInError{http.StatusUnauthorized, "insufficient_work", "proof of work does not meet the difficulty"}
+	}
+
+	bound, err := challenge.ConsumeReason(a.challenges, message)
+	if errors.Is(err, challenge.ErrExpiredChallenge) {
+		return nil, &signInError{http.StatusBadRequest, "expired_challenge", "challenge expired"}
+	} else if err != nil {
+		return nil, &signInError{http.StatusBadRequest, "invalid_challenge", "unknown or expired challenge"}
+	}
+	if bound.Session != session {
+		return nil, &signInError{http.StatusBadRequest, "session_mismatch", "challenge was issued to a different session"}
+	}
+
+	pk, err := auth.PublicKey(body)
+	if err != nil {
+		return nil, authError(err)
+	}
+	if bound.PublicKey != "" && !sameKey(bound.PublicKey, pk) {
+		return nil, &signInError{http.StatusUnauthorized, "challenge_key_mismatch", "challenge was issued for a different public key"}
+	}
+	err = auth.VerifyChallengeResponseWithMode(body, a.signMode)
+	if err != nil {
+		return nil, authError(err)
+	}
+	return pk, nil
+}
+
+// serverSignature signs message with the active signing key so clients can
+// check it came from this server, and returns the base64url signature and
+// the key's kid in the JWKS.
+func (a *app) serverSignature(message string) (string, string, error) {
+	key, header := a.keys.Active()
+	sig, err := jws.SignDetached(key, challenge.ServerSigningInput(message))
+	if err != nil {
+		return "", "", err
+	}
+	return dto.EncodeBinary(sig), header.KeyID, nil
+}
+
+// advertisedDigest is the digest GET /signIn asks clients to sign under: the
+// first allowed one in sha256 sign mode, none in the others.
+func (a *app) advertisedDigest() string {
+	if a.signMode != challenge.ModeDigest || len(a.digests) == 0 {
+		return ""
+	}
+	return string(a.digests[0])
+}
+
+// digestAllowed reports whether a response naming digest name may be
+// verified. Only the sha256 sign mode takes a digest, and only an allowed one;
+// no name means SHA-256.
+func (a *app) digestAllowed(name string) bool {
+	if a.signMode != challenge.ModeDigest {
+		return name == ""
+	}
+	d, err := challenge.ParseDigest(name)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range a.digests {
+		if d == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// authError maps an error from the auth package to the sign-in error the
+// client gets.
+func authError(err error) *signInError {
+	switch {
+	case errors.Is(err, auth.ErrMalformedPublicKey), errors.Is(err, auth.ErrBadKeyLength):
+		return &signInError{http.StatusBadRequest, "invalid_public_key", "invalid public key"}
+	case errors.Is(err, auth.ErrMalformedSignature), errors.Is(err, auth.ErrBadSignatureLength):
+		return &signInError{http.StatusBadRequest, "malformed_signature", "invalid signature"}
+	case errors.Is(err, auth.ErrUnsupportedDigest):
+		return &signInError{http.StatusBadRequest, "unsupported_digest", "digest is not supported"}
+	default:
+		return &signInError{http.StatusUnauthorized, "invalid_signature", "signature does not verify"}
+	}
+}
+
+// verificationCodes are the codes that say why a challenge response didn't
+// verify: an unknown or expired challenge, a malformed key or signature, or a
+// signature that doesn't match.
+var verificationCodes = map[string]bool{
+	"invalid_challenge":   true,
+	"expired_challenge":   true,
+	"invalid_public_key":  true,
+	"malformed_signature": true,
+	"invalid_signature":   true,
+}
+
+// disclosed returns e as the client may see it. Why a response didn't verify
+// could help an attacker, so without -debug every verification failure is
+// answered as a signature that doesn't verify. The detailed reason is logged
+// either way.
+func (a *app) disclosed(e *signInError) *signInError {
+	if !verificationCodes[e.code] {
+		return e
+	}
+	slog.Info("sign-in verification failed", slog.String("code", e.code), slog.String("reason", e.message))
+	if a.detailedErrors {
+		return e
+	}
+	return &signInError{http.StatusUnauthorized, "invalid_signature", "signature does not verify"}
+}
+
+// methodNotAllowed answers 405 with an Allow header listing the methods the
+// route accepts.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+}
+
+// writeError answers with status and a JSON dto.ErrorResponse body.
+func writeError(w http.ResponseWriter, status int, code string, msg string) {
+	res, _ := json.Marshal(dto.ErrorResponse{Code: code, Message: msg})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(res)
 }
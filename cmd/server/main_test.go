@@ -2,57 +2,131 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
 	"crypto/ed25519"
-	"crypto/sha256"
+	"crypto/rand"
 	b64 "encoding/base64"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
 	"github.com/martinsaporiti/ed25519-poc/internal/dto"
 	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
 )
 
-func TestSignInHandler(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/signIn", nil)
+func newTestApp(t *testing.T) *app {
+	t.Helper()
+	key, header, err := loadSigningKey("")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return newTestAppWithKey(t, key, header)
+}
+
+func newTestAppWithKey(t *testing.T, key crypto.Signer, header jws.Header) *app {
+	t.Helper()
+	challenges := challenge.NewChallengeStore(challenge.DefaultTTL)
+	t.Cleanup(challenges.Close)
+	a := newApp(challenges, key, header)
+	// Most tests sign in with throwaway keys; enroll_test.go covers
+	// enrollment.
+	a.requireEnrollment = false
+	return a
+}
+
+func signInToken(t *testing.T, a *app) string {
+	t.Helper()
+	res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+	}
+	return decodeToken(t, res)
+}
+
+func decodeToken(t *testing.T, res *http.Response) string {
+	t.Helper()
+	jwsPayload := dto.Jws{}
+	json.NewDecoder(res.Body).Decode(&jwsPayload)
+	return jwsPayload.Token
+}
+
+func getChallenge(t *testing.T, a *app) dto.Challenge {
+	t.Helper()
+	return getChallengeFor(t, a, "")
+}
+
+// getChallengeFor requests a challenge bound to publicKey, or an unbound one
+// when publicKey is empty.
+func getChallengeFor(t *testing.T, a *app, publicKey string) dto.Challenge {
+	t.Helper()
+	target := "/signIn"
+	if publicKey != "" {
+		target += "?" + url.Values{"publicKey": {publicKey}}.Encode()
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
 	w := httptest.NewRecorder()
-	signIn(w, req)
+	a.signIn(w, req)
 	res := w.Result()
 	defer res.Body.Close()
 
 	challenge := dto.Challenge{}
-
 	err := json.NewDecoder(res.Body).Decode(&challenge)
 	if err != nil {
 		t.Errorf("expected error to be nil got %v", err)
 	}
-
 	if res.StatusCode != http.StatusOK {
 		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
 	}
+	return challenge
+}
 
-	publ, priv, _ := ed25519.GenerateKey((nil))
-	digest := sha256.Sum256([]byte(challenge.Message))
-	signature := ed25519.Sign(priv, digest[:])
+func signChallenge(t *testing.T, message string) dto.ChallengeResponse {
+	t.Helper()
+	_, priv := testutil.DeterministicEd25519(28)
+	return signChallengeWithKey(t, message, priv)
+}
 
-	t.Run("Test sign in with success ", func(t *testing.T) {
-		pk := b64.StdEncoding.EncodeToString(publ)
-		sig := b64.StdEncoding.EncodeToString(signature)
-		challengeResponse := dto.ChallengeResponse{
-			Signature: sig,
-			Message:   challenge.Message,
-			PublicKey: pk,
-		}
+func signChallengeWithKey(t *testing.T, message string, priv ed25519.PrivateKey) dto.ChallengeResponse {
+	t.Helper()
+	publ := priv.Public().(ed25519.PublicKey)
+	return dto.ChallengeResponse{
+		Signature: testutil.SignChallenge(priv, message),
+		Message:   message,
+		PublicKey: b64.StdEncoding.EncodeToString(publ),
+	}
+}
 
-		challengeResponseJson, err := json.Marshal(challengeResponse)
-		if err != nil {
-			t.Errorf("expected error to be nil got %v", err)
-		}
-		req2 := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewBuffer(challengeResponseJson))
-		w2 := httptest.NewRecorder()
-		signIn(w2, req2)
-		res2 := w2.Result()
+func postSignIn(t *testing.T, a *app, challengeResponse dto.ChallengeResponse) *http.Response {
+	t.Helper()
+	challengeResponseJson, err := json.Marshal(challengeResponse)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewBuffer(challengeResponseJson))
+	w := httptest.NewRecorder()
+	a.signIn(w, req)
+	return w.Result()
+}
+
+func TestSignInHandler(t *testing.T) {
+	a := newTestApp(t)
+	// The failure cases check why a sign-in failed, which only -debug says.
+	a.detailedErrors = true
+	challenge := getChallenge(t, a)
+
+	t.Run("Test sign in with success ", func(t *testing.T) {
+		challengeResponse := signChallenge(t, challenge.Message)
+		res2 := postSignIn(t, a, challengeResponse)
 		defer res2.Body.Close()
 		if res2.StatusCode != http.StatusOK {
 			t.Errorf("expected status code to be 200 got %d", res2.StatusCode)
@@ -63,33 +137,524 @@ func TestSignInHandler(t *testing.T) {
 		if jwsPayload.Token == "" {
 			t.Errorf("expected token not to be empty got %s", jwsPayload.Token)
 		}
-		err = jws.Validate(jwsPayload.Token)
+		err := jws.Validate(jwsPayload.Token)
 		if err != nil {
 			t.Errorf("expected error to be nil got %v", err)
 		}
+		if res2.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected content type to be application/json got %s", res2.Header.Get("Content-Type"))
+		}
+
+		claims, err := jws.Decode(jwsPayload.Token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		if claims.Sub != challengeResponse.PublicKey {
+			t.Errorf("expected sub to be %s got %s", challengeResponse.PublicKey, claims.Sub)
+		}
+		if claims.Exp <= time.Now().Unix() {
+			t.Errorf("expected exp to be in the future got %d", claims.Exp)
+		}
+	})
+
+	t.Run("Test challenge carries its expiry ", func(t *testing.T) {
+		issued := getChallenge(t, a)
+		ttl := a.challenges.TTL()
+		want := time.Now().Add(ttl).Unix()
+		if issued.ExpiresAt < want-2 || issued.ExpiresAt > want {
+			t.Errorf("expected expiresAt to be about %d got %d", want, issued.ExpiresAt)
+		}
+		if issued.TTLSeconds != int(ttl/time.Second) {
+			t.Errorf("expected ttlSeconds to be %d got %d", int(ttl/time.Second), issued.TTLSeconds)
+		}
+	})
+
+	t.Run("Test sign in with custom token ttl ", func(t *testing.T) {
+		custom := newTestApp(t)
+		custom.tokenTTL = 5 * time.Minute
+		claims, _ := jws.Decode(signInToken(t, custom))
+		if time.Duration(claims.Exp-claims.Iat)*time.Second != custom.tokenTTL {
+			t.Errorf("expected exp - iat to be %s got %ds", custom.tokenTTL, claims.Exp-claims.Iat)
+		}
+	})
 
+	t.Run("Test sign in with reused challenge ", func(t *testing.T) {
+		res2 := postSignIn(t, a, signChallenge(t, challenge.Message))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "invalid_challenge" {
+			t.Errorf("expected error code to be invalid_challenge got %s", code)
+		}
+	})
+
+	t.Run("Test sign in with unknown challenge ", func(t *testing.T) {
+		res2 := postSignIn(t, a, signChallenge(t, "not issued by the server"))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "invalid_challenge" {
+			t.Errorf("expected error code to be invalid_challenge got %s", code)
+		}
+	})
+
+	t.Run("Test sign in with short public key ", func(t *testing.T) {
+		challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+		challengeResponse.PublicKey = b64.StdEncoding.EncodeToString([]byte("short"))
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "invalid_public_key" {
+			t.Errorf("expected error code to be invalid_public_key got %s", code)
+		}
+	})
+
+	t.Run("Test sign in with base64url key material ", func(t *testing.T) {
+		challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+		pk, _ := b64.StdEncoding.DecodeString(challengeResponse.PublicKey)
+		sig, _ := b64.StdEncoding.DecodeString(challengeResponse.Signature)
+		challengeResponse.PublicKey = b64.RawURLEncoding.EncodeToString(pk)
+		challengeResponse.Signature = b64.RawURLEncoding.EncodeToString(sig)
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res2.StatusCode)
+		}
+		claims, _ := jws.Decode(decodeToken(t, res2))
+		if claims.Sub != b64.StdEncoding.EncodeToString(pk) {
+			t.Errorf("expected sub to be std base64 got %s", claims.Sub)
+		}
+	})
+
+	t.Run("Test sign in with undecodable public key ", func(t *testing.T) {
+		challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+		challengeResponse.PublicKey = "%%% not base64 %%%"
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "invalid_public_key" {
+			t.Errorf("expected error code to be invalid_public_key got %s", code)
+		}
+	})
+
+	t.Run("Test sign in with garbage signature ", func(t *testing.T) {
+		challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+		challengeResponse.Signature = "%%% not base64 %%%"
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "malformed_signature" {
+			t.Errorf("expected error code to be malformed_signature got %s", code)
+		}
 	})
 
 	t.Run("Test sign in with wrong signature ", func(t *testing.T) {
-		pk := b64.StdEncoding.EncodeToString(publ)
-		sig := b64.StdEncoding.EncodeToString([]byte("wrong sig"))
-		challengeResponse := dto.ChallengeResponse{
-			Signature: sig,
-			Message:   challenge.Message,
-			PublicKey: pk,
+		challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+		wrongSig := make([]byte, ed25519.SignatureSize)
+		challengeResponse.Signature = b64.StdEncoding.EncodeToString(wrongSig)
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "invalid_signature" {
+			t.Errorf("expected error code to be invalid_signature got %s", code)
+		}
+	})
+
+	t.Run("Test sign in with bound challenge ", func(t *testing.T) {
+		publ, priv := testutil.DeterministicEd25519(29)
+		publicKey := b64.StdEncoding.EncodeToString(publ)
+		challengeResponse := signChallengeWithKey(t, getChallengeFor(t, a, publicKey).Message, priv)
+		res2 := postSignIn(t, a, challengeResponse)
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res2.StatusCode)
+		}
+	})
+
+	t.Run("Test sign in with challenge bound to another key ", func(t *testing.T) {
+		publ, _ := testutil.DeterministicEd25519(30)
+		publicKey := b64.StdEncoding.EncodeToString(publ)
+		res2 := postSignIn(t, a, signChallenge(t, getChallengeFor(t, a, publicKey).Message))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res2.StatusCode)
+		}
+		if code := errorCode(t, res2); code != "challenge_key_mismatch" {
+			t.Errorf("expected error code to be challenge_key_mismatch got %s", code)
+		}
+	})
+
+	t.Run("Test encrypted challenge hides the message ", func(t *testing.T) {
+		a.encryptChallenges = true
+		defer func() { a.encryptChallenges = false }()
+		key, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		query := url.Values{"x25519PublicKey": {b64.StdEncoding.EncodeToString(key.PublicKey().Bytes())}}
+		req := httptest.NewRequest(http.MethodGet, "/signIn?"+query.Encode(), nil)
+		w := httptest.NewRecorder()
+		a.signIn(w, req)
+		sealed := dto.Challenge{}
+		json.NewDecoder(w.Result().Body).Decode(&sealed)
+		if sealed.Message != "" || sealed.Ciphertext == "" {
+			t.Errorf("expected only ciphertext got %+v", sealed)
 		}
+	})
 
-		challengeResponseJson, err := json.Marshal(challengeResponse)
+	t.Run("Test sign in with unsupported method ", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodDelete, "/signIn", nil)
+		w := httptest.NewRecorder()
+		a.signIn(w, req)
+		res2 := w.Result()
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected status code to be 405 got %d", res2.StatusCode)
+		}
+		if allow := res2.Header.Get("Allow"); allow != "GET, POST" {
+			t.Errorf("expected Allow header to be GET, POST got %s", allow)
+		}
+		if code := errorCode(t, res2); code != "method_not_allowed" {
+			t.Errorf("expected error code to be method_not_allowed got %s", code)
+		}
+	})
+}
+
+// errorCode decodes a dto.ErrorResponse body and returns its code.
+func errorCode(t *testing.T, res *http.Response) string {
+	t.Helper()
+	if res.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected content type to be application/json got %s", res.Header.Get("Content-Type"))
+	}
+	body := dto.ErrorResponse{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return body.Code
+}
+
+func TestSignInModes(t *testing.T) {
+	for _, mode := range []challenge.Mode{challenge.ModeRaw, challenge.ModePrehash} {
+		t.Run("Test sign in with "+string(mode), func(t *testing.T) {
+			a := newTestApp(t)
+			a.signMode = mode
+			message := getChallenge(t, a).Message
+			publ, priv := testutil.DeterministicEd25519(31)
+			signature, err := mode.Sign(priv, []byte(message))
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			res := postSignIn(t, a, dto.ChallengeResponse{
+				Signature: b64.StdEncoding.EncodeToString(signature),
+				Message:   message,
+				PublicKey: b64.StdEncoding.EncodeToString(publ),
+			})
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+			}
+		})
+	}
+
+	t.Run("Test digest signature is rejected in raw mode", func(t *testing.T) {
+		a := newTestApp(t)
+		a.signMode = challenge.ModeRaw
+		res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+	})
+}
+
+// blockingKey is an HSM-style key whose signing blocks until its context is
+// done.
+type blockingKey struct {
+	crypto.Signer
+}
+
+func (k blockingKey) SignContext(ctx context.Context, data []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestSigningKey(t *testing.T) {
+	t.Run("Test tokens are signed by the loaded key", func(t *testing.T) {
+		a := newTestApp(t)
+		first := signInToken(t, a)
+		second := signInToken(t, a)
+		for _, token := range []string{first, second} {
+			signingKey, _ := a.keys.Active()
+			err := jws.VerifyWithKey(token, signingKey.Public())
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+		}
+		firstClaims, _ := jws.Decode(first)
+		secondClaims, _ := jws.Decode(second)
+		if firstClaims.Iss != secondClaims.Iss {
+			t.Errorf("expected both tokens to carry the same issuer key")
+		}
+	})
+
+	t.Run("Test Ed25519 signing key", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(32)
+		header, err := jws.HeaderForKey(pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		a := newTestAppWithKey(t, priv, header)
+		token := signInToken(t, a)
+		err = jws.VerifyEd25519(token, pub)
 		if err != nil {
 			t.Errorf("expected error to be nil got %v", err)
 		}
-		req2 := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewBuffer(challengeResponseJson))
-		w2 := httptest.NewRecorder()
-		signIn(w2, req2)
-		res2 := w2.Result()
-		defer res2.Body.Close()
-		if res2.StatusCode == http.StatusOK {
-			t.Errorf("expected status code not to be 200 got %d", res2.StatusCode)
+		claims, _ := jws.Decode(token)
+		if claims.Iss != header.KeyID {
+			t.Errorf("expected iss to be %s got %s", header.KeyID, claims.Iss)
+		}
+	})
+
+	t.Run("Test client disconnect cancels signing", func(t *testing.T) {
+		_, priv := testutil.DeterministicEd25519(33)
+		header, _ := jws.HeaderForKey(priv.Public())
+		a := newTestAppWithKey(t, blockingKey{priv}, header)
+		b, _ := json.Marshal(signChallenge(t, getChallenge(t, a).Message))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewReader(b)).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		start := time.Now()
+		a.signIn(w, req)
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the handler to return once the request was cancelled got %s", elapsed)
+		}
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status code to be 500 got %d", w.Code)
+		}
+	})
+}
+
+func tokenHeader(t *testing.T, token string) jws.Header {
+	t.Helper()
+	b, err := b64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	header := jws.Header{}
+	err = json.Unmarshal(b, &header)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return header
+}
+
+func TestJWKS(t *testing.T) {
+	_, edKey := testutil.DeterministicEd25519(34)
+	edHeader, _ := jws.HeaderForKey(edKey.Public())
+	rsaKey, rsaHeader, _ := loadSigningKey("")
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		header jws.Header
+		kty    string
+	}{
+		{"Test Ed25519 key", edKey, edHeader, "OKP"},
+		{"Test RSA key", rsaKey, rsaHeader, "RSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAppWithKey(t, tt.key, tt.header)
+
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+			a.jwks(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+			}
+
+			set := jws.JWKS{}
+			err := json.NewDecoder(res.Body).Decode(&set)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if len(set.Keys) != 1 || set.Keys[0].Kty != tt.kty {
+				t.Fatalf("expected one %s key got %v", tt.kty, set.Keys)
+			}
+
+			token := signInToken(t, a)
+			pub, err := set.Key(tokenHeader(t, token).KeyID)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = jws.VerifyWithKey(token, pub)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	old := requestLogger
+	l := slog.New(slog.NewJSONHandler(buf, nil))
+	requestLogger = func() *slog.Logger { return l }
+	t.Cleanup(func() { requestLogger = old })
+
+	a := newTestApp(t)
+	handler := logging(http.HandlerFunc(a.signIn))
+	post := func(challengeResponse dto.ChallengeResponse) {
+		b, _ := json.Marshal(challengeResponse)
+		req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewBuffer(b))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name     string
+		response func() dto.ChallengeResponse
+		status   int
+	}{
+		{"Test successful sign in is logged", func() dto.ChallengeResponse {
+			return signChallenge(t, getChallenge(t, a).Message)
+		}, http.StatusOK},
+		{"Test failed sign in is logged", func() dto.ChallengeResponse {
+			challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+			challengeResponse.Signature = b64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+			return challengeResponse
+		}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			post(tt.response())
+
+			entry := struct {
+				Method   string `json:"method"`
+				Path     string `json:"path"`
+				Status   int    `json:"status"`
+				Duration int64  `json:"duration"`
+			}{}
+			err := json.Unmarshal(buf.Bytes(), &entry)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if entry.Status != tt.status {
+				t.Errorf("expected status to be %d got %d", tt.status, entry.Status)
+			}
+			if entry.Method != http.MethodPost || entry.Path != "/signIn" {
+				t.Errorf("expected POST /signIn got %s %s", entry.Method, entry.Path)
+			}
+			if entry.Duration <= 0 {
+				t.Errorf("expected duration to be positive got %d", entry.Duration)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	a := newTestApp(t)
+	oldToken := signInToken(t, a)
+	_, oldHeader := a.keys.Active()
+
+	_, newKey := testutil.DeterministicEd25519(35)
+	newHeader, _ := jws.HeaderForKey(newKey.Public())
+	a.keys.Promote(newKey, newHeader)
+
+	t.Run("Test new tokens use the promoted key", func(t *testing.T) {
+		header := tokenHeader(t, signInToken(t, a))
+		if header.KeyID != newHeader.KeyID {
+			t.Errorf("expected kid to be %s got %s", newHeader.KeyID, header.KeyID)
+		}
+	})
+
+	t.Run("Test old tokens verify until the key is retired", func(t *testing.T) {
+		_, err := a.verifyToken(oldToken)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		a.keys.Retire(oldHeader.KeyID)
+		_, err = a.verifyToken(oldToken)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+func BenchmarkSignInGET(b *testing.B) {
+	key, header, err := loadSigningKey("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	challenges := challenge.NewChallengeStore(challenge.DefaultTTL)
+	defer challenges.Close()
+	a := newApp(challenges, key, header)
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			a.signIn(w, httptest.NewRequest(http.MethodGet, "/signIn", nil))
+			if w.Code != http.StatusOK {
+				b.Fatalf("expected status code to be 200 got %d", w.Code)
+			}
 		}
 	})
 }
+
+func TestMethodNotAllowed(t *testing.T) {
+	a := newTestApp(t)
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/signIn", "GET, POST"},
+		{"/signIn/batch", "POST"},
+		{"/signIn/multi", "POST"},
+		{"/challenges", "GET"},
+		{"/enroll", "POST, DELETE"},
+		{"/refresh", "POST"},
+		{"/.well-known/jwks.json", "GET"},
+		{"/readyz", "GET"},
+		{"/healthz", "GET"},
+		{"/metrics", "GET"},
+		{"/introspect", "POST"},
+		{"/verify", "POST"},
+		{"/revoke", "POST"},
+	}
+	// Routes behind authorize check the token before the method.
+	adminToken, err := a.mint(context.Background(), jws.NewClaimSet().Subject("admin").Scope(adminScope).TTL(a.tokenTTL))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	for _, tt := range tests {
+		t.Run("Test "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusMethodNotAllowed {
+				t.Errorf("expected status code to be 405 got %d", res.StatusCode)
+			}
+			if allow := res.Header.Get("Allow"); allow != tt.allow {
+				t.Errorf("expected Allow header to be %s got %s", tt.allow, allow)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// claimsKey is the context key authorize stores verified claims under.
+type claimsKey struct{}
+
+func withClaims(ctx context.Context, claims *jws.ClaimSet) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// claimsFromContext returns the claims of the bearer token authorize
+// verified for this request, if any.
+func claimsFromContext(ctx context.Context) (*jws.ClaimSet, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*jws.ClaimSet)
+	return claims, ok
+}
+
+// me answers GET /me with who the caller's token says they are. It must be
+// wrapped in authorize.
+func (a *app) me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing_token", "missing bearer token")
+		return
+	}
+
+	res, err := json.Marshal(dto.Me{Iss: claims.Iss, Sub: claims.Sub})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling identity")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func TestMe(t *testing.T) {
+	a := newTestApp(t)
+	handler := newServer(Config{}, a, newReadiness(a.signingProbe)).Handler
+	token := signInToken(t, a)
+	claims, _ := jws.Decode(token)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		code   string
+	}{
+		{"Test valid token", "Bearer " + token, http.StatusOK, ""},
+		{"Test missing token", "", http.StatusUnauthorized, "missing_token"},
+		{"Test invalid token", "Bearer not.a.token", http.StatusUnauthorized, "invalid_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Fatalf("expected status code to be %d got %d", tt.status, res.StatusCode)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, res); code != tt.code {
+					t.Errorf("expected error code to be %s got %s", tt.code, code)
+				}
+				return
+			}
+			me := dto.Me{}
+			json.NewDecoder(res.Body).Decode(&me)
+			if me.Iss != claims.Iss || me.Sub != claims.Sub {
+				t.Errorf("expected iss %s and sub %s got %+v", claims.Iss, claims.Sub, me)
+			}
+		})
+	}
+
+	t.Run("Test claims reach the handler through the context", func(t *testing.T) {
+		var got *jws.ClaimSet
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = claimsFromContext(r.Context())
+		})
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		a.authorize()(next).ServeHTTP(httptest.NewRecorder(), req)
+		if got == nil || got.Jti != claims.Jti {
+			t.Errorf("expected claims with jti %s got %v", claims.Jti, got)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// challengeStats is what a challenge store that counts its own entries
+// reports. Only the in-memory store does.
+type challengeStats interface {
+	Len() int
+	Evictions() uint64
+}
+
+// metrics answers GET /metrics with counters about the server's state.
+func (a *app) metrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	res := dto.Metrics{}
+	if stats, ok := a.challenges.(challengeStats); ok {
+		res.Challenges = &dto.ChallengeMetrics{Live: stats.Len(), Evictions: stats.Evictions()}
+	}
+	body, err := json.Marshal(res)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling metrics")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+func getMetrics(t *testing.T, a *app) dto.Metrics {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	a.metrics(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	body := dto.Metrics{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return body
+}
+
+func TestChallengeCapMetrics(t *testing.T) {
+	t.Run("Test evictions are reported", func(t *testing.T) {
+		a := newTestApp(t)
+		a.challenges.(*challenge.ChallengeStore).SetCap(challenge.Cap{Max: 2})
+		for i := 0; i < 3; i++ {
+			getChallenge(t, a)
+		}
+
+		m := getMetrics(t, a)
+		if m.Challenges == nil {
+			t.Fatalf("expected challenge metrics")
+		}
+		if m.Challenges.Live != 2 || m.Challenges.Evictions != 1 {
+			t.Errorf("expected 2 live challenges and 1 eviction got %+v", *m.Challenges)
+		}
+	})
+
+	t.Run("Test strict cap answers 503", func(t *testing.T) {
+		a := newTestApp(t)
+		a.challenges.(*challenge.ChallengeStore).SetCap(challenge.Cap{Max: 1, Reject: true})
+		getChallenge(t, a)
+
+		req := httptest.NewRequest(http.MethodGet, "/signIn", nil)
+		w := httptest.NewRecorder()
+		a.signIn(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected status code to be 503 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "too_many_challenges" {
+			t.Errorf("expected error code to be too_many_challenges got %s", code)
+		}
+		if m := getMetrics(t, a); m.Challenges.Evictions != 0 {
+			t.Errorf("expected no evictions got %d", m.Challenges.Evictions)
+		}
+	})
+}
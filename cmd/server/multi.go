@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/auth"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// maxMultiSignatures caps the signatures in one POST /signIn/multi.
+const maxMultiSignatures = 16
+
+// multiLimits fits maxMultiSignatures key and signature pairs.
+var multiLimits = routeLimits{
+	MaxBodyBytes: maxMultiSignatures * signInLimits.MaxBodyBytes,
+	Timeout:      signInLimits.Timeout,
+}
+
+// identity is a user who signs in with several device keys, Threshold of
+// which must sign each challenge.
+type identity struct {
+	Threshold int      `json:"threshold"`
+	Keys      []string `json:"keys"`
+}
+
+// enrolled reports whether pk is one of id's keys.
+func (id identity) enrolled(pk []byte) bool {
+	for _, key := range id.Keys {
+		if sameKey(key, pk) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadIdentities reads a JSON object mapping identities to their threshold
+// and base64 device public keys. Without a path there are no identities.
+func loadIdentities(path string) (map[string]identity, error) {
+	identities := map[string]identity{}
+	if path == "" {
+		return identities, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(b, &identities)
+	if err != nil {
+		return nil, err
+	}
+	for name, id := range identities {
+		if id.Threshold < 1 || id.Threshold > len(id.Keys) {
+			return nil, fmt.Errorf("identity %q: threshold %d is not between 1 and its %d keys", name, id.Threshold, len(id.Keys))
+		}
+	}
+	return identities, nil
+}
+
+// signInMulti answers POST /signIn/multi: it signs in an identity when at
+// least its threshold of distinct enrolled keys signed the challenge. Each
+// signature is checked as POST /signIn checks one; a key signing twice
+// counts once.
+func (a *app) signInMulti(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.MultiChallengeResponse{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling challenge response")
+		return
+	}
+	if len(body.Signatures) == 0 || len(body.Signatures) > maxMultiSignatures {
+		writeError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("a multi-key sign-in needs 1 to %d signatures", maxMultiSignatures))
+		return
+	}
+	id, ok := a.identities[body.Identity]
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unknown_identity", "unknown identity")
+		return
+	}
+
+	session, sErr := a.postedSession(r)
+	if sErr != nil {
+		writeError(w, sErr.status, sErr.code, sErr.message)
+		return
+	}
+	bound, ok := a.challenges.ConsumeBinding(body.Message)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid_challenge", "unknown or expired challenge")
+		return
+	}
+	if bound.Session != session {
+		writeError(w, http.StatusBadRequest, "session_mismatch", "challenge was issued to a different session")
+		return
+	}
+
+	verified := []string{}
+	seen := map[string]bool{}
+	boundSigned := bound.PublicKey == ""
+	for _, ks := range body.Signatures {
+		response := dto.ChallengeResponse{Message: body.Message, PublicKey: ks.PublicKey, Signature: ks.Signature}
+		pk, err := auth.PublicKey(response)
+		if err != nil || !id.enrolled(pk) {
+			continue
+		}
+		key := dto.EncodeBinary(pk)
+		if seen[key] {
+			continue
+		}
+		err = auth.VerifyChallengeResponseWithMode(response, a.signMode)
+		if errors.Is(err, auth.ErrMalformedSignature) || errors.Is(err, auth.ErrBadSignatureLength) {
+			writeError(w, http.StatusBadRequest, "malformed_signature", "invalid signature")
+			return
+		}
+		if err != nil {
+			continue
+		}
+		seen[key] = true
+		verified = append(verified, key)
+		if !boundSigned && sameKey(bound.PublicKey, pk) {
+			boundSigned = true
+		}
+	}
+	if !boundSigned {
+		writeError(w, http.StatusUnauthorized, "challenge_key_mismatch", "challenge was issued for a key that didn't sign it")
+		return
+	}
+	if len(verified) < id.Threshold {
+		writeError(w, http.StatusUnauthorized, "threshold_not_met", fmt.Sprintf("%d of %d required signatures verify", len(verified), id.Threshold))
+		return
+	}
+
+	token, err := a.mint(r.Context(), jws.NewClaimSet().Subject(body.Identity).TTL(a.tokenTTL))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error generating token")
+		return
+	}
+
+	res, err := json.Marshal(dto.MultiSignIn{Token: token, Verified: verified, Threshold: id.Threshold})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling token")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func postMulti(t *testing.T, a *app, body dto.MultiChallengeResponse) *http.Response {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/signIn/multi", bytes.NewReader(b))
+	w := httptest.NewRecorder()
+	a.signInMulti(w, req)
+	return w.Result()
+}
+
+// keySignatures signs message with each of keys.
+func keySignatures(t *testing.T, message string, keys ...ed25519.PrivateKey) []dto.KeySignature {
+	t.Helper()
+	signatures := []dto.KeySignature{}
+	for _, key := range keys {
+		response := signChallengeWithKey(t, message, key)
+		signatures = append(signatures, dto.KeySignature{PublicKey: response.PublicKey, Signature: response.Signature})
+	}
+	return signatures
+}
+
+func TestSignInMulti(t *testing.T) {
+	a := newTestApp(t)
+	devices := make([]ed25519.PrivateKey, 3)
+	enrolled := []string{}
+	for i := range devices {
+		pub, priv := testutil.DeterministicEd25519(byte(36 + i))
+		devices[i] = priv
+		enrolled = append(enrolled, b64.StdEncoding.EncodeToString(pub))
+	}
+	a.identities["alice"] = identity{Threshold: 2, Keys: enrolled}
+	_, stranger := testutil.DeterministicEd25519(40)
+
+	t.Run("Test threshold met", func(t *testing.T) {
+		message := getChallenge(t, a).Message
+		res := postMulti(t, a, dto.MultiChallengeResponse{
+			Identity:   "alice",
+			Message:    message,
+			Signatures: keySignatures(t, message, devices[0], devices[2], stranger),
+		})
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		multi := dto.MultiSignIn{}
+		json.NewDecoder(res.Body).Decode(&multi)
+		want := []string{
+			dto.EncodeBinary(devices[0].Public().(ed25519.PublicKey)),
+			dto.EncodeBinary(devices[2].Public().(ed25519.PublicKey)),
+		}
+		if len(multi.Verified) != 2 || multi.Verified[0] != want[0] || multi.Verified[1] != want[1] {
+			t.Errorf("expected verified keys to be %v got %v", want, multi.Verified)
+		}
+		if multi.Token == "" {
+			t.Errorf("expected token not to be empty")
+		}
+	})
+
+	t.Run("Test threshold not met", func(t *testing.T) {
+		message := getChallenge(t, a).Message
+		signatures := keySignatures(t, message, devices[0], devices[1])
+		signatures[1].Signature = b64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize))
+		res := postMulti(t, a, dto.MultiChallengeResponse{Identity: "alice", Message: message, Signatures: signatures})
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "threshold_not_met" {
+			t.Errorf("expected error code to be threshold_not_met got %s", code)
+		}
+	})
+
+	t.Run("Test duplicate keys count once", func(t *testing.T) {
+		message := getChallenge(t, a).Message
+		signatures := keySignatures(t, message, devices[1], devices[1])
+		signatures[1].PublicKey = b64.RawURLEncoding.EncodeToString(devices[1].Public().(ed25519.PublicKey))
+		res := postMulti(t, a, dto.MultiChallengeResponse{Identity: "alice", Message: message, Signatures: signatures})
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "threshold_not_met" {
+			t.Errorf("expected error code to be threshold_not_met got %s", code)
+		}
+	})
+
+	t.Run("Test unknown identity", func(t *testing.T) {
+		message := getChallenge(t, a).Message
+		res := postMulti(t, a, dto.MultiChallengeResponse{
+			Identity:   "bob",
+			Message:    message,
+			Signatures: keySignatures(t, message, devices[0], devices[1]),
+		})
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "unknown_identity" {
+			t.Errorf("expected error code to be unknown_identity got %s", code)
+		}
+	})
+}
+
+func TestLoadIdentities(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Test valid identities", func(t *testing.T) {
+		path := filepath.Join(dir, "identities.json")
+		os.WriteFile(path, []byte(`{"alice":{"threshold":1,"keys":["a2V5"]}}`), 0o600)
+		identities, err := loadIdentities(path)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if identities["alice"].Threshold != 1 {
+			t.Errorf("expected threshold to be 1 got %d", identities["alice"].Threshold)
+		}
+	})
+
+	t.Run("Test threshold above key count", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.json")
+		os.WriteFile(path, []byte(`{"alice":{"threshold":2,"keys":["a2V5"]}}`), 0o600)
+		_, err := loadIdentities(path)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Media types a challenge or token can be answered with. mediaText is the
+// bare challenge message or token, for clients that don't parse JSON.
+const (
+	mediaJSON = "application/json"
+	mediaText = "text/plain"
+)
+
+// negotiate picks mediaJSON or mediaText by r's Accept header, preferring
+// the higher q-value and, on a tie, JSON. No Accept header means JSON. ok is
+// false when Accept rules out both.
+func negotiate(r *http.Request) (media string, ok bool) {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return mediaJSON, true
+	}
+	var jsonQ, textQ float64
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			mediaRange, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				q, err = strconv.ParseFloat(v, 64)
+				if err != nil {
+					continue
+				}
+			}
+			switch mediaRange {
+			case mediaJSON, "application/*":
+				jsonQ = max(jsonQ, q)
+			case mediaText, "text/*":
+				textQ = max(textQ, q)
+			case "*/*":
+				jsonQ = max(jsonQ, q)
+				textQ = max(textQ, q)
+			}
+		}
+	}
+	switch {
+	case jsonQ > 0 && jsonQ >= textQ:
+		return mediaJSON, true
+	case textQ > 0:
+		return mediaText, true
+	}
+	return "", false
+}
+
+// notAcceptable answers 406 for a request negotiate found no media type for.
+func notAcceptable(w http.ResponseWriter) {
+	writeError(w, http.StatusNotAcceptable, "not_acceptable", "responses are application/json or text/plain")
+}
+
+// writeText answers 200 with s as a text/plain body.
+func writeText(w http.ResponseWriter, s string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(s))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		media  string
+		ok     bool
+	}{
+		{"", mediaJSON, true},
+		{"application/json", mediaJSON, true},
+		{"text/plain", mediaText, true},
+		{"text/plain; charset=utf-8", mediaText, true},
+		{"*/*", mediaJSON, true},
+		{"text/*", mediaText, true},
+		{"application/json;q=0.5, text/plain", mediaText, true},
+		{"text/plain;q=0.5, application/json", mediaJSON, true},
+		{"text/html, */*;q=0.1", mediaJSON, true},
+		{"text/html", "", false},
+		{"application/json;q=0", "", false},
+	}
+	for _, tt := range tests {
+		t.Run("Test "+tt.accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/signIn", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			media, ok := negotiate(req)
+			if media != tt.media || ok != tt.ok {
+				t.Errorf("expected %q, %v got %q, %v", tt.media, tt.ok, media, ok)
+			}
+		})
+	}
+}
+
+func TestSignInAccept(t *testing.T) {
+	a := newTestApp(t)
+	signIn := func(t *testing.T, method, accept string, body []byte) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, "/signIn", bytes.NewReader(body))
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		a.signIn(w, req)
+		return w.Result()
+	}
+	signedResponse := func(t *testing.T) []byte {
+		t.Helper()
+		body, _ := json.Marshal(signChallenge(t, getChallenge(t, a).Message))
+		return body
+	}
+
+	t.Run("Test GET with application/json", func(t *testing.T) {
+		res := signIn(t, http.MethodGet, "application/json", nil)
+		defer res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected content type to be application/json got %s", ct)
+		}
+		challenge := dto.Challenge{}
+		err := json.NewDecoder(res.Body).Decode(&challenge)
+		if err != nil || challenge.Message == "" {
+			t.Errorf("expected a challenge got %+v, %v", challenge, err)
+		}
+	})
+
+	t.Run("Test GET with text/plain", func(t *testing.T) {
+		res := signIn(t, http.MethodGet, "text/plain", nil)
+		defer res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("expected content type to be text/plain got %s", ct)
+		}
+		message, _ := io.ReadAll(res.Body)
+		res2 := postSignIn(t, a, signChallenge(t, string(message)))
+		defer res2.Body.Close()
+		if res2.StatusCode != http.StatusOK {
+			t.Errorf("expected the bare message to sign in got %d", res2.StatusCode)
+		}
+	})
+
+	t.Run("Test POST with application/json", func(t *testing.T) {
+		res := signIn(t, http.MethodPost, "application/json", signedResponse(t))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		decodeToken(t, res)
+	})
+
+	t.Run("Test POST with text/plain", func(t *testing.T) {
+		res := signIn(t, http.MethodPost, "text/plain", signedResponse(t))
+		defer res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("expected content type to be text/plain got %s", ct)
+		}
+		token, _ := io.ReadAll(res.Body)
+		_, err := jws.Decode(string(token))
+		if err != nil {
+			t.Errorf("expected a bare token got %q: %v", token, err)
+		}
+	})
+
+	t.Run("Test unsupported Accept", func(t *testing.T) {
+		for _, method := range []string{http.MethodGet, http.MethodPost} {
+			res := signIn(t, method, "text/html", signedResponse(t))
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusNotAcceptable {
+				t.Errorf("expected status code of %s to be 406 got %d", method, res.StatusCode)
+			}
+			if code := errorCode(t, res); code != "not_acceptable" {
+				t.Errorf("expected error code to be not_acceptable got %s", code)
+			}
+		}
+	})
+}
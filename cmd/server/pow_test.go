@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+)
+
+// solveWork returns a nonce that solves difficulty for message.
+func solveWork(t *testing.T, message string, difficulty int) string {
+	t.Helper()
+	nonce, err := challenge.SolveWork(context.Background(), message, difficulty)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return nonce
+}
+
+func TestProofOfWork(t *testing.T) {
+	a := newTestApp(t)
+	a.difficulty = 12
+
+	t.Run("Test challenge advertises the difficulty", func(t *testing.T) {
+		if ch := getChallenge(t, a); ch.Difficulty != 12 {
+			t.Errorf("expected difficulty to be 12 got %d", ch.Difficulty)
+		}
+	})
+
+	t.Run("Test valid proof of work", func(t *testing.T) {
+		ch := getChallenge(t, a)
+		response := signChallenge(t, ch.Message)
+		response.Nonce = solveWork(t, ch.Message, ch.Difficulty)
+		res := postSignIn(t, a, response)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test insufficient proof of work", func(t *testing.T) {
+		ch := getChallenge(t, a)
+		nonce := solveWork(t, ch.Message, 4)
+		for challenge.SolvesWork(ch.Message, nonce, ch.Difficulty) {
+			nonce += "0"
+		}
+		response := signChallenge(t, ch.Message)
+		response.Nonce = nonce
+		res := postSignIn(t, a, response)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "insufficient_work" {
+			t.Errorf("expected code insufficient_work got %s", code)
+		}
+	})
+
+	t.Run("Test failed work leaves the challenge unconsumed", func(t *testing.T) {
+		ch := getChallenge(t, a)
+		res := postSignIn(t, a, signChallenge(t, ch.Message))
+		res.Body.Close()
+		response := signChallenge(t, ch.Message)
+		response.Nonce = solveWork(t, ch.Message, ch.Difficulty)
+		res = postSignIn(t, a, response)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test no work without a difficulty", func(t *testing.T) {
+		b := newTestApp(t)
+		res := postSignIn(t, b, signChallenge(t, getChallenge(t, b).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/skip2/go-qrcode"
+)
+
+// challengeURIPrefix starts the URI GET /signIn?format=qr hands out for a
+// mobile app to scan.
+const challengeURIPrefix = "ed25519poc://challenge"
+
+// mediaPNG is what GET /signIn?format=qr renders the challenge URI as when
+// Accept asks for it.
+const mediaPNG = "image/png"
+
+// qrSize is the width and height, in pixels, of the QR codes served.
+const qrSize = 256
+
+// challengeURI encodes ch compactly enough for a QR code: its message as m,
+// its expiry as exp and, when one is asked for, its proof of work difficulty
+// as pow.
+func challengeURI(ch dto.Challenge) string {
+	uri := challengeURIPrefix + "?m=" + url.QueryEscape(ch.Message) + "&exp=" + strconv.FormatInt(ch.ExpiresAt, 10)
+	if ch.Difficulty > 0 {
+		uri += "&pow=" + strconv.Itoa(ch.Difficulty)
+	}
+	return uri
+}
+
+// acceptsPNG reports whether r's Accept header asks for image/png by name.
+func acceptsPNG(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			mediaRange, params, err := mime.ParseMediaType(part)
+			if err != nil || mediaRange != mediaPNG {
+				continue
+			}
+			q, err := strconv.ParseFloat(params["q"], 64)
+			if params["q"] == "" || (err == nil && q > 0) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeChallengeQR answers GET /signIn?format=qr with ch's URI, as a PNG QR
+// code when r accepts one and as text otherwise.
+func writeChallengeQR(w http.ResponseWriter, r *http.Request, ch dto.Challenge) {
+	uri := challengeURI(ch)
+	if !acceptsPNG(r) {
+		writeText(w, uri)
+		return
+	}
+	png, err := qrcode.Encode(uri, qrcode.Medium, qrSize)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error rendering QR code")
+		return
+	}
+	w.Header().Set("Content-Type", mediaPNG)
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func getQRChallenge(t *testing.T, a *app, target, accept string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	a.signIn(w, req)
+	return w.Result()
+}
+
+func TestChallengeQR(t *testing.T) {
+	a := newTestApp(t)
+
+	t.Run("Test URI parses back to the challenge", func(t *testing.T) {
+		res := getQRChallenge(t, a, "/signIn?format=qr", "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		body, _ := io.ReadAll(res.Body)
+		uri, err := url.Parse(string(body))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if uri.Scheme != "ed25519poc" || uri.Host != "challenge" {
+			t.Errorf("expected an ed25519poc://challenge URI got %s", uri)
+		}
+		exp, err := strconv.ParseInt(uri.Query().Get("exp"), 10, 64)
+		if err != nil || exp <= time.Now().Unix() {
+			t.Errorf("expected exp to be in the future got %s", uri.Query().Get("exp"))
+		}
+		signIn := postSignIn(t, a, signChallenge(t, uri.Query().Get("m")))
+		defer signIn.Body.Close()
+		if signIn.StatusCode != http.StatusOK {
+			t.Errorf("expected the URI's challenge to sign in got %d", signIn.StatusCode)
+		}
+	})
+
+	t.Run("Test PNG QR code", func(t *testing.T) {
+		res := getQRChallenge(t, a, "/signIn?format=qr", "image/png")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		if ct := res.Header.Get("Content-Type"); ct != "image/png" {
+			t.Errorf("expected content type image/png got %s", ct)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if !bytes.HasPrefix(body, []byte("\x89PNG\r\n\x1a\n")) {
+			t.Errorf("expected PNG magic bytes got %q", body[:min(8, len(body))])
+		}
+	})
+
+	t.Run("Test proof of work difficulty is carried", func(t *testing.T) {
+		b := newTestApp(t)
+		b.difficulty = 8
+		res := getQRChallenge(t, b, "/signIn?format=qr", "")
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		uri, _ := url.Parse(string(body))
+		if got := uri.Query().Get("pow"); got != "8" {
+			t.Errorf("expected pow to be 8 got %s", got)
+		}
+	})
+
+	t.Run("Test sealed challenge is refused", func(t *testing.T) {
+		res := getQRChallenge(t, a, "/signIn?format=qr&x25519PublicKey=abc", "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// readinessInterval is how often the signing check is repeated after startup.
+const readinessInterval = 30 * time.Second
+
+var errNotChecked = errors.New("signing check has not run yet")
+
+// readiness reports whether the server is able to sign tokens. It runs a
+// test sign+verify at startup and periodically, and keeps the last outcome
+// so /readyz can answer without doing any crypto on the request path.
+type readiness struct {
+	probe func() error
+
+	mu        sync.RWMutex
+	lastCheck time.Time
+	lastErr   error
+}
+
+func newReadiness(probe func() error) *readiness {
+	return &readiness{
+		probe:   probe,
+		lastErr: errNotChecked,
+	}
+}
+
+// check runs the probe once and records its outcome.
+func (rd *readiness) check() {
+	err := rd.probe()
+	rd.mu.Lock()
+	rd.lastCheck = time.Now()
+	rd.lastErr = err
+	rd.mu.Unlock()
+}
+
+// run repeats the check every interval until stop is closed.
+func (rd *readiness) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rd.check()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// status returns the time and outcome of the last check.
+func (rd *readiness) status() (time.Time, error) {
+	rd.mu.RLock()
+	defer rd.mu.RUnlock()
+	return rd.lastCheck, rd.lastErr
+}
+
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	lastCheck, lastErr := rd.status()
+	res := dto.Readiness{
+		Status:    "ok",
+		LastCheck: lastCheck,
+	}
+	status := http.StatusOK
+	if lastErr != nil {
+		res.Status = "unavailable"
+		res.Error = lastErr.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling readiness")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// healthz is the liveness probe: the process is up and has a signing key.
+// Unlike /readyz it does no crypto, not even a cached result of it.
+func (a *app) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	res := dto.Health{Status: "ok"}
+	status := http.StatusOK
+	if signingKey, _ := a.keys.Active(); signingKey == nil {
+		res.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+
+	body, err := json.Marshal(res)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling health")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func readyz(t *testing.T, rd *readiness) (int, dto.Readiness) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	rd.ServeHTTP(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+
+	body := dto.Readiness{}
+	err := json.NewDecoder(res.Body).Decode(&body)
+	if err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+	return res.StatusCode, body
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("Test ready with working signer", func(t *testing.T) {
+		rd := newReadiness(newTestApp(t).signingProbe)
+		rd.check()
+		status, body := readyz(t, rd)
+		if status != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", status)
+		}
+		if body.Status != "ok" {
+			t.Errorf("expected status to be ok got %s", body.Status)
+		}
+		if body.LastCheck.IsZero() {
+			t.Errorf("expected last check time to be set")
+		}
+	})
+
+	t.Run("Test not ready before first check", func(t *testing.T) {
+		rd := newReadiness(newTestApp(t).signingProbe)
+		status, _ := readyz(t, rd)
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("expected status code to be 503 got %d", status)
+		}
+	})
+
+	t.Run("Test not ready with broken signer", func(t *testing.T) {
+		brokenSigner := func(data []byte) ([]byte, error) {
+			return nil, errors.New("kms unavailable")
+		}
+		probe := func() error {
+			_, err := jws.EncodeWithSigner(&jws.Header{Algorithm: "RS256", Typ: "JWT"}, &jws.ClaimSet{}, brokenSigner)
+			return err
+		}
+		rd := newReadiness(probe)
+		rd.check()
+		status, body := readyz(t, rd)
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("expected status code to be 503 got %d", status)
+		}
+		if body.Error != "kms unavailable" {
+			t.Errorf("expected error to be kms unavailable got %s", body.Error)
+		}
+		if body.LastCheck.IsZero() {
+			t.Errorf("expected last check time to be set")
+		}
+	})
+}
+
+func TestHealthz(t *testing.T) {
+	healthz := func(t *testing.T, a *app) (int, dto.Health) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		w := httptest.NewRecorder()
+		a.healthz(w, req)
+		res := w.Result()
+		defer res.Body.Close()
+
+		body := dto.Health{}
+		err := json.NewDecoder(res.Body).Decode(&body)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		return res.StatusCode, body
+	}
+
+	t.Run("Test healthy with signing key", func(t *testing.T) {
+		status, body := healthz(t, newTestApp(t))
+		if status != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", status)
+		}
+		if body.Status != "ok" {
+			t.Errorf("expected status to be ok got %s", body.Status)
+		}
+	})
+
+	t.Run("Test unhealthy without signing key", func(t *testing.T) {
+		status, body := healthz(t, newTestAppWithKey(t, nil, jws.Header{}))
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("expected status code to be 503 got %d", status)
+		}
+		if body.Status != "unavailable" {
+			t.Errorf("expected status to be unavailable got %s", body.Status)
+		}
+	})
+}
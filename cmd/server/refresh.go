@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// defaultRefreshWindow is how close to its exp a token must be before
+// /refresh renews it.
+const defaultRefreshWindow = 10 * time.Minute
+
+// bearerToken returns the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// verifyToken checks that token was signed by one of the server's trusted
+// keys, is past its nbf and has neither expired nor been revoked, and
+// returns its claims.
+func (a *app) verifyToken(token string) (*jws.ClaimSet, error) {
+	err := a.keys.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := jws.Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if claims.Exp <= now {
+		return nil, jws.ErrTokenExpired
+	}
+	if claims.Nbf > now {
+		return nil, jws.ErrTokenNotBefore
+	}
+	if a.isRevoked(claims) {
+		logRevoked(token)
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+// refresh renews a token issued by this server once it is within
+// refreshWindow of its exp. The new token keeps the subject and scope and is
+// delivered as sign-in delivers tokens, bare when Accept asks for
+// text/plain. The old one may come in the session cookie instead of the
+// Authorization header.
+func (a *app) refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+	media, ok := negotiate(r)
+	if !ok {
+		notAcceptable(w)
+		return
+	}
+
+	token, ok := requestToken(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing_token", "missing bearer token")
+		return
+	}
+	claims, err := a.verifyToken(token)
+	if errors.Is(err, jws.ErrTokenExpired) {
+		writeError(w, http.StatusUnauthorized, "token_expired", "token is expired")
+		return
+	} else if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_token", "token does not verify")
+		return
+	}
+
+	now := time.Now()
+	if time.Unix(claims.Exp, 0).Sub(now) > a.refreshWindow {
+		writeError(w, http.StatusBadRequest, "not_refreshable", "token is not within the renewal window")
+		return
+	}
+
+	token, err = a.mint(r.Context(), jws.NewClaimSet().Subject(claims.Sub).Scope(claims.Scope).TTL(a.tokenTTL))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error generating token")
+		return
+	}
+	if a.tokenDelivery == tokenDeliveryCookie {
+		a.setSessionCookie(w, token)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if media == mediaText {
+		writeText(w, token)
+		return
+	}
+
+	res, err := json.Marshal(dto.Jws{Token: token})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling token")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func postRefresh(t *testing.T, a *app, token string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.refresh(w, req)
+	return w.Result()
+}
+
+func TestRefresh(t *testing.T) {
+	a := newTestApp(t)
+	mintExpiring := func(t *testing.T, in time.Duration) string {
+		t.Helper()
+		now := time.Now()
+		token, err := a.signClaims(context.Background(), &jws.ClaimSet{
+			Sub:   "device",
+			Scope: "read",
+			Iat:   now.Add(in - a.tokenTTL).Unix(),
+			Exp:   now.Add(in).Unix(),
+		})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		return token
+	}
+
+	t.Run("Test refresh within the renewal window", func(t *testing.T) {
+		old := mintExpiring(t, time.Minute)
+		res := postRefresh(t, a, old)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+
+		jwsPayload := dto.Jws{}
+		json.NewDecoder(res.Body).Decode(&jwsPayload)
+		oldClaims, _ := jws.Decode(old)
+		claims, err := a.verifyToken(jwsPayload.Token)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Exp <= oldClaims.Exp {
+			t.Errorf("expected exp to be after %d got %d", oldClaims.Exp, claims.Exp)
+		}
+		if claims.Iss != oldClaims.Iss || claims.Sub != oldClaims.Sub || claims.Scope != oldClaims.Scope {
+			t.Errorf("expected iss, sub and scope to be preserved got %+v", claims)
+		}
+	})
+
+	t.Run("Test refresh outside the renewal window", func(t *testing.T) {
+		res := postRefresh(t, a, mintExpiring(t, a.tokenTTL))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "not_refreshable" {
+			t.Errorf("expected error code to be not_refreshable got %s", code)
+		}
+	})
+
+	t.Run("Test refresh with expired token", func(t *testing.T) {
+		res := postRefresh(t, a, mintExpiring(t, -time.Minute))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "token_expired" {
+			t.Errorf("expected error code to be token_expired got %s", code)
+		}
+	})
+
+	t.Run("Test refresh with token from another key", func(t *testing.T) {
+		res := postRefresh(t, a, mintToken(t, time.Now().Add(time.Minute).Unix()))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test refresh without authorization header", func(t *testing.T) {
+		res := postRefresh(t, a, "")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "missing_token" {
+			t.Errorf("expected error code to be missing_token got %s", code)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// reloadSigningKey loads the signing key at path and makes it the active
+// key. The previous key stays trusted, so tokens it signed keep verifying.
+// Minting and verifying go through a.keys, which swaps both under its lock.
+func (a *app) reloadSigningKey(path string) error {
+	key, header, err := jws.LoadSigningKey(path)
+	if err != nil {
+		return err
+	}
+	a.keys.Promote(key, header)
+	return nil
+}
+
+// reloadOnHangup reloads the signing key from path for every signal on
+// hangup until it is closed. A key that fails to load leaves the active key
+// in place.
+func reloadOnHangup(a *app, path string, hangup <-chan os.Signal) {
+	for range hangup {
+		err := a.reloadSigningKey(path)
+		if err != nil {
+			fmt.Printf("error reloading signing key: %s\n", err)
+			continue
+		}
+		_, header := a.keys.Active()
+		fmt.Printf("signing key reloaded, active kid %s\n", header.KeyID)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// writeSigningKey writes a fresh Ed25519 signing key to path and returns
+// the kid tokens signed with it carry.
+func writeSigningKey(t *testing.T, path string) string {
+	t.Helper()
+	_, priv := testutil.DeterministicEd25519(38)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	_, header, err := jws.LoadSigningKey(path)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return header.KeyID
+}
+
+func TestReloadSigningKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	writeSigningKey(t, path)
+	key, header, _ := jws.LoadSigningKey(path)
+	a := newTestAppWithKey(t, key, header)
+	oldToken := signInToken(t, a)
+
+	t.Run("Test new tokens use the reloaded key", func(t *testing.T) {
+		kid := writeSigningKey(t, path)
+		err := a.reloadSigningKey(path)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if got := tokenHeader(t, signInToken(t, a)).KeyID; got != kid {
+			t.Errorf("expected kid to be %s got %s", kid, got)
+		}
+		_, err = a.verifyToken(oldToken)
+		if err != nil {
+			t.Errorf("expected old token to verify got %v", err)
+		}
+	})
+
+	t.Run("Test broken key file keeps the active key", func(t *testing.T) {
+		_, before := a.keys.Active()
+		os.WriteFile(path, []byte("not a key"), 0o600)
+		err := a.reloadSigningKey(path)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+		if _, after := a.keys.Active(); after.KeyID != before.KeyID {
+			t.Errorf("expected kid to stay %s got %s", before.KeyID, after.KeyID)
+		}
+	})
+
+	t.Run("Test hangup reloads the key", func(t *testing.T) {
+		kid := writeSigningKey(t, path)
+		hangup := make(chan os.Signal)
+		done := make(chan struct{})
+		go func() {
+			reloadOnHangup(a, path, hangup)
+			close(done)
+		}()
+		hangup <- os.Interrupt
+		close(hangup)
+		<-done
+		if _, header := a.keys.Active(); header.KeyID != kid {
+			t.Errorf("expected kid to be %s got %s", kid, header.KeyID)
+		}
+	})
+
+	t.Run("Test tokens minted during reloads verify", func(t *testing.T) {
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					token := signInToken(t, a)
+					_, err := a.verifyToken(token)
+					if err != nil {
+						t.Errorf("expected error to be nil got %v", err)
+						return
+					}
+				}
+			}()
+		}
+		for i := 0; i < 5; i++ {
+			writeSigningKey(t, path)
+			a.reloadSigningKey(path)
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(stop)
+		wg.Wait()
+	})
+}
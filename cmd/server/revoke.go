@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+var errTokenRevoked = errors.New("token has been revoked")
+
+// newTokenID returns a random jti for a minted token.
+func newTokenID() (string, error) {
+	var b [16]byte
+	_, err := io.ReadFull(rand.Reader, b[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// isRevoked reports whether the token with claims was revoked, by its jti or
+// along with every token for its subject.
+func (a *app) isRevoked(claims *jws.ClaimSet) bool {
+	if claims.Jti != "" && a.revoked.IsRevoked(claims.Jti) {
+		return true
+	}
+	return a.revoked.IsSubjectRevoked(claims.Sub, claims.Iat)
+}
+
+// logRevoked records that token, though its signature verified, was turned
+// away by the deny list.
+func logRevoked(token string) {
+	vc := jws.NewVerificationContext(token, jws.KeySourceDenyList)
+	vc.Err = errTokenRevoked
+	jws.LogVerification(vc)
+}
+
+// revoke answers POST /revoke: the token's jti is blacklisted until the token
+// expires, so /verify and the other token checks reject it. The token may come
+// in the session cookie instead of the Authorization header.
+func (a *app) revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	token, ok := requestToken(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing_token", "missing bearer token")
+		return
+	}
+	claims, err := a.verifyToken(token)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_token", "token does not verify")
+		return
+	}
+	if claims.Jti == "" {
+		writeError(w, http.StatusBadRequest, "invalid_token", "token has no jti")
+		return
+	}
+
+	a.revoked.Revoke(claims.Jti, claims.Exp)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"crypto/ecdh"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// sealChallenge encrypts message to the base64url (or std base64) X25519
+// public key x25519Key. The client opens it and answers the plaintext challenge as
+// usual.
+func sealChallenge(x25519Key string, message string) (dto.Challenge, error) {
+	raw, err := dto.DecodeBinary(x25519Key)
+	if err != nil {
+		return dto.Challenge{}, err
+	}
+	clientKey, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return dto.Challenge{}, err
+	}
+	sealed, err := challenge.Seal(clientKey, message)
+	if err != nil {
+		return dto.Challenge{}, err
+	}
+	challenge := dto.Challenge{}
+	challenge.SetSealed(sealed.EphemeralPublicKey, sealed.Nonce, sealed.Ciphertext)
+	return challenge, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+)
+
+// challengeSessionCookie ties the challenges GET /signIn issues, with
+// -bind-session, to the client that fetched them. It is apart from
+// sessionCookie, which carries tokens.
+const challengeSessionCookie = "challenge_session"
+
+// challengeSession returns the session id the challenge GET /signIn is about
+// to issue belongs to: the one r already presents, so challenges fetched in
+// parallel can all be answered, or a fresh one set on w. It is "" without
+// -bind-session.
+func (a *app) challengeSession(w http.ResponseWriter, r *http.Request) (string, *signInError) {
+	if !a.bindSession {
+		return "", nil
+	}
+	cookie, err := r.Cookie(challengeSessionCookie)
+	if err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+	session, err := newTokenID()
+	if err != nil {
+		return "", &signInError{http.StatusInternalServerError, "internal_error", "error generating session"}
+	}
+	// Enrollment proofs answer challenges too, so the cookie goes to
+	// /enroll as well as /signIn.
+	http.SetCookie(w, &http.Cookie{
+		Name:     challengeSessionCookie,
+		Value:    session,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return session, nil
+}
+
+// postedSession returns the session id a challenge response was posted in,
+// which must be the one its challenge was issued to. With -bind-session a
+// request without one is refused; without it, it is "".
+func (a *app) postedSession(r *http.Request) (string, *signInError) {
+	if !a.bindSession {
+		return "", nil
+	}
+	cookie, err := r.Cookie(challengeSessionCookie)
+	if err != nil || cookie.Value == "" {
+		return "", &signInError{http.StatusBadRequest, "missing_session", "challenge session cookie is missing"}
+	}
+	return cookie.Value, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// getSessionChallenge requests a challenge, presenting cookie when it isn't
+// nil, and returns it with the challenge session cookie the response sets.
+func getSessionChallenge(t *testing.T, a *app, cookie *http.Cookie) (dto.Challenge, *http.Cookie) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/signIn", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	a.signIn(w, req)
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+	}
+	ch := dto.Challenge{}
+	json.NewDecoder(res.Body).Decode(&ch)
+	for _, c := range res.Cookies() {
+		if c.Name == challengeSessionCookie {
+			return ch, c
+		}
+	}
+	return ch, nil
+}
+
+// postSessionSignIn posts response, presenting cookie when it isn't nil.
+func postSessionSignIn(t *testing.T, a *app, response dto.ChallengeResponse, cookie *http.Cookie) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(response)
+	req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewReader(body))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	a.signIn(w, req)
+	return w.Result()
+}
+
+func TestBindSession(t *testing.T) {
+	a := newTestApp(t)
+	a.bindSession = true
+
+	t.Run("Test matching session", func(t *testing.T) {
+		ch, cookie := getSessionChallenge(t, a, nil)
+		if cookie == nil {
+			t.Fatalf("expected a challenge session cookie")
+		}
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+			t.Errorf("expected a Secure HttpOnly SameSite=Strict cookie got %v", cookie)
+		}
+		res := postSessionSignIn(t, a, signChallenge(t, ch.Message), cookie)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test presented session is kept", func(t *testing.T) {
+		_, cookie := getSessionChallenge(t, a, nil)
+		first, again := getSessionChallenge(t, a, cookie)
+		if again != nil {
+			t.Errorf("expected no new cookie got %v", again)
+		}
+		second, _ := getSessionChallenge(t, a, cookie)
+		for _, ch := range []dto.Challenge{first, second} {
+			res := postSessionSignIn(t, a, signChallenge(t, ch.Message), cookie)
+			res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+			}
+		}
+	})
+
+	t.Run("Test mismatching session", func(t *testing.T) {
+		ch, _ := getSessionChallenge(t, a, nil)
+		_, other := getSessionChallenge(t, a, nil)
+		res := postSessionSignIn(t, a, signChallenge(t, ch.Message), other)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "session_mismatch" {
+			t.Errorf("expected code session_mismatch got %s", code)
+		}
+	})
+
+	t.Run("Test missing session", func(t *testing.T) {
+		ch, _ := getSessionChallenge(t, a, nil)
+		res := postSessionSignIn(t, a, signChallenge(t, ch.Message), nil)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status code to be 400 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "missing_session" {
+			t.Errorf("expected code missing_session got %s", code)
+		}
+	})
+
+	t.Run("Test no cookie without -bind-session", func(t *testing.T) {
+		_, cookie := getSessionChallenge(t, newTestApp(t), nil)
+		if cookie != nil {
+			t.Errorf("expected no challenge session cookie got %v", cookie)
+		}
+	})
+}
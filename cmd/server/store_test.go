@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+)
+
+func TestRedisChallengeStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cfg, err := parseConfig([]string{"-store", "redis", "-redis-addr", mr.Addr()}, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	// Two instances behind a load balancer: one issues, the other verifies.
+	newInstance := func(t *testing.T) *app {
+		t.Helper()
+		challenges, err := newChallengeStore(cfg)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		t.Cleanup(challenges.Close)
+		key, header, _ := loadSigningKey("")
+		a := newApp(challenges, key, header)
+		a.requireEnrollment = false
+		return a
+	}
+	a, b := newInstance(t), newInstance(t)
+
+	challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+	res := postSignIn(t, b, challengeResponse)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	res2 := postSignIn(t, a, challengeResponse)
+	defer res2.Body.Close()
+	if res2.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code to be 401 got %d", res2.StatusCode)
+	}
+}
+
+func TestStatelessChallengeStore(t *testing.T) {
+	secret := strings.Repeat("s", challenge.MinSecretSize)
+	cfg, err := parseConfig([]string{"-store", "stateless"}, func(key string) string {
+		if key == "CHALLENGE_SECRET" {
+			return secret
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	// No shared storage: the instances only share the secret.
+	newInstance := func(t *testing.T) *app {
+		t.Helper()
+		challenges, err := newChallengeStore(cfg)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		t.Cleanup(challenges.Close)
+		key, header, _ := loadSigningKey("")
+		a := newApp(challenges, key, header)
+		a.requireEnrollment = false
+		return a
+	}
+	a, b := newInstance(t), newInstance(t)
+
+	challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+	res := postSignIn(t, b, challengeResponse)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	res2 := postSignIn(t, b, challengeResponse)
+	defer res2.Body.Close()
+	if res2.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code to be 401 got %d", res2.StatusCode)
+	}
+}
+
+func TestSharedUsedChallenges(t *testing.T) {
+	mr := miniredis.RunT(t)
+	secret := strings.Repeat("s", challenge.MinSecretSize)
+	cfg, err := parseConfig([]string{"-store", "stateless", "-challenge-secret", secret, "-shared-used-challenges", "-redis-addr", mr.Addr()}, func(string) string { return "" })
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	// Two replicas sharing the secret and only the used marks in Redis.
+	newInstance := func(t *testing.T) *app {
+		t.Helper()
+		challenges, err := newChallengeStore(cfg)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		t.Cleanup(challenges.Close)
+		key, header, _ := loadSigningKey("")
+		a := newApp(challenges, key, header)
+		a.requireEnrollment = false
+		return a
+	}
+	a, b := newInstance(t), newInstance(t)
+
+	challengeResponse := signChallenge(t, getChallenge(t, a).Message)
+	res := postSignIn(t, a, challengeResponse)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	res2 := postSignIn(t, b, challengeResponse)
+	defer res2.Body.Close()
+	if res2.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected the other replica to reject the reused challenge got %d", res2.StatusCode)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	b64 "encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// signTimestamped answers message with a response timestamped at ts.
+func signTimestamped(t *testing.T, message string, ts time.Time) dto.ChallengeResponse {
+	t.Helper()
+	pub, priv := testutil.DeterministicEd25519(39)
+	signature, err := challenge.DefaultMode.Sign(priv, challenge.SignedMessage(message, ts.Unix()))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return dto.ChallengeResponse{
+		Signature: b64.StdEncoding.EncodeToString(signature),
+		Message:   message,
+		PublicKey: b64.StdEncoding.EncodeToString(pub),
+		Timestamp: ts.Unix(),
+	}
+}
+
+func TestSignInTimestamp(t *testing.T) {
+	a := newTestApp(t)
+
+	tests := []struct {
+		name   string
+		ts     time.Time
+		status int
+		code   string
+	}{
+		{"Test in-window timestamp", time.Now().Add(-5 * time.Second), http.StatusOK, ""},
+		{"Test too old timestamp", time.Now().Add(-time.Minute), http.StatusUnauthorized, "stale_timestamp"},
+		{"Test future timestamp", time.Now().Add(time.Minute), http.StatusUnauthorized, "stale_timestamp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := postSignIn(t, a, signTimestamped(t, getChallenge(t, a).Message, tt.ts))
+			defer res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("expected status code to be %d got %d", tt.status, res.StatusCode)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, res); code != tt.code {
+					t.Errorf("expected error code to be %s got %s", tt.code, code)
+				}
+			}
+		})
+	}
+
+	t.Run("Test signature must cover the timestamp", func(t *testing.T) {
+		challengeResponse := signTimestamped(t, getChallenge(t, a).Message, time.Now())
+		challengeResponse.Timestamp++
+		res := postSignIn(t, a, challengeResponse)
+		defer res.Body.Close()
+		if code := errorCode(t, res); code != "invalid_signature" {
+			t.Errorf("expected error code to be invalid_signature got %s", code)
+		}
+	})
+
+	t.Run("Test zero timestamp keeps the plain message", func(t *testing.T) {
+		res := postSignIn(t, a, signChallenge(t, getChallenge(t, a).Message))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+}
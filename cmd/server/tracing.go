@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/audit"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the instrumentation scope of the server's spans.
+const tracerName = "github.com/martinsaporiti/ed25519-poc/cmd/server"
+
+// traceRequest starts the server span for r, continuing the trace named by
+// its traceparent header if it has one, and returns r carrying the span.
+func (a *app) traceRequest(r *http.Request, name string) (*http.Request, trace.Span) {
+	ctx := propagation.TraceContext{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := a.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("http.method", r.Method)),
+	)
+	return r.WithContext(ctx), span
+}
+
+// startSpan starts a span for one step of signing in under the one in ctx.
+func (a *app) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return a.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// recordResult records result, an error code or audit.ResultSuccess as in
+// the audit log, on span, marking it failed unless it succeeded.
+func recordResult(span trace.Span, result string) {
+	span.SetAttributes(attribute.String("signin.result", result))
+	if result != audit.ResultSuccess {
+		span.SetStatus(codes.Error, result)
+	}
+}
+
+// endSpan records result on span and ends it.
+func endSpan(span trace.Span, result string) {
+	recordResult(span, result)
+	span.End()
+}
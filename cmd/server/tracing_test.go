@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// traceparent is a remote parent for the sign-in spans, as an upstream
+// service would send it.
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// recordSpans points a's tracer at an in-memory recorder.
+func recordSpans(a *app) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	a.tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer(tracerName)
+	return recorder
+}
+
+// spansByName indexes the ended spans by name.
+func spansByName(recorder *tracetest.SpanRecorder) map[string]sdktrace.ReadOnlySpan {
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	return spans
+}
+
+func spanAttribute(s sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestTracing(t *testing.T) {
+	t.Run("Test sign in span hierarchy", func(t *testing.T) {
+		a := newTestApp(t)
+		ch := getChallenge(t, a)
+		recorder := recordSpans(a)
+
+		body, _ := json.Marshal(signChallenge(t, ch.Message))
+		req := httptest.NewRequest(http.MethodPost, "/signIn", bytes.NewReader(body))
+		req.Header.Set("traceparent", traceparent)
+		w := httptest.NewRecorder()
+		a.signIn(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", w.Code)
+		}
+
+		spans := spansByName(recorder)
+		if len(spans) != 3 {
+			t.Fatalf("expected 3 spans got %d", len(spans))
+		}
+		root, ok := spans["signIn"]
+		if !ok {
+			t.Fatalf("expected a signIn span")
+		}
+		if got := root.Parent().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected signIn to continue the incoming trace got %s", got)
+		}
+		if !root.Parent().IsRemote() {
+			t.Errorf("expected signIn's parent to be remote")
+		}
+		if got := spanAttribute(root, "signin.result"); got != "success" {
+			t.Errorf("expected result to be success got %s", got)
+		}
+		for _, name := range []string{"auth.verify", "jws.sign"} {
+			s, ok := spans[name]
+			if !ok {
+				t.Fatalf("expected a %s span", name)
+			}
+			if s.Parent().SpanID() != root.SpanContext().SpanID() {
+				t.Errorf("expected %s to be a child of signIn", name)
+			}
+		}
+		if got := spanAttribute(spans["jws.sign"], "jws.alg"); got != "RS256" {
+			t.Errorf("expected alg to be RS256 got %s", got)
+		}
+	})
+
+	t.Run("Test challenge issuance span", func(t *testing.T) {
+		a := newTestApp(t)
+		recorder := recordSpans(a)
+		getChallenge(t, a)
+
+		spans := spansByName(recorder)
+		issue, ok := spans["challenge.issue"]
+		if !ok {
+			t.Fatalf("expected a challenge.issue span")
+		}
+		if issue.Parent().SpanID() != spans["signIn"].SpanContext().SpanID() {
+			t.Errorf("expected challenge.issue to be a child of signIn")
+		}
+	})
+
+	t.Run("Test failed verification is recorded", func(t *testing.T) {
+		a := newTestApp(t)
+		recorder := recordSpans(a)
+		res := postSignIn(t, a, signChallenge(t, "unknown"))
+		res.Body.Close()
+
+		spans := spansByName(recorder)
+		verify := spans["auth.verify"]
+		if verify == nil {
+			t.Fatalf("expected an auth.verify span")
+		}
+		if verify.Status().Code != codes.Error {
+			t.Errorf("expected auth.verify to fail got %v", verify.Status().Code)
+		}
+		if got := spanAttribute(spans["signIn"], "signin.result"); got != "invalid_challenge" {
+			t.Errorf("expected result to be invalid_challenge got %s", got)
+		}
+		if _, ok := spans["jws.sign"]; ok {
+			t.Errorf("expected no token to be minted")
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// verify answers POST /verify: it checks the token in the body with
+// verifyToken, so only tokens signed by one of the server's trusted keys
+// pass, and returns its claims, or 401 with the reason. ?pretty=true indents
+// the claims.
+func (a *app) verify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodPost)
+		return
+	}
+
+	body := dto.Jws{}
+	err := decodeJSON(w, r, &body, a.maxBodyBytes)
+	if err != nil {
+		writeDecodeError(w, err, "error unmarshalling verify request")
+		return
+	}
+
+	claims, err := a.verifyToken(body.Token)
+	if errors.Is(err, errTokenRevoked) {
+		writeError(w, http.StatusUnauthorized, "token_revoked", err.Error())
+		return
+	} else if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid_token", err.Error())
+		return
+	}
+
+	res, err := marshalDebug(r, dto.Claims{
+		Iss:   claims.Iss,
+		Sub:   claims.Sub,
+		Aud:   claims.Aud,
+		Scope: claims.Scope,
+		Jti:   claims.Jti,
+		Exp:   claims.Exp,
+		Iat:   claims.Iat,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "internal_error", "error marshalling claims")
+		return
+	}
+	writeDebug(w, res)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func postVerify(t *testing.T, a *app, token string) *http.Response {
+	t.Helper()
+	reqBody, _ := json.Marshal(dto.Jws{Token: token})
+	req := httptest.NewRequest(http.MethodPost, "/verify", bytes.NewBuffer(reqBody))
+	w := httptest.NewRecorder()
+	a.verify(w, req)
+	return w.Result()
+}
+
+func TestVerify(t *testing.T) {
+	a := newTestApp(t)
+	t.Run("Test valid token", func(t *testing.T) {
+		token := signInToken(t, a)
+		res := postVerify(t, a, token)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+		}
+		claims := dto.Claims{}
+		err := json.NewDecoder(res.Body).Decode(&claims)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		want, _ := jws.Decode(token)
+		if claims.Sub != want.Sub {
+			t.Errorf("expected sub to be %s got %s", want.Sub, claims.Sub)
+		}
+		if claims.Exp != want.Exp {
+			t.Errorf("expected exp to be %d got %d", want.Exp, claims.Exp)
+		}
+		if claims.Iss == "" {
+			t.Errorf("expected iss not to be empty")
+		}
+	})
+
+	t.Run("Test expired token", func(t *testing.T) {
+		res := postVerify(t, a, serverToken(t, a, time.Now().Add(-time.Hour).Unix()))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		body := dto.ErrorResponse{}
+		json.NewDecoder(res.Body).Decode(&body)
+		if !strings.Contains(body.Message, "expired") {
+			t.Errorf("expected message to mention expiry got %s", body.Message)
+		}
+	})
+
+	t.Run("Test token from an Ed25519 server key", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(50)
+		header, err := jws.HeaderForKey(pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		b := newTestAppWithKey(t, priv, header)
+		res := postVerify(t, b, signInToken(t, b))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("Test token from a foreign key", func(t *testing.T) {
+		res := postVerify(t, a, mintToken(t, time.Now().Add(time.Hour).Unix()))
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "invalid_token" {
+			t.Errorf("expected error code to be invalid_token got %s", code)
+		}
+	})
+
+	t.Run("Test broken token", func(t *testing.T) {
+		res := postVerify(t, a, "not-a-token")
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "invalid_token" {
+			t.Errorf("expected error code to be invalid_token got %s", code)
+		}
+	})
+}
+
+func TestRevoke(t *testing.T) {
+	a := newTestApp(t)
+	token := signInToken(t, a)
+	unrelated := signInToken(t, a)
+
+	res := postVerify(t, a, token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code to be 200 got %d", res.StatusCode)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/revoke", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	a.revoke(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status code to be 204 got %d", w.Code)
+	}
+
+	t.Run("Test revoked token fails verification", func(t *testing.T) {
+		res := postVerify(t, a, token)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected status code to be 401 got %d", res.StatusCode)
+		}
+		if code := errorCode(t, res); code != "token_revoked" {
+			t.Errorf("expected error code to be token_revoked got %s", code)
+		}
+		if _, err := a.verifyToken(token); err == nil {
+			t.Errorf("expected revoked token to be rejected by verifyToken")
+		}
+	})
+
+	t.Run("Test revoked token is logged as denied", func(t *testing.T) {
+		buf := captureLog(t)
+		a.verifyToken(token)
+		out := buf.String()
+		if !strings.Contains(out, "verification.keySource=deny-list") || !strings.Contains(out, errTokenRevoked.Error()) {
+			t.Errorf("expected a deny-list entry got %s", out)
+		}
+	})
+
+	t.Run("Test unrelated token still verifies", func(t *testing.T) {
+		res := postVerify(t, a, unrelated)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected status code to be 200 got %d", res.StatusCode)
+		}
+	})
+}
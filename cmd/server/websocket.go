@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/audit"
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"golang.org/x/net/websocket"
+)
+
+// wsSignIn serves GET /ws/signIn, the sign-in flow over one WebSocket: it
+// sends a challenge frame, as GET /signIn would answer with, reads the
+// response frame, verifies it as POST /signIn does and sends a token or
+// error frame before closing. ?publicKey and ?x25519PublicKey work as on
+// GET /signIn. The client has until the challenge expires to answer.
+func (a *app) wsSignIn() http.Handler {
+	return websocket.Handler(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ws.MaxPayloadBytes = int(a.maxBodyBytes)
+		r := ws.Request()
+
+		ch, sErr := a.issueChallenge(challenge.Binding{PublicKey: r.URL.Query().Get("publicKey")}, r.URL.Query().Get("x25519PublicKey"))
+		if sErr != nil {
+			sendErrorFrame(ws, sErr.code, sErr.message)
+			return
+		}
+		ws.SetDeadline(time.Unix(ch.ExpiresAt, 0))
+		err := websocket.JSON.Send(ws, dto.Frame{Type: dto.FrameChallenge, Challenge: &ch})
+		if err != nil {
+			return
+		}
+
+		event := audit.AuthEvent{Time: time.Now(), Result: audit.ResultSuccess, ClientIP: clientIP(r)}
+		defer func() { a.audit.LogSignIn(event) }()
+		frame := dto.Frame{}
+		err = websocket.JSON.Receive(ws, &frame)
+		if err != nil || frame.Type != dto.FrameResponse || frame.Response == nil {
+			event.Result = "invalid_request"
+			sendErrorFrame(ws, "invalid_request", "expected a response frame")
+			return
+		}
+		token, sErr := a.completeSignIn(r.Context(), *frame.Response, "", &event)
+		if sErr != nil {
+			event.Result = sErr.code
+			sErr = a.disclosed(sErr)
+			sendErrorFrame(ws, sErr.code, sErr.message)
+			return
+		}
+		websocket.JSON.Send(ws, dto.Frame{Type: dto.FrameToken, Token: token})
+	})
+}
+
+// sendErrorFrame sends an error frame with code and message.
+func sendErrorFrame(ws *websocket.Conn, code, message string) {
+	websocket.JSON.Send(ws, dto.Frame{Type: dto.FrameError, Error: &dto.ErrorResponse{Code: code, Message: message}})
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+	"golang.org/x/net/websocket"
+)
+
+// dialSignIn opens a WebSocket to /ws/signIn on a server for a and reads
+// the challenge frame.
+func dialSignIn(t *testing.T, a *app) (*websocket.Conn, dto.Challenge) {
+	t.Helper()
+	srv := httptest.NewServer(newServer(Config{}, a, newReadiness(a.signingProbe)).Handler)
+	t.Cleanup(srv.Close)
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/signIn", "", srv.URL)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	frame := dto.Frame{}
+	err = websocket.JSON.Receive(ws, &frame)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if frame.Type != dto.FrameChallenge || frame.Challenge == nil {
+		t.Fatalf("expected a challenge frame got %+v", frame)
+	}
+	return ws, *frame.Challenge
+}
+
+func TestWebSocketSignIn(t *testing.T) {
+	a := newTestApp(t)
+	a.detailedErrors = true
+
+	t.Run("Test handshake ends with a token", func(t *testing.T) {
+		ws, ch := dialSignIn(t, a)
+		resp := signChallenge(t, ch.Message)
+		err := websocket.JSON.Send(ws, dto.Frame{Type: dto.FrameResponse, Response: &resp})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+
+		frame := dto.Frame{}
+		err = websocket.JSON.Receive(ws, &frame)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if frame.Type != dto.FrameToken {
+			t.Fatalf("expected a token frame got %+v", frame)
+		}
+		claims, err := a.verifyToken(frame.Token)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Sub != resp.PublicKey {
+			t.Errorf("expected sub to be %s got %s", resp.PublicKey, claims.Sub)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		frame func(t *testing.T, message string) dto.Frame
+		code  string
+	}{
+		{"Test bad signature", func(t *testing.T, message string) dto.Frame {
+			resp := signChallenge(t, message)
+			_, otherKey := testutil.DeterministicEd25519(42)
+			resp.Signature = signChallengeWithKey(t, message, otherKey).Signature
+			return dto.Frame{Type: dto.FrameResponse, Response: &resp}
+		}, "invalid_signature"},
+		{"Test other challenge", func(t *testing.T, _ string) dto.Frame {
+			resp := signChallenge(t, getChallenge(t, a).Message+"00")
+			return dto.Frame{Type: dto.FrameResponse, Response: &resp}
+		}, "invalid_challenge"},
+		{"Test unexpected frame", func(t *testing.T, _ string) dto.Frame {
+			return dto.Frame{Type: dto.FrameToken}
+		}, "invalid_request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, ch := dialSignIn(t, a)
+			websocket.JSON.Send(ws, tt.frame(t, ch.Message))
+			frame := dto.Frame{}
+			err := websocket.JSON.Receive(ws, &frame)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if frame.Type != dto.FrameError || frame.Error == nil || frame.Error.Code != tt.code {
+				t.Errorf("expected an error frame with code %s got %+v", tt.code, frame)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+const usage = `usage:
+  sign sign -key private.pem < claims.json    print a JWS signed with the key
+  sign verify -key public.pem < token         print the claims of a valid token
+  sign verify -trust-store dir < token        the same, with the key named by the token's kid in dir
+  sign decode < token                         print header and claims without verifying`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	key := fs.String("key", "", "PEM key file")
+	trustStore := fs.String("trust-store", "", "directory of PEM and JWK keys, named by kid, to verify with instead of -key")
+	fs.Parse(os.Args[2:])
+
+	var err error
+	switch os.Args[1] {
+	case "sign":
+		err = runSign(*key, os.Stdin, os.Stdout)
+	case "verify":
+		if *trustStore != "" {
+			err = runVerifyTrustStore(*trustStore, os.Stdin, os.Stdout)
+		} else {
+			err = runVerify(*key, os.Stdin, os.Stdout)
+		}
+	case "decode":
+		err = runDecode(os.Stdin, os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runSign reads a claim set as JSON from in and writes a JWS signed with
+// the private key at keyPath to out.
+func runSign(keyPath string, in io.Reader, out io.Writer) error {
+	key, header, err := jws.LoadSigningKey(keyPath)
+	if err != nil {
+		return err
+	}
+	claims := &jws.ClaimSet{}
+	err = json.NewDecoder(in).Decode(claims)
+	if err != nil {
+		return fmt.Errorf("reading claims: %w", err)
+	}
+	token, err := jws.EncodeWithKey(&header, claims, key)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, token)
+	return err
+}
+
+// runVerify reads a token from in, verifies it with the public key at
+// keyPath and writes its claims to out.
+func runVerify(keyPath string, in io.Reader, out io.Writer) error {
+	pub, err := loadPublicKey(keyPath)
+	if err != nil {
+		return err
+	}
+	token, err := readToken(in)
+	if err != nil {
+		return err
+	}
+	err = jws.VerifyWithKey(token, pub)
+	if err != nil {
+		return err
+	}
+	return writeClaims(out, token)
+}
+
+// runVerifyTrustStore is runVerify with the key named by the token's kid
+// among those in the trust store directory dir.
+func runVerifyTrustStore(dir string, in io.Reader, out io.Writer) error {
+	ts, err := jws.LoadTrustStore(dir)
+	if err != nil {
+		return err
+	}
+	token, err := readToken(in)
+	if err != nil {
+		return err
+	}
+	err = jws.VerifyWithResolver(token, ts)
+	if err != nil {
+		return err
+	}
+	return writeClaims(out, token)
+}
+
+// writeClaims writes the claims of token to out.
+func writeClaims(out io.Writer, token string) error {
+	claims, err := jws.Decode(token)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, claims.AllClaims())
+}
+
+// runDecode reads a token from in and writes its header and claims to out.
+// The signature is not checked.
+func runDecode(in io.Reader, out io.Writer) error {
+	token, err := readToken(in)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("token must have 3 parts")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("decoding header: %w", err)
+	}
+	claims, err := jws.Decode(token)
+	if err != nil {
+		return err
+	}
+	return writeJSON(out, map[string]interface{}{
+		"header": json.RawMessage(header),
+		"claims": claims.AllClaims(),
+	})
+}
+
+func readToken(in io.Reader) (string, error) {
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.New("no token on stdin")
+	}
+	return token, nil
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var pretty bytes.Buffer
+	err = json.Indent(&pretty, b, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, pretty.String())
+	return err
+}
+
+// loadPublicKey reads a PEM public key, PKIX or PKCS#1 RSA, from path.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found in %s", path)
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// writeKeys writes the Ed25519 key pair for seed as PEM files to dir.
+func writeKeys(t *testing.T, dir string, seed byte) (string, string) {
+	t.Helper()
+	pub, priv := testutil.DeterministicEd25519(seed)
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	privPath := filepath.Join(dir, "private.pem")
+	pubPath := filepath.Join(dir, "public.pem")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600)
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o600)
+	return privPath, pubPath
+}
+
+func TestSubcommands(t *testing.T) {
+	privPath, pubPath := writeKeys(t, t.TempDir(), 1)
+	exp := time.Now().Add(time.Hour).Unix()
+	claimsJson := fmt.Sprintf(`{"iss":"cli","sub":"device","exp":%d,"role":"admin"}`, exp)
+
+	token := &bytes.Buffer{}
+	err := runSign(privPath, strings.NewReader(claimsJson), token)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	t.Run("Test verify prints the claims", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		err := runVerify(pubPath, bytes.NewReader(token.Bytes()), out)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		claims := map[string]interface{}{}
+		json.Unmarshal(out.Bytes(), &claims)
+		if claims["sub"] != "device" || claims["role"] != "admin" {
+			t.Errorf("expected sub device and role admin got %v", claims)
+		}
+	})
+
+	t.Run("Test verify with another key fails", func(t *testing.T) {
+		_, otherPub := writeKeys(t, t.TempDir(), 2)
+		err := runVerify(otherPub, bytes.NewReader(token.Bytes()), &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test verify with a trust store", func(t *testing.T) {
+		header, err := jws.DecodeHeader(strings.TrimSpace(token.String()))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		pub, err := os.ReadFile(pubPath)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, header.KeyID+".pem"), pub, 0o600)
+
+		out := &bytes.Buffer{}
+		err = runVerifyTrustStore(dir, bytes.NewReader(token.Bytes()), out)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !strings.Contains(out.String(), `"sub": "device"`) {
+			t.Errorf("expected the claims got %s", out)
+		}
+		err = runVerifyTrustStore(t.TempDir(), bytes.NewReader(token.Bytes()), &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("expected a token whose kid isn't in the store to fail")
+		}
+	})
+
+	t.Run("Test decode prints header and claims", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		err := runDecode(bytes.NewReader(token.Bytes()), out)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		decoded := struct {
+			Header map[string]interface{} `json:"header"`
+			Claims map[string]interface{} `json:"claims"`
+		}{}
+		err = json.Unmarshal(out.Bytes(), &decoded)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if decoded.Header["alg"] != "EdDSA" {
+			t.Errorf("expected alg to be EdDSA got %v", decoded.Header["alg"])
+		}
+		if decoded.Claims["iss"] != "cli" {
+			t.Errorf("expected iss to be cli got %v", decoded.Claims["iss"])
+		}
+	})
+
+	t.Run("Test decode rejects garbage", func(t *testing.T) {
+		err := runDecode(strings.NewReader("not a token"), &bytes.Buffer{})
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
@@ -0,0 +1,72 @@
+// Package audit records authentication events for operators who need a
+// trail of who signed in, when and from where.
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// ResultSuccess is the Result of a sign-in that got a token. A failed one
+// carries the error code the client was answered with.
+const ResultSuccess = "success"
+
+// AuthEvent is one sign-in attempt.
+type AuthEvent struct {
+	Time time.Time `json:"time"`
+	// PublicKey is the client's key, std base64 once it verified and as sent
+	// otherwise.
+	PublicKey string `json:"publicKey,omitempty"`
+	// Identity is the identity the key is enrolled under, if any.
+	Identity string `json:"identity,omitempty"`
+	Result   string `json:"result"`
+	ClientIP string `json:"clientIp,omitempty"`
+}
+
+// Logger records authentication events. Implementations must be safe for
+// concurrent use.
+type Logger interface {
+	LogSignIn(event AuthEvent)
+}
+
+// Nop is a Logger that records nothing.
+type Nop struct{}
+
+func (Nop) LogSignIn(AuthEvent) {}
+
+// FileLogger appends events to a file, one JSON object per line.
+type FileLogger struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileLogger opens path for appending, creating it if needed.
+func NewFileLogger(path string) (*FileLogger, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLogger{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// LogSignIn appends event. An event that can't be written is reported
+// through slog rather than failing the sign-in.
+func (l *FileLogger) LogSignIn(event AuthEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.enc.Encode(event)
+	if err != nil {
+		slog.Error("audit: error writing event", slog.String("error", err.Error()))
+	}
+}
+
+// Close closes the underlying file.
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	events := []AuthEvent{
+		{Time: time.Unix(1700000000, 0).UTC(), PublicKey: "a2V5", Identity: "alice", Result: ResultSuccess, ClientIP: "192.0.2.1"},
+		{Time: time.Unix(1700000001, 0).UTC(), PublicKey: "a2V5", Result: "invalid_signature", ClientIP: "192.0.2.1"},
+	}
+
+	// A reopened log is appended to, not truncated.
+	for _, event := range events {
+		l, err := NewFileLogger(path)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		l.LogSignIn(event)
+		err = l.Close()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	defer f.Close()
+	var got []AuthEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		event := AuthEvent{}
+		err := json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("expected each line to be a JSON event got %q: %v", scanner.Text(), err)
+		}
+		got = append(got, event)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("expected %d events got %d", len(events), len(got))
+	}
+	for i := range events {
+		if got[i] != events[i] {
+			t.Errorf("expected event %d to be %+v got %+v", i, events[i], got[i])
+		}
+	}
+}
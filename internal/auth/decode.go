@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+// ErrUndecodableKeyMaterial is returned when no base64 variant decodes s.
+var ErrUndecodableKeyMaterial = errors.New("auth: key material is not base64 or base64url")
+
+// DecodeKeyMaterial decodes a public key or signature sent in any of the
+// base64 and base64url variants, padded or not, as dto.DecodeBinary does.
+func DecodeKeyMaterial(s string) ([]byte, error) {
+	b, err := dto.DecodeBinary(s)
+	if err != nil {
+		return nil, ErrUndecodableKeyMaterial
+	}
+	return b, nil
+}
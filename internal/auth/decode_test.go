@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	b64 "encoding/base64"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestDecodeKeyMaterial(t *testing.T) {
+	// Keys whose encodings differ in both alphabet and padding.
+	var pub ed25519.PublicKey
+	for seed := byte(1); ; seed++ {
+		pub, _ = testutil.DeterministicEd25519(seed)
+		std := b64.StdEncoding.EncodeToString(pub)
+		if bytes.ContainsAny([]byte(std), "+/") {
+			break
+		}
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"Test std", b64.StdEncoding.EncodeToString(pub)},
+		{"Test raw std", b64.RawStdEncoding.EncodeToString(pub)},
+		{"Test url", b64.URLEncoding.EncodeToString(pub)},
+		{"Test raw url", b64.RawURLEncoding.EncodeToString(pub)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeKeyMaterial(tt.encoded)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if !bytes.Equal(got, pub) {
+				t.Errorf("expected %x got %x", []byte(pub), got)
+			}
+		})
+	}
+
+	t.Run("Test not base64", func(t *testing.T) {
+		_, err := DecodeKeyMaterial("not base64!")
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+)
+
+var (
+	// ErrMalformedPublicKey is returned when the public key isn't base64 or
+	// base64url.
+	ErrMalformedPublicKey = errors.New("auth: public key is not base64 or base64url")
+	// ErrBadKeyLength is returned when the public key isn't an Ed25519 key.
+	ErrBadKeyLength = errors.New("auth: public key is not 32 bytes")
+	// ErrMalformedSignature is returned when the signature isn't base64 or
+	// base64url.
+	ErrMalformedSignature = errors.New("auth: signature is not base64 or base64url")
+	// ErrBadSignatureLength is returned when the signature isn't an Ed25519
+	// signature.
+	ErrBadSignatureLength = errors.New("auth: signature is not 64 bytes")
+	// ErrBadSignature is returned when the signature doesn't verify.
+	ErrBadSignature = errors.New("auth: signature does not verify")
+	// ErrUnsupportedDigest is returned when the response names an unknown
+	// digest.
+	ErrUnsupportedDigest = errors.New("auth: unsupported digest")
+)
+
+// VerifyChallengeResponse checks that resp is signed, in challenge.DefaultMode,
+// by the public key it carries. It doesn't check that resp answers a live
+// challenge; that is the challenge store's job.
+func VerifyChallengeResponse(resp dto.ChallengeResponse) error {
+	return VerifyChallengeResponseWithMode(resp, challenge.DefaultMode)
+}
+
+// VerifyChallengeResponseWithMode is VerifyChallengeResponse for a server
+// running another signing mode.
+func VerifyChallengeResponseWithMode(resp dto.ChallengeResponse, mode challenge.Mode) error {
+	pk, err := PublicKey(resp)
+	if err != nil {
+		return err
+	}
+	sig, err := resp.DecodeSignature()
+	if err != nil {
+		return ErrMalformedSignature
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return ErrBadSignatureLength
+	}
+	digest, err := challenge.ParseDigest(resp.Digest)
+	if err != nil {
+		return ErrUnsupportedDigest
+	}
+	if !mode.VerifyDigest(pk, SignedBytes(resp), sig, digest) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// PublicKey decodes the public key resp carries.
+func PublicKey(resp dto.ChallengeResponse) (ed25519.PublicKey, error) {
+	pk, err := resp.DecodePublicKey()
+	if err != nil {
+		return nil, ErrMalformedPublicKey
+	}
+	if len(pk) != ed25519.PublicKeySize {
+		return nil, ErrBadKeyLength
+	}
+	return pk, nil
+}
+
+// SignedBytes returns what the client signed to produce resp: the hash of its
+// client data if it has any, otherwise its message, timestamped if it is.
+func SignedBytes(resp dto.ChallengeResponse) []byte {
+	if len(resp.ClientData) > 0 {
+		return ClientDataHash(resp.ClientData)
+	}
+	return challenge.SignedMessage(resp.Message, resp.Timestamp)
+}
+
+// ClientDataHash returns what the client signs for a client data assertion:
+// the SHA-256 of the client data exactly as sent.
+func ClientDataHash(raw json.RawMessage) []byte {
+	sum := sha256.Sum256(raw)
+	return sum[:]
+}
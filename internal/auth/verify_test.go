@@ -0,0 +1,74 @@
+package auth
+
+import (
+	b64 "encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// signedResponse answers message the way the default sign mode expects.
+func signedResponse(t *testing.T, message string) dto.ChallengeResponse {
+	t.Helper()
+	pub, priv := testutil.DeterministicEd25519(2)
+	return dto.ChallengeResponse{
+		Signature: testutil.SignChallenge(priv, message),
+		Message:   message,
+		PublicKey: b64.StdEncoding.EncodeToString(pub),
+	}
+}
+
+func TestVerifyChallengeResponse(t *testing.T) {
+	valid := signedResponse(t, "challenge")
+	otherPub, _ := testutil.DeterministicEd25519(4)
+
+	tests := []struct {
+		name   string
+		mutate func(*dto.ChallengeResponse)
+		err    error
+	}{
+		{"Test valid response", func(*dto.ChallengeResponse) {}, nil},
+		{"Test malformed public key", func(r *dto.ChallengeResponse) { r.PublicKey = "not base64!" }, ErrMalformedPublicKey},
+		{"Test short public key", func(r *dto.ChallengeResponse) { r.PublicKey = b64.StdEncoding.EncodeToString(make([]byte, 16)) }, ErrBadKeyLength},
+		{"Test malformed signature", func(r *dto.ChallengeResponse) { r.Signature = "not base64!" }, ErrMalformedSignature},
+		{"Test short signature", func(r *dto.ChallengeResponse) { r.Signature = b64.StdEncoding.EncodeToString(make([]byte, 32)) }, ErrBadSignatureLength},
+		{"Test other message", func(r *dto.ChallengeResponse) { r.Message = "other" }, ErrBadSignature},
+		{"Test other key", func(r *dto.ChallengeResponse) { r.PublicKey = b64.StdEncoding.EncodeToString(otherPub) }, ErrBadSignature},
+		{"Test unsigned timestamp", func(r *dto.ChallengeResponse) { r.Timestamp = 1700000000 }, ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := valid
+			tt.mutate(&resp)
+			err := VerifyChallengeResponse(resp)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected error to be %v got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("Test mode must match", func(t *testing.T) {
+		err := VerifyChallengeResponseWithMode(valid, challenge.ModeRaw)
+		if !errors.Is(err, ErrBadSignature) {
+			t.Errorf("expected error to be %v got %v", ErrBadSignature, err)
+		}
+	})
+
+	t.Run("Test client data is what is signed", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(3)
+		raw := []byte(`{"type":"webauthn.get","origin":"https://app.example.com","challenge":"challenge"}`)
+		sig, _ := challenge.ModeRaw.Sign(priv, ClientDataHash(raw))
+		resp := dto.ChallengeResponse{
+			Signature:  b64.StdEncoding.EncodeToString(sig),
+			PublicKey:  b64.StdEncoding.EncodeToString(pub),
+			ClientData: raw,
+		}
+		err := VerifyChallengeResponseWithMode(resp, challenge.ModeRaw)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
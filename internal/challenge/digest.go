@@ -0,0 +1,85 @@
+package challenge
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+)
+
+// Digest names the hash ModeDigest applies to the challenge before signing
+// it. Other modes ignore it.
+type Digest string
+
+const (
+	DigestSHA256 Digest = "SHA-256"
+	DigestSHA512 Digest = "SHA-512"
+)
+
+// DefaultDigest is what ModeDigest has always used, and what a response that
+// names no digest is checked with.
+const DefaultDigest = DigestSHA256
+
+// ParseDigest returns the Digest named by s, or DefaultDigest when s is
+// empty.
+func ParseDigest(s string) (Digest, error) {
+	switch d := Digest(s); d {
+	case "":
+		return DefaultDigest, nil
+	case DigestSHA256, DigestSHA512:
+		return d, nil
+	}
+	return "", fmt.Errorf("challenge: unknown digest %q", s)
+}
+
+// Sum returns the digest of message.
+func (d Digest) Sum(message []byte) []byte {
+	if d == DigestSHA512 {
+		sum := sha512.Sum512(message)
+		return sum[:]
+	}
+	sum := sha256.Sum256(message)
+	return sum[:]
+}
+
+// Input returns the bytes Ed25519 is called on when message is signed in
+// mode m under d: its d digest in ModeDigest, message itself in ModeRaw, and
+// in ModePrehash its SHA-512, which Ed25519ph signs. It is nil for an unknown
+// mode.
+func (m Mode) Input(message []byte, d Digest) []byte {
+	switch m {
+	case ModeDigest:
+		return d.Sum(message)
+	case ModeRaw:
+		return message
+	case ModePrehash:
+		sum := sha512.Sum512(message)
+		return sum[:]
+	}
+	return nil
+}
+
+// SignDigest is Sign with ModeDigest hashing message under d instead of
+// DefaultDigest.
+func (m Mode) SignDigest(priv ed25519.PrivateKey, message []byte, d Digest) ([]byte, error) {
+	switch m {
+	case ModeDigest, ModeRaw:
+		return ed25519.Sign(priv, m.Input(message, d)), nil
+	case ModePrehash:
+		return priv.Sign(nil, m.Input(message, d), &ed25519.Options{Hash: crypto.SHA512})
+	}
+	return nil, fmt.Errorf("challenge: unknown signing mode %q", m)
+}
+
+// VerifyDigest is Verify with ModeDigest hashing message under d instead of
+// DefaultDigest.
+func (m Mode) VerifyDigest(pub ed25519.PublicKey, message, sig []byte, d Digest) bool {
+	switch m {
+	case ModeDigest, ModeRaw:
+		return ed25519.Verify(pub, m.Input(message, d), sig)
+	case ModePrehash:
+		return ed25519.VerifyWithOptions(pub, m.Input(message, d), sig, &ed25519.Options{Hash: crypto.SHA512}) == nil
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package challenge
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Mode selects what a client signs when answering a challenge. Whatever the
+// mode, the challenge response carries the hex challenge as message and the
+// unpadded base64url public key and signature; only the bytes fed to Ed25519
+// differ.
+type Mode string
+
+const (
+	// ModeDigest signs SHA-256(challenge) with plain Ed25519. It is what the
+	// POC originally did and is neither Ed25519 nor Ed25519ph, so other
+	// libraries can't interoperate with it.
+	ModeDigest Mode = "sha256"
+	// ModeRaw signs the challenge bytes with plain Ed25519 (RFC 8032).
+	ModeRaw Mode = "ed25519"
+	// ModePrehash signs the challenge with Ed25519ph (RFC 8032), which hashes
+	// it with SHA-512 under a domain-separated context.
+	ModePrehash Mode = "ed25519ph"
+)
+
+// DefaultMode keeps existing clients working.
+const DefaultMode = ModeDigest
+
+// ParseMode returns the Mode named by s.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeDigest, ModeRaw, ModePrehash:
+		return m, nil
+	}
+	return "", fmt.Errorf("challenge: unknown signing mode %q", s)
+}
+
+// Sign signs message with priv in mode m.
+func (m Mode) Sign(priv ed25519.PrivateKey, message []byte) ([]byte, error) {
+	return m.SignDigest(priv, message, DefaultDigest)
+}
+
+// Verify reports whether sig is a valid signature of message by pub in mode
+// m. pub must be ed25519.PublicKeySize bytes long.
+func (m Mode) Verify(pub ed25519.PublicKey, message, sig []byte) bool {
+	return m.VerifyDigest(pub, message, sig, DefaultDigest)
+}
@@ -0,0 +1,79 @@
+package challenge
+
+import (
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestMode(t *testing.T) {
+	pub, priv := testutil.DeterministicEd25519(1)
+	message := []byte("0123456789abcdef")
+	modes := []Mode{ModeDigest, ModeRaw, ModePrehash}
+
+	for _, signed := range modes {
+		sig, err := signed.Sign(priv, message)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		for _, verified := range modes {
+			t.Run("Test "+string(signed)+" verified as "+string(verified), func(t *testing.T) {
+				ok := verified.Verify(pub, message, sig)
+				if ok != (signed == verified) {
+					t.Errorf("expected verify to be %v got %v", signed == verified, ok)
+				}
+			})
+		}
+	}
+
+	t.Run("Test raw mode is plain Ed25519", func(t *testing.T) {
+		sig, _ := ModeRaw.Sign(priv, message)
+		if !ed25519.Verify(pub, message, sig) {
+			t.Errorf("expected signature to verify with ed25519.Verify")
+		}
+	})
+
+	t.Run("Test unknown mode", func(t *testing.T) {
+		_, err := ParseMode("ed448")
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+func TestDigest(t *testing.T) {
+	pub, priv := testutil.DeterministicEd25519(2)
+	message := []byte("8ce8129fad2ed163736b562819f6fed5fd72e072e30b0c354a4a9a8497a4c6dc")
+	digests := []Digest{DigestSHA256, DigestSHA512}
+
+	for _, signed := range digests {
+		sig, _ := ModeDigest.SignDigest(priv, message, signed)
+		for _, verified := range digests {
+			t.Run("Test "+string(signed)+" verified as "+string(verified), func(t *testing.T) {
+				ok := ModeDigest.VerifyDigest(pub, message, sig, verified)
+				if ok != (signed == verified) {
+					t.Errorf("expected verify to be %v got %v", signed == verified, ok)
+				}
+			})
+		}
+	}
+
+	t.Run("Test default digest matches Sign", func(t *testing.T) {
+		sig, _ := ModeDigest.Sign(priv, message)
+		if !ModeDigest.VerifyDigest(pub, message, sig, DefaultDigest) {
+			t.Errorf("expected signature to verify under %s", DefaultDigest)
+		}
+	})
+
+	t.Run("Test parse", func(t *testing.T) {
+		d, err := ParseDigest("")
+		if err != nil || d != DefaultDigest {
+			t.Errorf("expected empty digest to be %s got %s, %v", DefaultDigest, d, err)
+		}
+		_, err = ParseDigest("MD5")
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
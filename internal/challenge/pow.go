@@ -0,0 +1,53 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"math/bits"
+	"strconv"
+)
+
+// MaxDifficulty is the most leading zero bits a proof of work may be asked
+// for. Each bit doubles the client's work; past this a sign-in takes hours.
+const MaxDifficulty = 32
+
+// SolvesWork reports whether SHA-256(message || nonce) starts with at least
+// difficulty zero bits. Any nonce solves a difficulty of 0.
+func SolvesWork(message, nonce string, difficulty int) bool {
+	if difficulty <= 0 {
+		return true
+	}
+	return leadingZeroBits(sha256.Sum256([]byte(message+nonce))) >= difficulty
+}
+
+// solveCheckInterval is how many nonces SolveWork tries between checks of
+// its context.
+const solveCheckInterval = 1024
+
+// SolveWork finds a decimal nonce that solves difficulty for message. It
+// tries about 2^difficulty nonces on average, and gives up with ctx.Err()
+// once ctx is done.
+func SolveWork(ctx context.Context, message string, difficulty int) (string, error) {
+	for n := uint64(0); ; n++ {
+		if n%solveCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		nonce := strconv.FormatUint(n, 10)
+		if SolvesWork(message, nonce, difficulty) {
+			return nonce, nil
+		}
+	}
+}
+
+func leadingZeroBits(sum [sha256.Size]byte) int {
+	zeros := 0
+	for _, b := range sum {
+		if b != 0 {
+			return zeros + bits.LeadingZeros8(b)
+		}
+		zeros += 8
+	}
+	return zeros
+}
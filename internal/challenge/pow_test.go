@@ -0,0 +1,59 @@
+package challenge
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+func TestProofOfWork(t *testing.T) {
+	t.Run("Test solution meets the difficulty", func(t *testing.T) {
+		nonce, err := SolveWork(context.Background(), "abc", 12)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !SolvesWork("abc", nonce, 12) {
+			t.Errorf("expected nonce %s to solve difficulty 12", nonce)
+		}
+		sum := sha256.Sum256([]byte("abc" + nonce))
+		if sum[0] != 0 || sum[1]>>4 != 0 {
+			t.Errorf("expected 12 leading zero bits got %x", sum[:2])
+		}
+	})
+
+	t.Run("Test solution is tied to the message", func(t *testing.T) {
+		nonce, err := SolveWork(context.Background(), "abc", 16)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if SolvesWork("abd", nonce, 16) {
+			t.Errorf("expected nonce %s not to solve another message", nonce)
+		}
+	})
+
+	t.Run("Test cancelled context stops the search", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := SolveWork(ctx, "abc", MaxDifficulty)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected error to be %v got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("Test zero difficulty needs no work", func(t *testing.T) {
+		if !SolvesWork("abc", "", 0) {
+			t.Errorf("expected difficulty 0 to be solved without a nonce")
+		}
+	})
+
+	t.Run("Test leading zero bits", func(t *testing.T) {
+		sum := [sha256.Size]byte{0, 0x10}
+		if got := leadingZeroBits(sum); got != 11 {
+			t.Errorf("expected 11 got %d", got)
+		}
+		if got := leadingZeroBits([sha256.Size]byte{}); got != 256 {
+			t.Errorf("expected 256 got %d", got)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package challenge
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces challenge keys in a shared Redis.
+const redisKeyPrefix = "challenge:"
+
+// redisUsedKeyPrefix namespaces the nonces of consumed stateless challenges.
+const redisUsedKeyPrefix = "challenge-used:"
+
+// RedisStore keeps challenges in Redis so every server instance behind a load
+// balancer sees the ones the others issued. Redis expires them after the TTL
+// and GETDEL consumes them atomically, so a challenge answers once even when
+// two instances race for it.
+type RedisStore struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+	cfg    ChallengeConfig
+}
+
+// NewRedisStore returns a store whose challenges live in client and expire
+// after ttl, generated according to cfg. It pings client so a bad address
+// fails at startup. The store owns client and closes it on Close.
+func NewRedisStore(client redis.UniversalClient, ttl time.Duration, cfg ChallengeConfig) (*RedisStore, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = client.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStore{client: client, ttl: ttl, cfg: cfg}, nil
+}
+
+// TTL returns how long issued challenges stay valid.
+func (s *RedisStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue generates a new random challenge and stores it until it expires.
+func (s *RedisStore) Issue() (string, error) {
+	return s.IssueFor(Binding{})
+}
+
+// IssueFor is like Issue but binds the challenge to b, which is stored as
+// the key's value in JSON.
+func (s *RedisStore) IssueFor(b Binding) (string, error) {
+	challenge, err := s.cfg.generate()
+	if err != nil {
+		return "", err
+	}
+	value, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	err = s.client.SetEx(context.Background(), redisKeyPrefix+challenge, value, s.ttl).Err()
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// Consume reports whether message is a live challenge and deletes it.
+func (s *RedisStore) Consume(message string) bool {
+	_, ok := s.ConsumeBinding(message)
+	return ok
+}
+
+// ConsumeBinding is like Consume but also returns what the challenge was
+// bound to. Redis errors count as an unknown challenge.
+func (s *RedisStore) ConsumeBinding(message string) (b Binding, ok bool) {
+	value, err := s.client.GetDel(context.Background(), redisKeyPrefix+message).Bytes()
+	if err != nil {
+		return Binding{}, false
+	}
+	err = json.Unmarshal(value, &b)
+	if err != nil {
+		return Binding{}, false
+	}
+	return b, true
+}
+
+// Close closes the Redis client.
+func (s *RedisStore) Close() {
+	s.client.Close()
+}
+
+// RedisUsedMarker is a UsedMarker in Redis. SET NX marks a nonce atomically,
+// so of two instances racing for a challenge only one wins.
+type RedisUsedMarker struct {
+	client redis.UniversalClient
+}
+
+// NewRedisUsedMarker returns a marker keeping nonces in client. It pings
+// client so a bad address fails at startup. The marker owns client and closes
+// it on Close.
+func NewRedisUsedMarker(client redis.UniversalClient) (*RedisUsedMarker, error) {
+	err := client.Ping(context.Background()).Err()
+	if err != nil {
+		return nil, err
+	}
+	return &RedisUsedMarker{client: client}, nil
+}
+
+// MarkUsed sets nonce's key unless it exists, expiring it after ttl.
+func (m *RedisUsedMarker) MarkUsed(nonce string, ttl time.Duration) (bool, error) {
+	return m.client.SetNX(context.Background(), redisUsedKeyPrefix+nonce, 1, ttl).Result()
+}
+
+// Close closes the Redis client.
+func (m *RedisUsedMarker) Close() {
+	m.client.Close()
+}
@@ -0,0 +1,123 @@
+package challenge
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), DefaultTTL, DefaultChallengeConfig)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s, mr
+}
+
+func TestRedisStore(t *testing.T) {
+	t.Run("Test issued challenge is consumed once", func(t *testing.T) {
+		s, mr := newTestRedisStore(t)
+		c, err := s.Issue()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if ttl := mr.TTL(redisKeyPrefix + c); ttl != DefaultTTL {
+			t.Errorf("expected key ttl to be %v got %v", DefaultTTL, ttl)
+		}
+		if !s.Consume(c) {
+			t.Errorf("expected challenge to be consumed")
+		}
+		if s.Consume(c) {
+			t.Errorf("expected reused challenge to be rejected")
+		}
+	})
+
+	t.Run("Test unknown challenge is rejected", func(t *testing.T) {
+		s, _ := newTestRedisStore(t)
+		if s.Consume("never issued") {
+			t.Errorf("expected unknown challenge to be rejected")
+		}
+	})
+
+	t.Run("Test challenge issued by one instance is consumed by another", func(t *testing.T) {
+		a, mr := newTestRedisStore(t)
+		b, err := NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}), DefaultTTL, DefaultChallengeConfig)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		defer b.Close()
+		c, _ := a.IssueFor(Binding{PublicKey: "client-key", Session: "session-id"})
+		binding, ok := b.ConsumeBinding(c)
+		if !ok || binding.PublicKey != "client-key" || binding.Session != "session-id" {
+			t.Errorf("expected challenge bound to client-key in session-id got %+v, %v", binding, ok)
+		}
+	})
+
+	t.Run("Test expired challenge is rejected", func(t *testing.T) {
+		s, mr := newTestRedisStore(t)
+		c, _ := s.Issue()
+		mr.FastForward(DefaultTTL + time.Second)
+		if s.Consume(c) {
+			t.Errorf("expected expired challenge to be rejected")
+		}
+	})
+
+	t.Run("Test unreachable Redis fails fast", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		addr := mr.Addr()
+		mr.Close()
+		_, err := NewRedisStore(redis.NewClient(&redis.Options{Addr: addr}), DefaultTTL, DefaultChallengeConfig)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+func TestRedisUsedMarker(t *testing.T) {
+	mr := miniredis.RunT(t)
+	// Two replicas sharing the secret and the marker's Redis.
+	newReplica := func(t *testing.T) *StatelessStore {
+		t.Helper()
+		marker, err := NewRedisUsedMarker(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		s := newTestStatelessStore(t)
+		s.SetUsedMarker(marker)
+		return s
+	}
+	a, b := newReplica(t), newReplica(t)
+
+	t.Run("Test reused challenge is rejected by the other replica", func(t *testing.T) {
+		c, _ := a.Issue()
+		if !b.Consume(c) {
+			t.Fatalf("expected challenge to be consumed")
+		}
+		if a.Consume(c) {
+			t.Errorf("expected the other replica to reject the reused challenge")
+		}
+	})
+
+	t.Run("Test marks expire with the challenge", func(t *testing.T) {
+		c, _ := a.Issue()
+		a.Consume(c)
+		nonce := c[:strings.Index(c, ".")]
+		ttl := mr.TTL(redisUsedKeyPrefix + nonce)
+		if ttl <= 0 || ttl > a.TTL() {
+			t.Errorf("expected the mark to expire within %s got %s", a.TTL(), ttl)
+		}
+	})
+
+	t.Run("Test bad address", func(t *testing.T) {
+		_, err := NewRedisUsedMarker(redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1}))
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package challenge
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+// Sealed is a challenge encrypted to a client's X25519 key. Only the holder
+// of the matching private key can Open it.
+type Sealed struct {
+	EphemeralPublicKey []byte
+	Nonce              []byte
+	Ciphertext         []byte
+}
+
+// Seal encrypts challenge to clientKey. It does X25519 with a fresh
+// ephemeral key, derives an AES-256-GCM key from the shared secret and both
+// public keys, and seals the challenge under it.
+func Seal(clientKey *ecdh.PublicKey, challenge string) (Sealed, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return Sealed{}, err
+	}
+	aead, err := sealingAEAD(ephemeral, clientKey, ephemeral.PublicKey(), clientKey)
+	if err != nil {
+		return Sealed{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return Sealed{}, err
+	}
+	return Sealed{
+		EphemeralPublicKey: ephemeral.PublicKey().Bytes(),
+		Nonce:              nonce,
+		Ciphertext:         aead.Seal(nil, nonce, []byte(challenge), nil),
+	}, nil
+}
+
+// Open decrypts a challenge sealed to the public key of priv.
+func Open(priv *ecdh.PrivateKey, s Sealed) (string, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(s.EphemeralPublicKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := sealingAEAD(priv, ephemeral, ephemeral, priv.PublicKey())
+	if err != nil {
+		return "", err
+	}
+	if len(s.Nonce) != aead.NonceSize() {
+		return "", errors.New("challenge: invalid nonce")
+	}
+	plain, err := aead.Open(nil, s.Nonce, s.Ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// sealingAEAD does ECDH between priv and peer and keys AES-256-GCM with
+// SHA-256(shared secret || ephemeral public key || client public key).
+func sealingAEAD(priv *ecdh.PrivateKey, peer, ephemeral, client *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	h.Write(shared)
+	h.Write(ephemeral.Bytes())
+	h.Write(client.Bytes())
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,58 @@
+package challenge
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestSeal(t *testing.T) {
+	clientKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+
+	t.Run("Test encrypt, decrypt and sign", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		c, _ := s.Issue()
+
+		sealed, err := Seal(clientKey.PublicKey(), c)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		opened, err := Open(clientKey, sealed)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if opened != c {
+			t.Errorf("expected challenge to be %s got %s", c, opened)
+		}
+
+		pub, priv := testutil.DeterministicEd25519(3)
+		sig, _ := DefaultMode.Sign(priv, []byte(opened))
+		if !DefaultMode.Verify(pub, []byte(c), sig) {
+			t.Errorf("expected signature over the opened challenge to verify")
+		}
+		if !s.Consume(opened) {
+			t.Errorf("expected opened challenge to be consumed")
+		}
+	})
+
+	t.Run("Test other key cannot open", func(t *testing.T) {
+		sealed, _ := Seal(clientKey.PublicKey(), "secret")
+		otherKey, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		_, err := Open(otherKey, sealed)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test tampered ciphertext", func(t *testing.T) {
+		sealed, _ := Seal(clientKey.PublicKey(), "secret")
+		sealed.Ciphertext[0] ^= 1
+		_, err := Open(clientKey, sealed)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
@@ -0,0 +1,12 @@
+package challenge
+
+// serverSigningContext prefixes what the server signs for a challenge, so a
+// challenge signature can't pass for a token signature or anything else the
+// server's key signs.
+const serverSigningContext = "ed25519-poc challenge\x00"
+
+// ServerSigningInput returns the bytes the server signs to vouch that it
+// issued message.
+func ServerSigningInput(message string) []byte {
+	return []byte(serverSigningContext + message)
+}
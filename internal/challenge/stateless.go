@@ -0,0 +1,225 @@
+package challenge
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MinSecretSize is the shortest HMAC secret a StatelessStore accepts.
+const MinSecretSize = 32
+
+// ErrShortSecret is returned when a StatelessStore secret is under
+// MinSecretSize bytes.
+var ErrShortSecret = errors.New("challenge: stateless secret must be at least 32 bytes")
+
+// StatelessStore keeps no challenges: each one carries its own nonce, expiry
+// and binding, MACed with a secret, as
+//
+//	payload.base64url(HMAC-SHA256(secret, payload))
+//
+// where payload is nonce.expiry.base64url(publicKey).base64url(session).
+//
+// Instances sharing the secret accept each other's challenges, so it scales
+// out without shared storage. A challenge answers once per instance: the
+// nonces of consumed challenges are remembered until they expire, but
+// another instance could still accept a replay within the TTL unless they
+// share a UsedMarker.
+type StatelessStore struct {
+	ttl    time.Duration
+	cfg    ChallengeConfig
+	secret []byte
+	now    func() time.Time
+	used   UsedMarker
+
+	mu   sync.Mutex
+	seen map[string]time.Time // consumed nonce -> expiry
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// UsedMarker records the nonces of consumed stateless challenges where every
+// instance sees them, so a challenge answers once across instances while
+// issuing them stays stateless. RedisUsedMarker keeps them in Redis.
+type UsedMarker interface {
+	// MarkUsed records nonce as used for ttl and reports whether it wasn't
+	// already.
+	MarkUsed(nonce string, ttl time.Duration) (bool, error)
+	Close()
+}
+
+// NewStatelessStore returns a store whose challenges expire after ttl, with
+// nonces generated according to cfg and MACed with secret. A background
+// goroutine forgets expired nonces every ttl until Close.
+func NewStatelessStore(ttl time.Duration, cfg ChallengeConfig, secret []byte) (*StatelessStore, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < MinSecretSize {
+		return nil, ErrShortSecret
+	}
+	s := &StatelessStore{
+		ttl:    ttl,
+		cfg:    cfg,
+		secret: secret,
+		now:    time.Now,
+		seen:   make(map[string]time.Time),
+		stop:   make(chan struct{}),
+	}
+	go s.sweepEvery(ttl)
+	return s, nil
+}
+
+// SetUsedMarker has consumed challenges also marked in m, which instances
+// sharing the secret should share too. Call it before the store is used. The
+// store owns m and closes it on Close.
+func (s *StatelessStore) SetUsedMarker(m UsedMarker) {
+	s.used = m
+}
+
+// TTL returns how long issued challenges stay valid.
+func (s *StatelessStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue returns a new signed challenge.
+func (s *StatelessStore) Issue() (string, error) {
+	return s.IssueFor(Binding{})
+}
+
+// IssueFor is like Issue but binds the challenge to b, which it carries
+// under the MAC.
+func (s *StatelessStore) IssueFor(b Binding) (string, error) {
+	nonce, err := s.cfg.generate()
+	if err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(s.now().Add(s.ttl).Unix(), 10)
+	payload := nonce + "." + expiry + "." + base64.RawURLEncoding.EncodeToString([]byte(b.PublicKey)) +
+		"." + base64.RawURLEncoding.EncodeToString([]byte(b.Session))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload)), nil
+}
+
+// Consume reports whether message is a challenge this store's secret signed
+// that hasn't expired or been consumed, and marks it consumed.
+func (s *StatelessStore) Consume(message string) bool {
+	_, ok := s.ConsumeBinding(message)
+	return ok
+}
+
+// ConsumeBinding is like Consume but also returns what the challenge was
+// bound to.
+func (s *StatelessStore) ConsumeBinding(message string) (b Binding, ok bool) {
+	b, err := s.ConsumeReason(message)
+	return b, err == nil
+}
+
+// ConsumeReason is like ConsumeBinding but says why a challenge was refused.
+// Only a challenge whose MAC checks out can be told to have expired. With a
+// UsedMarker, a challenge another instance consumed is unknown, and so is
+// one the marker fails on; that one may be retried.
+func (s *StatelessStore) ConsumeReason(message string) (Binding, error) {
+	parts := strings.Split(message, ".")
+	if len(parts) != 5 {
+		return Binding{}, ErrUnknownChallenge
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil || !hmac.Equal(mac, s.mac(strings.Join(parts[:4], "."))) {
+		return Binding{}, ErrUnknownChallenge
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Binding{}, ErrUnknownChallenge
+	}
+	expiry := time.Unix(unix, 0)
+	key, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Binding{}, ErrUnknownChallenge
+	}
+	session, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return Binding{}, ErrUnknownChallenge
+	}
+
+	now := s.now()
+	if !now.Before(expiry) {
+		return Binding{}, ErrExpiredChallenge
+	}
+	s.mu.Lock()
+	if _, replayed := s.seen[parts[0]]; replayed {
+		s.mu.Unlock()
+		return Binding{}, ErrUnknownChallenge
+	}
+	s.seen[parts[0]] = expiry
+	s.mu.Unlock()
+
+	if s.used != nil {
+		fresh, err := s.used.MarkUsed(parts[0], expiry.Sub(now))
+		if err != nil {
+			s.mu.Lock()
+			delete(s.seen, parts[0])
+			s.mu.Unlock()
+			return Binding{}, ErrUnknownChallenge
+		}
+		if !fresh {
+			return Binding{}, ErrUnknownChallenge
+		}
+	}
+	return Binding{PublicKey: string(key), Session: string(session)}, nil
+}
+
+// Len returns how many consumed nonces are remembered against replay.
+func (s *StatelessStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.seen)
+}
+
+// Close stops the background sweep and closes the UsedMarker, if any.
+func (s *StatelessStore) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		if s.used != nil {
+			s.used.Close()
+		}
+	})
+}
+
+func (s *StatelessStore) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func (s *StatelessStore) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// sweep forgets the nonces of expired challenges, which can't be replayed
+// anyway.
+func (s *StatelessStore) sweep() {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nonce, expiry := range s.seen {
+		if !now.Before(expiry) {
+			delete(s.seen, nonce)
+		}
+	}
+}
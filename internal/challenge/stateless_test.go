@@ -0,0 +1,164 @@
+package challenge
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = bytes.Repeat([]byte("s"), MinSecretSize)
+
+func newTestStatelessStore(t *testing.T) *StatelessStore {
+	t.Helper()
+	s, err := NewStatelessStore(time.Minute, DefaultChallengeConfig, testSecret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestStatelessStore(t *testing.T) {
+	t.Run("Test valid challenge is consumed once", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		c, err := s.Issue()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !s.Consume(c) {
+			t.Errorf("expected challenge to be consumed")
+		}
+		if s.Consume(c) {
+			t.Errorf("expected replayed challenge to be rejected")
+		}
+	})
+
+	t.Run("Test another instance with the secret accepts it", func(t *testing.T) {
+		c, _ := newTestStatelessStore(t).Issue()
+		if !newTestStatelessStore(t).Consume(c) {
+			t.Errorf("expected challenge to be consumed")
+		}
+	})
+
+	t.Run("Test expired challenge is rejected", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		now := time.Now()
+		s.now = func() time.Time { return now }
+		c, _ := s.Issue()
+		now = now.Add(2 * time.Minute)
+		if s.Consume(c) {
+			t.Errorf("expected expired challenge to be rejected")
+		}
+	})
+
+	t.Run("Test refusal reasons", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		now := time.Now()
+		s.now = func() time.Time { return now }
+		expired, _ := s.Issue()
+		now = now.Add(2 * time.Minute)
+		if _, err := ConsumeReason(s, expired); !errors.Is(err, ErrExpiredChallenge) {
+			t.Errorf("expected error to be %v got %v", ErrExpiredChallenge, err)
+		}
+		if _, err := ConsumeReason(s, "not.a.challenge"); !errors.Is(err, ErrUnknownChallenge) {
+			t.Errorf("expected error to be %v got %v", ErrUnknownChallenge, err)
+		}
+	})
+
+	t.Run("Test tampered challenge is rejected", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		c, _ := s.Issue()
+		parts := strings.Split(c, ".")
+		tampered := []string{
+			"00" + c[2:],
+			parts[0] + "." + strings.Repeat("9", len(parts[1])) + "." + strings.Join(parts[2:], "."),
+			strings.Join(parts[:4], ".") + ".AAAA",
+			strings.Join(parts[:4], "."),
+			parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString([]byte("other-key")) + "." + strings.Join(parts[3:], "."),
+			strings.Join(parts[:3], ".") + "." + base64.RawURLEncoding.EncodeToString([]byte("other-session")) + "." + parts[4],
+		}
+		for _, m := range tampered {
+			if s.Consume(m) {
+				t.Errorf("expected tampered challenge %q to be rejected", m)
+			}
+		}
+		if !s.Consume(c) {
+			t.Errorf("expected the original challenge to still be consumed")
+		}
+	})
+
+	t.Run("Test challenge from another secret is rejected", func(t *testing.T) {
+		other, _ := NewStatelessStore(time.Minute, DefaultChallengeConfig, bytes.Repeat([]byte("o"), MinSecretSize))
+		defer other.Close()
+		c, _ := other.Issue()
+		if newTestStatelessStore(t).Consume(c) {
+			t.Errorf("expected challenge to be rejected")
+		}
+	})
+
+	t.Run("Test bound challenge returns its key", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		c, _ := s.IssueFor(Binding{PublicKey: "client-key", Session: "session-id"})
+		b, ok := s.ConsumeBinding(c)
+		if !ok || b.PublicKey != "client-key" || b.Session != "session-id" {
+			t.Errorf("expected challenge bound to client-key in session-id got %+v (ok %v)", b, ok)
+		}
+	})
+
+	t.Run("Test sweep forgets expired nonces", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		now := time.Now()
+		s.now = func() time.Time { return now }
+		c, _ := s.Issue()
+		s.Consume(c)
+		now = now.Add(2 * time.Minute)
+		s.sweep()
+		if s.Len() != 0 {
+			t.Errorf("expected no remembered nonces got %d", s.Len())
+		}
+	})
+
+	t.Run("Test challenge the marker fails on can be retried", func(t *testing.T) {
+		s := newTestStatelessStore(t)
+		marker := &flakyMarker{fail: true}
+		s.SetUsedMarker(marker)
+		c, _ := s.Issue()
+		if _, err := s.ConsumeReason(c); !errors.Is(err, ErrUnknownChallenge) {
+			t.Errorf("expected error to be %v got %v", ErrUnknownChallenge, err)
+		}
+		marker.fail = false
+		if !s.Consume(c) {
+			t.Errorf("expected the retried challenge to be consumed")
+		}
+	})
+
+	t.Run("Test short secret", func(t *testing.T) {
+		_, err := NewStatelessStore(time.Minute, DefaultChallengeConfig, []byte("short"))
+		if !errors.Is(err, ErrShortSecret) {
+			t.Errorf("expected error to be %v got %v", ErrShortSecret, err)
+		}
+	})
+}
+
+// flakyMarker is a UsedMarker that errors while fail is set.
+type flakyMarker struct {
+	fail bool
+	used map[string]bool
+}
+
+func (m *flakyMarker) MarkUsed(nonce string, _ time.Duration) (bool, error) {
+	if m.fail {
+		return false, errors.New("marker unavailable")
+	}
+	if m.used == nil {
+		m.used = map[string]bool{}
+	}
+	fresh := !m.used[nonce]
+	m.used[nonce] = true
+	return fresh, nil
+}
+
+func (m *flakyMarker) Close() {}
@@ -0,0 +1,268 @@
+package challenge
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChallengeStore(t *testing.T) {
+	t.Run("Test issued challenge is consumed once", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+
+		c, err := s.Issue()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		b, err := hex.DecodeString(c)
+		if err != nil || len(b) != 32 {
+			t.Errorf("expected a 32-byte hex challenge got %s", c)
+		}
+		if !s.Consume(c) {
+			t.Errorf("expected challenge to be consumed")
+		}
+		if s.Consume(c) {
+			t.Errorf("expected reused challenge to be rejected")
+		}
+	})
+
+	t.Run("Test unknown challenge is rejected", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		if s.Consume("not issued") {
+			t.Errorf("expected unknown challenge to be rejected")
+		}
+	})
+
+	t.Run("Test expired challenge is rejected", func(t *testing.T) {
+		s := NewChallengeStore(time.Minute)
+		defer s.Close()
+		now := time.Now()
+		s.now = func() time.Time { return now }
+
+		c, _ := s.Issue()
+		now = now.Add(time.Minute)
+		if s.Consume(c) {
+			t.Errorf("expected expired challenge to be rejected")
+		}
+	})
+
+	t.Run("Test refusal reasons", func(t *testing.T) {
+		s := NewChallengeStore(time.Minute)
+		defer s.Close()
+		now := time.Now()
+		s.now = func() time.Time { return now }
+
+		c, _ := s.Issue()
+		now = now.Add(time.Minute)
+		if _, err := ConsumeReason(s, c); !errors.Is(err, ErrExpiredChallenge) {
+			t.Errorf("expected error to be %v got %v", ErrExpiredChallenge, err)
+		}
+		if _, err := ConsumeReason(s, c); !errors.Is(err, ErrUnknownChallenge) {
+			t.Errorf("expected error to be %v got %v", ErrUnknownChallenge, err)
+		}
+	})
+
+	t.Run("Test challenge is live until its expiry", func(t *testing.T) {
+		s := NewChallengeStore(time.Minute)
+		defer s.Close()
+		now := time.Now()
+		s.now = func() time.Time { return now }
+
+		c, _ := s.Issue()
+		now = now.Add(time.Minute - time.Nanosecond)
+		if !s.Consume(c) {
+			t.Errorf("expected challenge to be live just before its expiry")
+		}
+	})
+
+	t.Run("Test sweep forgets expired challenges", func(t *testing.T) {
+		s := NewChallengeStore(time.Minute)
+		defer s.Close()
+		now := time.Now()
+		s.now = func() time.Time { return now }
+
+		s.Issue()
+		now = now.Add(30 * time.Second)
+		live, _ := s.Issue()
+		now = now.Add(30 * time.Second)
+		s.sweep()
+
+		if s.Len() != 1 {
+			t.Errorf("expected 1 challenge after sweep got %d", s.Len())
+		}
+		if !s.Consume(live) {
+			t.Errorf("expected live challenge to survive the sweep")
+		}
+	})
+	t.Run("Test bound challenge returns its key", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+
+		bound, _ := s.IssueFor(Binding{PublicKey: "client-key", Session: "session-id"})
+		unbound, _ := s.Issue()
+		b, ok := s.ConsumeBinding(bound)
+		if !ok || b.PublicKey != "client-key" || b.Session != "session-id" {
+			t.Errorf("expected challenge bound to client-key in session-id got %+v (ok %v)", b, ok)
+		}
+		b, ok = s.ConsumeBinding(unbound)
+		if !ok || b != (Binding{}) {
+			t.Errorf("expected unbound challenge got %+v (ok %v)", b, ok)
+		}
+	})
+}
+
+func TestChallengeConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    ChallengeConfig
+		decode func(string) ([]byte, error)
+	}{
+		{"Test hex challenge", ChallengeConfig{ByteLen: 16, Encoding: "hex"}, hex.DecodeString},
+		{"Test base64url challenge", ChallengeConfig{ByteLen: 48, Encoding: "base64url"}, base64.RawURLEncoding.DecodeString},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewChallengeStoreWithConfig(DefaultTTL, tt.cfg)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			defer s.Close()
+			c, _ := s.Issue()
+			b, err := tt.decode(c)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if len(b) != tt.cfg.ByteLen {
+				t.Errorf("expected %d bytes got %d", tt.cfg.ByteLen, len(b))
+			}
+		})
+	}
+
+	t.Run("Test invalid configs", func(t *testing.T) {
+		for _, cfg := range []ChallengeConfig{{ByteLen: 8, Encoding: "hex"}, {ByteLen: 32, Encoding: "base32"}} {
+			_, err := NewChallengeStoreWithConfig(DefaultTTL, cfg)
+			if err == nil {
+				t.Errorf("expected error for %+v", cfg)
+			}
+		}
+	})
+}
+
+func TestChallengePool(t *testing.T) {
+	s := NewChallengeStore(DefaultTTL)
+	defer s.Close()
+
+	const n = 4 * poolSize
+	issued := make(chan string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := s.Issue()
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+			issued <- c
+		}()
+	}
+	wg.Wait()
+	close(issued)
+
+	seen := make(map[string]bool)
+	for c := range issued {
+		if seen[c] {
+			t.Errorf("expected unique challenges got %s twice", c)
+		}
+		seen[c] = true
+	}
+	if s.Len() != n {
+		t.Errorf("expected %d issued challenges to be remembered got %d", n, s.Len())
+	}
+	for c := range seen {
+		if !s.Consume(c) {
+			t.Errorf("expected pooled challenge %s to be consumable", c)
+		}
+	}
+}
+
+func TestChallengeCap(t *testing.T) {
+	t.Run("Test oldest challenge is evicted at the cap", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		s.SetCap(Cap{Max: 2})
+
+		oldest, _ := s.Issue()
+		second, _ := s.Issue()
+		third, err := s.Issue()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if s.Len() != 2 {
+			t.Errorf("expected 2 live challenges got %d", s.Len())
+		}
+		if s.Evictions() != 1 {
+			t.Errorf("expected 1 eviction got %d", s.Evictions())
+		}
+		if s.Consume(oldest) {
+			t.Errorf("expected oldest challenge to be evicted")
+		}
+		if !s.Consume(second) || !s.Consume(third) {
+			t.Errorf("expected newer challenges to survive")
+		}
+	})
+
+	t.Run("Test consumed challenges free their slot", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		s.SetCap(Cap{Max: 1})
+
+		c, _ := s.Issue()
+		s.Consume(c)
+		s.Issue()
+		if s.Evictions() != 0 {
+			t.Errorf("expected no evictions got %d", s.Evictions())
+		}
+	})
+
+	t.Run("Test strict cap rejects", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		s.SetCap(Cap{Max: 2, Reject: true})
+
+		first, _ := s.Issue()
+		s.Issue()
+		_, err := s.Issue()
+		if !errors.Is(err, ErrStoreFull) {
+			t.Errorf("expected error to be %v got %v", ErrStoreFull, err)
+		}
+		if s.Evictions() != 0 {
+			t.Errorf("expected no evictions got %d", s.Evictions())
+		}
+		if !s.Consume(first) {
+			t.Errorf("expected first challenge to survive")
+		}
+		_, err = s.Issue()
+		if err != nil {
+			t.Errorf("expected error to be nil once a slot is free got %v", err)
+		}
+	})
+
+	t.Run("Test zero cap is unbounded", func(t *testing.T) {
+		s := NewChallengeStore(DefaultTTL)
+		defer s.Close()
+		s.SetCap(Cap{})
+
+		for i := 0; i < 10; i++ {
+			s.Issue()
+		}
+		if s.Len() != 10 {
+			t.Errorf("expected 10 live challenges got %d", s.Len())
+		}
+	})
+}
@@ -0,0 +1,13 @@
+package challenge
+
+import "strconv"
+
+// SignedMessage returns the bytes a client signs to answer message. A zero
+// timestamp leaves message as is; otherwise the Unix timestamp, in seconds,
+// is appended after a dot so the signature also covers when it was made.
+func SignedMessage(message string, timestamp int64) []byte {
+	if timestamp == 0 {
+		return []byte(message)
+	}
+	return []byte(message + "." + strconv.FormatInt(timestamp, 10))
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// DefaultTimeout bounds a whole sign-in, both round trips included.
+const DefaultTimeout = 10 * time.Second
+
+// RetryConfig controls how a request is retried after a connection error or
+// a 5xx response. Attempt n waits BaseDelay * 2^(n-1) first. 4xx responses
+// are never retried.
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+}
+
+// DefaultRetry tries each request up to three times.
+var DefaultRetry = RetryConfig{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond}
+
+// ErrChallengeExpired is returned when the challenge expired before the
+// client could answer it, so answering it is pointless.
+var ErrChallengeExpired = errors.New("client: challenge expired before it was answered")
+
+var (
+	// ErrUnsignedChallenge is returned when c.VerifyServer is set and the
+	// server sent a challenge without a signature.
+	ErrUnsignedChallenge = errors.New("client: challenge is not signed by the server")
+	// ErrBadServerSignature is returned when the challenge's signature
+	// doesn't verify with the server's published key.
+	ErrBadServerSignature = errors.New("client: challenge signature does not verify")
+)
+
+// ErrExcessiveDifficulty is returned when the server asks for more proof of
+// work than challenge.MaxDifficulty, which would never finish.
+var ErrExcessiveDifficulty = errors.New("client: proof of work difficulty is too high")
+
+// Client signs in to the server at BaseURL with an Ed25519 key.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// Mode is what the client signs and must match the server's -sign-mode.
+	Mode challenge.Mode
+	// Timeout bounds each SignIn on top of any deadline on its context. Zero
+	// means no timeout.
+	Timeout time.Duration
+	// EncryptChallenge asks for the challenge sealed to a one-off X25519
+	// key. The server must run with -encrypted-challenges.
+	EncryptChallenge bool
+	// VerifyServer checks that each challenge is signed by a key in the
+	// server's JWKS before answering it. The server must run with
+	// -sign-challenges. This only helps when the JWKS itself is fetched over
+	// an authenticated channel such as TLS.
+	VerifyServer bool
+	// Retry retries failed requests. A zero MaxAttempts tries once.
+	Retry RetryConfig
+	// Timestamp signs the current time along with the challenge, so the
+	// server can reject responses relayed too late.
+	Timestamp bool
+	// RefreshMargin is how close to its exp a token Token cached may get
+	// before Token renews it.
+	RefreshMargin time.Duration
+
+	cache tokenCache
+}
+
+// New returns a Client for baseURL using http.DefaultClient,
+// challenge.DefaultMode, DefaultTimeout, DefaultRetry and
+// DefaultRefreshMargin.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:       baseURL,
+		HTTPClient:    http.DefaultClient,
+		Mode:          challenge.DefaultMode,
+		Timeout:       DefaultTimeout,
+		Retry:         DefaultRetry,
+		RefreshMargin: DefaultRefreshMargin,
+	}
+}
+
+// SignIn fetches a challenge, signs it with priv and exchanges the response
+// for a token. It gives up when ctx is done or c.Timeout elapses.
+func (c *Client) SignIn(ctx context.Context, priv ed25519.PrivateKey, pub ed25519.PublicKey) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	challengeResponse, err := c.answerChallenge(ctx, priv, pub)
+	if err != nil {
+		return "", err
+	}
+	token := dto.Jws{}
+	err = c.do(ctx, http.MethodPost, "/signIn", challengeResponse, &token)
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
+}
+
+// answerChallenge fetches a challenge and answers it with priv, as SignIn
+// posts it.
+func (c *Client) answerChallenge(ctx context.Context, priv ed25519.PrivateKey, pub ed25519.PublicKey) (dto.ChallengeResponse, error) {
+	message, ch, err := c.fetchChallenge(ctx)
+	if err != nil {
+		return dto.ChallengeResponse{}, err
+	}
+	if c.VerifyServer {
+		err = c.verifyServerSignature(ctx, message, ch)
+		if err != nil {
+			return dto.ChallengeResponse{}, err
+		}
+	}
+
+	var timestamp int64
+	if c.Timestamp {
+		timestamp = time.Now().Unix()
+	}
+	// In sha256 mode the server may ask for another digest; the default
+	// goes unnamed so older servers keep working.
+	digest, err := challenge.ParseDigest(ch.Digest)
+	if err != nil {
+		return dto.ChallengeResponse{}, err
+	}
+	signature, err := c.Mode.SignDigest(priv, challenge.SignedMessage(message, timestamp), digest)
+	if err != nil {
+		return dto.ChallengeResponse{}, err
+	}
+	if ch.ExpiresAt != 0 && !time.Now().Before(time.Unix(ch.ExpiresAt, 0)) {
+		return dto.ChallengeResponse{}, ErrChallengeExpired
+	}
+	challengeResponse := dto.ChallengeResponse{Message: message, Timestamp: timestamp}
+	challengeResponse.SetSignature(signature)
+	challengeResponse.SetPublicKey(pub)
+	if digest != challenge.DefaultDigest {
+		challengeResponse.Digest = string(digest)
+	}
+	if ch.Difficulty > challenge.MaxDifficulty {
+		return dto.ChallengeResponse{}, ErrExcessiveDifficulty
+	}
+	if ch.Difficulty > 0 {
+		challengeResponse.Nonce, err = challenge.SolveWork(ctx, message, ch.Difficulty)
+		if err != nil {
+			return dto.ChallengeResponse{}, err
+		}
+	}
+	return challengeResponse, nil
+}
+
+// Enroll registers pub with the server under identity so it can sign in,
+// proving it holds priv by answering a challenge with it. The server only
+// takes a first key for a new identity this way.
+func (c *Client) Enroll(ctx context.Context, identity string, priv ed25519.PrivateKey, pub ed25519.PublicKey) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	proof, err := c.answerChallenge(ctx, priv, pub)
+	if err != nil {
+		return err
+	}
+	enrollment := dto.Enrollment{Identity: identity, Proof: &proof}
+	enrollment.SetPublicKey(pub)
+	return c.do(ctx, http.MethodPost, "/enroll", enrollment, &enrollment)
+}
+
+// verifyServerSignature checks ch's server signature over message against the
+// key its kid names in the server's JWKS.
+func (c *Client) verifyServerSignature(ctx context.Context, message string, ch dto.Challenge) error {
+	if ch.ServerSignature == "" {
+		return ErrUnsignedChallenge
+	}
+	sig, err := ch.DecodeServerSignature()
+	if err != nil {
+		return ErrBadServerSignature
+	}
+	set := jws.JWKS{}
+	err = c.do(ctx, http.MethodGet, "/.well-known/jwks.json", nil, &set)
+	if err != nil {
+		return err
+	}
+	pub, err := set.Key(ch.Kid)
+	if err != nil {
+		return err
+	}
+	if jws.VerifyDetached(pub, challenge.ServerSigningInput(message), sig) != nil {
+		return ErrBadServerSignature
+	}
+	return nil
+}
+
+// fetchChallenge gets a challenge, opening it first if c.EncryptChallenge,
+// and returns its message along with the rest of what the server said about
+// it.
+func (c *Client) fetchChallenge(ctx context.Context) (string, dto.Challenge, error) {
+	challengeMsg := dto.Challenge{}
+	if !c.EncryptChallenge {
+		err := c.do(ctx, http.MethodGet, "/signIn", nil, &challengeMsg)
+		return challengeMsg.Message, challengeMsg, err
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", challengeMsg, err
+	}
+	query := url.Values{"x25519PublicKey": {dto.EncodeBinary(key.PublicKey().Bytes())}}
+	err = c.do(ctx, http.MethodGet, "/signIn?"+query.Encode(), nil, &challengeMsg)
+	if err != nil {
+		return "", challengeMsg, err
+	}
+	ephemeral, nonce, ciphertext, err := challengeMsg.DecodeSealed()
+	if err != nil {
+		return "", challengeMsg, err
+	}
+	message, err := challenge.Open(key, challenge.Sealed{EphemeralPublicKey: ephemeral, Nonce: nonce, Ciphertext: ciphertext})
+	return message, challengeMsg, err
+}
+
+// do sends body as JSON to path and decodes a 2xx response into out,
+// retrying as c.Retry says until ctx is done. A retried POST /signIn whose
+// challenge the server already consumed gets a 4xx and fails.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.doWithToken(ctx, method, path, "", body, out)
+}
+
+// doWithToken is do with token sent as a bearer token, unless it is "".
+func (c *Client) doWithToken(ctx context.Context, method, path, token string, body, out interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(c.Retry.BaseDelay << (attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		var retry bool
+		retry, err = c.doOnce(ctx, method, path, token, reqBody, out)
+		if !retry || attempt+1 >= c.Retry.MaxAttempts || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+// doOnce makes a single request and reports whether its failure is worth
+// retrying.
+func (c *Client) doOnce(ctx context.Context, method, path, token string, body []byte, out interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errRes := dto.ErrorResponse{}
+		json.NewDecoder(resp.Body).Decode(&errRes)
+		err := &StatusError{StatusCode: resp.StatusCode, Code: errRes.Code, Message: errRes.Message}
+		return resp.StatusCode >= 500, err
+	}
+	return false, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// StatusError is returned when the server answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("client: server answered %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/challenge"
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestSignIn(t *testing.T) {
+	t.Run("Test non-200 response is a StatusError", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":"internal_error","message":"error generating challenge"}`))
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(1)
+		_, err := New(srv.URL).SignIn(context.Background(), priv, pub)
+		statusErr := &StatusError{}
+		if !errors.As(err, &statusErr) {
+			t.Fatalf("expected a StatusError got %v", err)
+		}
+		if statusErr.StatusCode != http.StatusInternalServerError || statusErr.Code != "internal_error" {
+			t.Errorf("expected 500 internal_error got %d %s", statusErr.StatusCode, statusErr.Code)
+		}
+	})
+	t.Run("Test expired challenge is not answered", func(t *testing.T) {
+		posted := false
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				posted = true
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"message":"abc","expiresAt":%d,"ttlSeconds":120}`, time.Now().Add(-time.Second).Unix())
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(2)
+		_, err := New(srv.URL).SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, ErrChallengeExpired) {
+			t.Errorf("expected error to be %v got %v", ErrChallengeExpired, err)
+		}
+		if posted {
+			t.Errorf("expected the expired challenge not to be posted")
+		}
+	})
+	t.Run("Test proof of work is solved", func(t *testing.T) {
+		var nonce string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`{"message":"abc","difficulty":8}`))
+				return
+			}
+			response := dto.ChallengeResponse{}
+			json.NewDecoder(r.Body).Decode(&response)
+			nonce = response.Nonce
+			w.Write([]byte(`{"token":"a.b.c"}`))
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(5)
+		_, err := New(srv.URL).SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !challenge.SolvesWork("abc", nonce, 8) {
+			t.Errorf("expected nonce %q to solve difficulty 8", nonce)
+		}
+	})
+	t.Run("Test excessive difficulty is refused", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"message":"abc","difficulty":200}`))
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(6)
+		_, err := New(srv.URL).SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, ErrExcessiveDifficulty) {
+			t.Errorf("expected error to be %v got %v", ErrExcessiveDifficulty, err)
+		}
+	})
+	t.Run("Test proof of work gives up at the timeout", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"message":"abc","difficulty":%d}`, challenge.MaxDifficulty)
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(9)
+		c := New(srv.URL)
+		c.Timeout = 50 * time.Millisecond
+		start := time.Now()
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to be %v got %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("expected sign in to give up quickly took %s", time.Since(start))
+		}
+	})
+	t.Run("Test hung server hits the timeout", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(3)
+		c := New(srv.URL)
+		c.Timeout = 50 * time.Millisecond
+		start := time.Now()
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to be %v got %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("expected sign in to give up quickly took %s", time.Since(start))
+		}
+	})
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("Test transient failures are retried", func(t *testing.T) {
+		requests := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "application/json")
+			if requests <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`{"message":"abc"}`))
+				return
+			}
+			w.Write([]byte(`{"token":"a.b.c"}`))
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(4)
+		c := New(srv.URL)
+		c.Retry = RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond}
+		token, err := c.SignIn(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if token != "a.b.c" {
+			t.Errorf("expected token to be a.b.c got %s", token)
+		}
+		if requests != 4 {
+			t.Errorf("expected 4 requests got %d", requests)
+		}
+	})
+
+	t.Run("Test 4xx is not retried", func(t *testing.T) {
+		requests := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(5)
+		c := New(srv.URL)
+		c.Retry = RetryConfig{MaxAttempts: 3, BaseDelay: time.Millisecond}
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+		if requests != 1 {
+			t.Errorf("expected 1 request got %d", requests)
+		}
+	})
+
+	t.Run("Test backoff stops at the deadline", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		pub, priv := testutil.DeterministicEd25519(6)
+		c := New(srv.URL)
+		c.Timeout = 50 * time.Millisecond
+		c.Retry = RetryConfig{MaxAttempts: 10, BaseDelay: time.Second}
+		start := time.Now()
+		_, err := c.SignIn(context.Background(), priv, pub)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to be %v got %v", context.DeadlineExceeded, err)
+		}
+		if time.Since(start) > time.Second {
+			t.Errorf("expected sign in to give up quickly took %s", time.Since(start))
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/dto"
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// DefaultRefreshMargin renews a cached token a minute before it expires,
+// well inside the server's default -refresh-window.
+const DefaultRefreshMargin = time.Minute
+
+// tokenCache holds the last token Token got and who it was minted for.
+type tokenCache struct {
+	mu        sync.Mutex
+	publicKey ed25519.PublicKey
+	token     string
+	exp       time.Time
+}
+
+// Token returns a token for pub, reusing the one it last got while that is
+// more than c.RefreshMargin from its exp. Closer than that the token is
+// renewed through /refresh, or by signing in again with priv when it has
+// expired or the server won't renew it. Token is safe to call from several
+// goroutines; they wait on a single renewal rather than each signing in.
+func (c *Client) Token(ctx context.Context, priv ed25519.PrivateKey, pub ed25519.PublicKey) (string, error) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	now := time.Now()
+	cached := c.cache.token != "" && bytes.Equal(c.cache.publicKey, pub)
+	if cached && c.cache.exp.Sub(now) > c.RefreshMargin {
+		return c.cache.token, nil
+	}
+
+	var token string
+	var err error
+	if cached && now.Before(c.cache.exp) {
+		token, err = c.refresh(ctx, c.cache.token)
+	}
+	if token == "" || err != nil {
+		token, err = c.SignIn(ctx, priv, pub)
+		if err != nil {
+			return "", err
+		}
+	}
+	c.cache.token, c.cache.publicKey, c.cache.exp = token, pub, time.Time{}
+	claims, err := jws.Decode(token)
+	if err == nil {
+		c.cache.exp = time.Unix(claims.Exp, 0)
+	}
+	return token, nil
+}
+
+// refresh exchanges token for a renewed one at /refresh.
+func (c *Client) refresh(ctx context.Context, token string) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	renewed := dto.Jws{}
+	err := c.doWithToken(ctx, http.MethodPost, "/refresh", token, nil, &renewed)
+	return renewed.Token, err
+}
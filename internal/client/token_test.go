@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// tokenServer hands out tokens that expire ttl after they are minted and
+// counts the challenges and refreshes it serves.
+type tokenServer struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	minted     int
+	challenges int
+	refreshes  int
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/signIn":
+		s.challenges++
+		w.Write([]byte(`{"message":"abc"}`))
+		return
+	case r.Method == http.MethodPost && r.URL.Path == "/refresh":
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		s.refreshes++
+	}
+	s.minted++
+	now := time.Now()
+	claims := &jws.ClaimSet{Iss: "test", Jti: fmt.Sprint(s.minted), Iat: now.Add(-time.Hour).Unix(), Exp: now.Add(s.ttl).Unix()}
+	token, _ := jws.EncodeHS256(&jws.Header{Algorithm: "HS256", Typ: "JWT"}, claims, make([]byte, jws.MinHS256SecretSize))
+	fmt.Fprintf(w, `{"token":%q}`, token)
+}
+
+func TestToken(t *testing.T) {
+	pub, priv := testutil.DeterministicEd25519(7)
+
+	t.Run("Test valid token is reused", func(t *testing.T) {
+		s := &tokenServer{ttl: time.Hour}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		c := New(srv.URL)
+		first, err := c.Token(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		second, err := c.Token(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if second != first {
+			t.Errorf("expected the cached token to be reused")
+		}
+		if s.challenges != 1 {
+			t.Errorf("expected 1 challenge got %d", s.challenges)
+		}
+	})
+
+	t.Run("Test token within the margin is refreshed", func(t *testing.T) {
+		s := &tokenServer{ttl: 30 * time.Second}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		c := New(srv.URL)
+		first, _ := c.Token(context.Background(), priv, pub)
+		second, err := c.Token(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if second == first {
+			t.Errorf("expected a renewed token")
+		}
+		if s.challenges != 1 || s.refreshes != 1 {
+			t.Errorf("expected 1 challenge and 1 refresh got %d and %d", s.challenges, s.refreshes)
+		}
+	})
+
+	t.Run("Test expired token signs in again", func(t *testing.T) {
+		s := &tokenServer{ttl: -time.Second}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		c := New(srv.URL)
+		c.Token(context.Background(), priv, pub)
+		_, err := c.Token(context.Background(), priv, pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if s.challenges != 2 || s.refreshes != 0 {
+			t.Errorf("expected 2 challenges and no refresh got %d and %d", s.challenges, s.refreshes)
+		}
+	})
+
+	t.Run("Test another key gets its own token", func(t *testing.T) {
+		s := &tokenServer{ttl: time.Hour}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		c := New(srv.URL)
+		c.Token(context.Background(), priv, pub)
+		otherPub, otherPriv := testutil.DeterministicEd25519(8)
+		c.Token(context.Background(), otherPriv, otherPub)
+		if s.challenges != 2 {
+			t.Errorf("expected 2 challenges got %d", s.challenges)
+		}
+	})
+
+	t.Run("Test concurrent callers share one sign in", func(t *testing.T) {
+		s := &tokenServer{ttl: time.Hour}
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		c := New(srv.URL)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.Token(context.Background(), priv, pub)
+			}()
+		}
+		wg.Wait()
+		if s.challenges != 1 {
+			t.Errorf("expected 1 challenge got %d", s.challenges)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package dto
+
+type BatchChallengeResponse struct {
+	Responses []ChallengeResponse `json:"responses"`
+}
+
+type BatchResult struct {
+	Index int    `json:"index"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchSignIn struct {
+	Results []BatchResult `json:"results"`
+	AllOk   bool          `json:"allOk"`
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	b64 "encoding/base64"
+	"errors"
+)
+
+// Binary fields, such as keys, signatures, nonces and ciphertexts, travel as
+// unpadded base64url, the encoding JWS uses, so they are safe in URLs and
+// headers as they are. EncodeBinary and DecodeBinary are the one place that
+// is decided.
+
+// binaryEncodings are the encodings DecodeBinary accepts, in the order it
+// tries them. Standard base64 is what the DTOs used to carry, and is still
+// accepted from older clients.
+var binaryEncodings = []*b64.Encoding{
+	b64.RawURLEncoding,
+	b64.URLEncoding,
+	b64.RawStdEncoding,
+	b64.StdEncoding,
+}
+
+// ErrNotBase64 is returned when no base64 variant decodes a binary field.
+var ErrNotBase64 = errors.New("dto: binary field is not base64 or base64url")
+
+// EncodeBinary encodes b for a binary field.
+func EncodeBinary(b []byte) string {
+	return b64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeBinary decodes a binary field sent in any of the base64 and
+// base64url variants, padded or not.
+func DecodeBinary(s string) ([]byte, error) {
+	for _, enc := range binaryEncodings {
+		b, err := enc.DecodeString(s)
+		if err == nil {
+			return b, nil
+		}
+	}
+	return nil, ErrNotBase64
+}
@@ -0,0 +1,143 @@
+package dto
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// urlUnsafe encodes to "+/" in std base64 and "-_" in base64url, so it tells
+// the two apart.
+var urlUnsafe = []byte{0xfb, 0xff, 0xbf, 0xfe, 0xef}
+
+func TestDecodeBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantErr error
+	}{
+		{"Test base64url", b64.RawURLEncoding.EncodeToString(urlUnsafe), nil},
+		{"Test padded base64url", b64.URLEncoding.EncodeToString(urlUnsafe), nil},
+		{"Test std base64", b64.StdEncoding.EncodeToString(urlUnsafe), nil},
+		{"Test unpadded std base64", b64.RawStdEncoding.EncodeToString(urlUnsafe), nil},
+		{"Test not base64", "not base64!", ErrNotBase64},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := DecodeBinary(tt.s)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && !bytes.Equal(b, urlUnsafe) {
+				t.Errorf("expected %x got %x", urlUnsafe, b)
+			}
+		})
+	}
+
+	t.Run("Test encoding is URL safe", func(t *testing.T) {
+		s := EncodeBinary(urlUnsafe)
+		if strings.ContainsAny(s, "+/=") {
+			t.Errorf("expected no +, / or = in %s", s)
+		}
+	})
+}
+
+// roundTrip marshals in to JSON, checks no binary field came out std base64
+// encoded, and unmarshals it into out.
+func roundTrip(t *testing.T, in, out interface{}) {
+	t.Helper()
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if bytes.ContainsAny(b, "+/=") {
+		t.Errorf("expected URL-safe JSON got %s", b)
+	}
+	err = json.Unmarshal(b, out)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+}
+
+func TestBinaryFieldsRoundTrip(t *testing.T) {
+	t.Run("Test Challenge", func(t *testing.T) {
+		in := Challenge{Kid: "k1"}
+		in.SetServerSignature(urlUnsafe)
+		in.SetSealed(urlUnsafe, urlUnsafe[:3], urlUnsafe[1:])
+		out := Challenge{}
+		roundTrip(t, in, &out)
+
+		sig, err := out.DecodeServerSignature()
+		if err != nil || !bytes.Equal(sig, urlUnsafe) {
+			t.Errorf("expected server signature %x got %x, %v", urlUnsafe, sig, err)
+		}
+		ephemeral, nonce, ciphertext, err := out.DecodeSealed()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !bytes.Equal(ephemeral, urlUnsafe) || !bytes.Equal(nonce, urlUnsafe[:3]) || !bytes.Equal(ciphertext, urlUnsafe[1:]) {
+			t.Errorf("expected sealed challenge %x %x %x got %x %x %x", urlUnsafe, urlUnsafe[:3], urlUnsafe[1:], ephemeral, nonce, ciphertext)
+		}
+	})
+
+	t.Run("Test ChallengeResponse", func(t *testing.T) {
+		in := ChallengeResponse{Message: "abc"}
+		in.SetSignature(urlUnsafe)
+		in.SetPublicKey(urlUnsafe[1:])
+		out := ChallengeResponse{}
+		roundTrip(t, in, &out)
+
+		sig, err := out.DecodeSignature()
+		if err != nil || !bytes.Equal(sig, urlUnsafe) {
+			t.Errorf("expected signature %x got %x, %v", urlUnsafe, sig, err)
+		}
+		pub, err := out.DecodePublicKey()
+		if err != nil || !bytes.Equal(pub, urlUnsafe[1:]) {
+			t.Errorf("expected public key %x got %x, %v", urlUnsafe[1:], pub, err)
+		}
+	})
+
+	t.Run("Test KeySignature", func(t *testing.T) {
+		in := KeySignature{}
+		in.SetPublicKey(urlUnsafe[1:])
+		in.SetSignature(urlUnsafe)
+		out := KeySignature{}
+		roundTrip(t, in, &out)
+
+		pub, err := out.DecodePublicKey()
+		if err != nil || !bytes.Equal(pub, urlUnsafe[1:]) {
+			t.Errorf("expected public key %x got %x, %v", urlUnsafe[1:], pub, err)
+		}
+		sig, err := out.DecodeSignature()
+		if err != nil || !bytes.Equal(sig, urlUnsafe) {
+			t.Errorf("expected signature %x got %x, %v", urlUnsafe, sig, err)
+		}
+	})
+
+	t.Run("Test Enrollment", func(t *testing.T) {
+		in := Enrollment{Identity: "alice"}
+		in.SetPublicKey(urlUnsafe)
+		out := Enrollment{}
+		roundTrip(t, in, &out)
+
+		pub, err := out.DecodePublicKey()
+		if err != nil || !bytes.Equal(pub, urlUnsafe) {
+			t.Errorf("expected public key %x got %x, %v", urlUnsafe, pub, err)
+		}
+	})
+
+	t.Run("Test std base64 from older clients", func(t *testing.T) {
+		out := ChallengeResponse{}
+		err := json.Unmarshal([]byte(`{"signature":"`+b64.StdEncoding.EncodeToString(urlUnsafe)+`"}`), &out)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		sig, err := out.DecodeSignature()
+		if err != nil || !bytes.Equal(sig, urlUnsafe) {
+			t.Errorf("expected signature %x got %x, %v", urlUnsafe, sig, err)
+		}
+	})
+}
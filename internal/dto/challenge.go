@@ -1,11 +1,115 @@
 package dto
 
+import "encoding/json"
+
 type Challenge struct {
 	Message string `json:"message"`
+
+	// When the challenge stops being accepted, in Unix seconds, and the TTL
+	// it was issued with.
+	ExpiresAt  int64 `json:"expiresAt,omitempty"`
+	TTLSeconds int   `json:"ttlSeconds,omitempty"`
+
+	// Digest is the hash the server wants the challenge signed under when it
+	// runs in sha256 sign mode, e.g. "SHA-512".
+	Digest string `json:"digest,omitempty"`
+
+	// Difficulty is how many leading zero bits SHA-256 of the challenge
+	// followed by the response's Nonce must have. Zero asks for no work.
+	Difficulty int `json:"difficulty,omitempty"`
+
+	// With -sign-challenges, the server's signature over the plaintext
+	// challenge and the JWKS kid of the key that made it.
+	ServerSignature string `json:"serverSignature,omitempty"`
+	Kid             string `json:"kid,omitempty"`
+
+	// Set instead of Message when the challenge is sealed to the client's
+	// X25519 key.
+	EphemeralPublicKey string `json:"ephemeralPublicKey,omitempty"`
+	Nonce              string `json:"nonce,omitempty"`
+	Ciphertext         string `json:"ciphertext,omitempty"`
 }
 
 type ChallengeResponse struct {
 	Signature string `json:"signature"`
 	Message   string `json:"message"`
 	PublicKey string `json:"publicKey"`
+
+	// ClientData, when set, is a ClientData object and the signature covers
+	// its SHA-256, byte for byte as sent, instead of Message.
+	ClientData json.RawMessage `json:"clientData,omitempty"`
+
+	// Timestamp, when set, is the Unix time in seconds the response was
+	// signed at; the signature then covers Message followed by "." and the
+	// timestamp, and the server rejects it outside its timestamp window.
+	Timestamp int64 `json:"timestamp,omitempty"`
+
+	// Digest names the hash the challenge was signed under in sha256 sign
+	// mode; empty means SHA-256.
+	Digest string `json:"digest,omitempty"`
+
+	// Nonce is the proof of work for the challenge's Difficulty.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// SetServerSignature encodes sig into c.ServerSignature.
+func (c *Challenge) SetServerSignature(sig []byte) {
+	c.ServerSignature = EncodeBinary(sig)
+}
+
+// DecodeServerSignature decodes c.ServerSignature.
+func (c Challenge) DecodeServerSignature() ([]byte, error) {
+	return DecodeBinary(c.ServerSignature)
+}
+
+// SetSealed encodes a sealed challenge into c.
+func (c *Challenge) SetSealed(ephemeralPublicKey, nonce, ciphertext []byte) {
+	c.EphemeralPublicKey = EncodeBinary(ephemeralPublicKey)
+	c.Nonce = EncodeBinary(nonce)
+	c.Ciphertext = EncodeBinary(ciphertext)
+}
+
+// DecodeSealed decodes the sealed challenge in c.
+func (c Challenge) DecodeSealed() (ephemeralPublicKey, nonce, ciphertext []byte, err error) {
+	ephemeralPublicKey, err = DecodeBinary(c.EphemeralPublicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nonce, err = DecodeBinary(c.Nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ciphertext, err = DecodeBinary(c.Ciphertext)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ephemeralPublicKey, nonce, ciphertext, nil
+}
+
+// SetSignature encodes sig into r.Signature.
+func (r *ChallengeResponse) SetSignature(sig []byte) {
+	r.Signature = EncodeBinary(sig)
+}
+
+// DecodeSignature decodes r.Signature.
+func (r ChallengeResponse) DecodeSignature() ([]byte, error) {
+	return DecodeBinary(r.Signature)
+}
+
+// SetPublicKey encodes pub into r.PublicKey.
+func (r *ChallengeResponse) SetPublicKey(pub []byte) {
+	r.PublicKey = EncodeBinary(pub)
+}
+
+// DecodePublicKey decodes r.PublicKey.
+func (r ChallengeResponse) DecodePublicKey() ([]byte, error) {
+	return DecodeBinary(r.PublicKey)
+}
+
+// ClientData binds a signed challenge to where and why it was signed, like
+// WebAuthn's collected client data.
+type ClientData struct {
+	Type      string `json:"type"`
+	Origin    string `json:"origin"`
+	Challenge string `json:"challenge"`
 }
@@ -0,0 +1,11 @@
+package dto
+
+type Claims struct {
+	Iss   string `json:"iss"`
+	Sub   string `json:"sub,omitempty"`
+	Aud   string `json:"aud,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	Exp   int64  `json:"exp"`
+	Iat   int64  `json:"iat"`
+	Jti   string `json:"jti,omitempty"`
+}
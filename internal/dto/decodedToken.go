@@ -0,0 +1,11 @@
+package dto
+
+import "encoding/json"
+
+// DecodedToken is what a token says about itself. SignatureValid is only set
+// when the server holds the key named by the token's kid.
+type DecodedToken struct {
+	Header         json.RawMessage `json:"header"`
+	Claims         json.RawMessage `json:"claims"`
+	SignatureValid *bool           `json:"signatureValid,omitempty"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+type Enrollment struct {
+	Identity  string `json:"identity"`
+	PublicKey string `json:"publicKey,omitempty"`
+	// Certificate is a PEM certificate for the key, followed by any
+	// intermediates, for servers that run with -enroll-ca.
+	Certificate string `json:"certificate,omitempty"`
+	// Proof answers a challenge from GET /signIn with the key being
+	// enrolled, proving the client holds its private key.
+	Proof *ChallengeResponse `json:"proof,omitempty"`
+}
+
+// SetPublicKey encodes pub into e.PublicKey.
+func (e *Enrollment) SetPublicKey(pub []byte) {
+	e.PublicKey = EncodeBinary(pub)
+}
+
+// DecodePublicKey decodes e.PublicKey.
+func (e Enrollment) DecodePublicKey() ([]byte, error) {
+	return DecodeBinary(e.PublicKey)
+}
+
+// EnrolledKeys is the answer to GET /enroll/{identity}: the keys enrolled
+// under Identity, oldest first.
+type EnrolledKeys struct {
+	Identity string        `json:"identity"`
+	Keys     []EnrolledKey `json:"keys"`
+}
+
+// EnrolledKey is one enrolled public key and when, in Unix seconds, it was
+// enrolled.
+type EnrolledKey struct {
+	PublicKey  string `json:"publicKey"`
+	EnrolledAt int64  `json:"enrolledAt"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type Health struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+type Introspection struct {
+	Active bool   `json:"active"`
+	Iss    string `json:"iss,omitempty"`
+	Sub    string `json:"sub,omitempty"`
+	Aud    string `json:"aud,omitempty"`
+	Scope  string `json:"scope,omitempty"`
+	Exp    int64  `json:"exp,omitempty"`
+	Iat    int64  `json:"iat,omitempty"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+type Me struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+type Metrics struct {
+	Challenges *ChallengeMetrics `json:"challenges,omitempty"`
+}
+
+// ChallengeMetrics describes the in-memory challenge store.
+type ChallengeMetrics struct {
+	Live      int    `json:"live"`
+	Evictions uint64 `json:"evictions"`
+}
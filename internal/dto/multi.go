@@ -0,0 +1,41 @@
+package dto
+
+type KeySignature struct {
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// SetPublicKey encodes pub into ks.PublicKey.
+func (ks *KeySignature) SetPublicKey(pub []byte) {
+	ks.PublicKey = EncodeBinary(pub)
+}
+
+// DecodePublicKey decodes ks.PublicKey.
+func (ks KeySignature) DecodePublicKey() ([]byte, error) {
+	return DecodeBinary(ks.PublicKey)
+}
+
+// SetSignature encodes sig into ks.Signature.
+func (ks *KeySignature) SetSignature(sig []byte) {
+	ks.Signature = EncodeBinary(sig)
+}
+
+// DecodeSignature decodes ks.Signature.
+func (ks KeySignature) DecodeSignature() ([]byte, error) {
+	return DecodeBinary(ks.Signature)
+}
+
+// MultiChallengeResponse answers one challenge with signatures by several of
+// Identity's device keys.
+type MultiChallengeResponse struct {
+	Identity   string         `json:"identity"`
+	Message    string         `json:"message"`
+	Signatures []KeySignature `json:"signatures"`
+}
+
+type MultiSignIn struct {
+	Token string `json:"token"`
+	// Verified are the public keys whose signatures verified.
+	Verified  []string `json:"verified"`
+	Threshold int      `json:"threshold"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "time"
+
+type Readiness struct {
+	Status    string    `json:"status"`
+	LastCheck time.Time `json:"lastCheck"`
+	Error     string    `json:"error,omitempty"`
+}
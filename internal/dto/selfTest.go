@@ -0,0 +1,8 @@
+package dto
+
+// SelfTest says whether the server could mint a token with its active
+// signing key and verify it with the matching public key.
+type SelfTest struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
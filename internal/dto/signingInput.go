@@ -0,0 +1,29 @@
+package dto
+
+import "encoding/json"
+
+// SigningInputRequest carries either a JWS header and payload or what a
+// sign-in ChallengeResponse signs over, named as there.
+type SigningInputRequest struct {
+	Header    json.RawMessage `json:"header,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Challenge string          `json:"challenge,omitempty"`
+
+	ClientData json.RawMessage `json:"clientData,omitempty"`
+	Timestamp  int64           `json:"timestamp,omitempty"`
+	Digest     string          `json:"digest,omitempty"`
+
+	// Mode is the sign mode to assume; empty means the server's.
+	Mode string `json:"mode,omitempty"`
+}
+
+// SigningInput is the JWS signing input, or for a challenge the message as
+// signed (timestamped if so) or the client data as sent, with its SHA-256.
+// For a challenge, Verified is what Ed25519, or Ed25519ph in ed25519ph mode,
+// is called on.
+type SigningInput struct {
+	Input    string `json:"input"`
+	SHA256   string `json:"sha256"`
+	Mode     string `json:"mode,omitempty"`
+	Verified string `json:"verified,omitempty"`
+}
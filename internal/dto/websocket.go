@@ -0,0 +1,20 @@
+package dto
+
+// Types of the frames exchanged on /ws/signIn.
+const (
+	FrameChallenge = "challenge"
+	FrameResponse  = "response"
+	FrameToken     = "token"
+	FrameError     = "error"
+)
+
+// Frame is one message of the /ws/signIn handshake: the server sends a
+// challenge frame, the client answers with a response frame and the server
+// ends with a token or error frame. Type says which other field is set.
+type Frame struct {
+	Type      string             `json:"type"`
+	Challenge *Challenge         `json:"challenge,omitempty"`
+	Response  *ChallengeResponse `json:"response,omitempty"`
+	Token     string             `json:"token,omitempty"`
+	Error     *ErrorResponse     `json:"error,omitempty"`
+}
@@ -0,0 +1,84 @@
+package enrollment
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// ErrInvalidCertificate is returned when a certificate doesn't parse or
+	// doesn't chain to a trust anchor.
+	ErrInvalidCertificate = errors.New("enrollment: certificate does not validate")
+	// ErrUnsupportedKeyType is returned when a certificate certifies a key
+	// that can't sign in. Sign-in verifies Ed25519 signatures only, so an
+	// RSA key is refused like any other.
+	ErrUnsupportedKeyType = errors.New("enrollment: certificate key is not Ed25519")
+)
+
+// LoadCA reads the PEM certificates in path as the trust anchors enrollment
+// certificates must chain to.
+func LoadCA(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("enrollment: no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// CertifiedKey verifies the PEM bundle chain against roots at now and
+// returns the Ed25519 key its first certificate is for. Any further
+// certificates in the bundle are intermediates.
+func CertifiedKey(chain []byte, roots *x509.CertPool, now time.Time) (ed25519.PublicKey, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no PEM certificate", ErrInvalidCertificate)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCertificate, err)
+	}
+
+	switch pub := certs[0].PublicKey.(type) {
+	case ed25519.PublicKey:
+		return pub, nil
+	case *rsa.PublicKey:
+		return nil, fmt.Errorf("%w: got RSA", ErrUnsupportedKeyType)
+	default:
+		return nil, fmt.Errorf("%w: got %T", ErrUnsupportedKeyType, pub)
+	}
+}
@@ -0,0 +1,120 @@
+package enrollment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// newCert returns a PEM certificate for pub signed by parent's key, or
+// self-signed when parent is nil.
+func newCert(t *testing.T, name string, pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer, isCA bool) (*x509.Certificate, []byte) {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent = tmpl
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, parentKey)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertifiedKey(t *testing.T) {
+	caPub, caKey := testutil.DeterministicEd25519(1)
+	ca, caPEM := newCert(t, "ca", caPub, nil, caKey, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	t.Run("Test certificate from the CA", func(t *testing.T) {
+		pub, _ := testutil.DeterministicEd25519(2)
+		_, leaf := newCert(t, "device", pub, ca, caKey, false)
+		got, err := CertifiedKey(leaf, roots, time.Now())
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !got.Equal(pub) {
+			t.Errorf("expected the certified key")
+		}
+	})
+
+	t.Run("Test certificate through an intermediate", func(t *testing.T) {
+		interPub, interKey := testutil.DeterministicEd25519(3)
+		inter, interPEM := newCert(t, "intermediate", interPub, ca, caKey, true)
+		pub, _ := testutil.DeterministicEd25519(4)
+		_, leaf := newCert(t, "device", pub, inter, interKey, false)
+		_, err := CertifiedKey(append(leaf, interPEM...), roots, time.Now())
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		chain   func(t *testing.T) []byte
+		now     time.Time
+		wantErr error
+	}{
+		{"Test self-signed certificate", func(t *testing.T) []byte {
+			pub, priv := testutil.DeterministicEd25519(5)
+			_, leaf := newCert(t, "device", pub, nil, priv, false)
+			return leaf
+		}, time.Now(), ErrInvalidCertificate},
+		{"Test expired certificate", func(t *testing.T) []byte {
+			pub, _ := testutil.DeterministicEd25519(6)
+			_, leaf := newCert(t, "device", pub, ca, caKey, false)
+			return leaf
+		}, time.Now().Add(2 * time.Hour), ErrInvalidCertificate},
+		{"Test RSA key", func(t *testing.T) []byte {
+			key, _ := rsa.GenerateKey(rand.Reader, 2048)
+			_, leaf := newCert(t, "device", &key.PublicKey, ca, caKey, false)
+			return leaf
+		}, time.Now(), ErrUnsupportedKeyType},
+		{"Test not PEM", func(t *testing.T) []byte {
+			return []byte("device")
+		}, time.Now(), ErrInvalidCertificate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CertifiedKey(tt.chain(t), roots, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Test load CA", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		os.WriteFile(path, caPEM, 0o600)
+		_, err := LoadCA(path)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		os.WriteFile(path, []byte("not a certificate"), 0o600)
+		_, err = LoadCA(path)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
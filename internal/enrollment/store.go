@@ -0,0 +1,127 @@
+package enrollment
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAlreadyEnrolled is returned when enrolling a key that is already
+	// enrolled, under any identity.
+	ErrAlreadyEnrolled = errors.New("enrollment: public key is already enrolled")
+	// ErrEmptyIdentity is returned when enrolling a key under no identity.
+	ErrEmptyIdentity = errors.New("enrollment: identity is empty")
+	// ErrNotEnrolled is returned when unenrolling a key that isn't enrolled
+	// under the identity given.
+	ErrNotEnrolled = errors.New("enrollment: public key is not enrolled under identity")
+)
+
+// Store maps enrolled public keys to the identity they sign in as. An
+// identity may have several keys; a key belongs to one identity. Once a key
+// is enrolled under an identity, the identity stays claimed even after all
+// its keys are unenrolled. MemoryStore keeps them in memory.
+type Store interface {
+	Enroll(identity string, publicKey ed25519.PublicKey) error
+	Unenroll(identity string, publicKey ed25519.PublicKey) error
+	Identity(publicKey ed25519.PublicKey) (identity string, ok bool)
+	Keys(identity string) []ed25519.PublicKey
+	Enrolled(identity string) []EnrolledKey
+	Claimed(identity string) bool
+}
+
+// EnrolledKey is a key enrolled under an identity and when it was enrolled.
+type EnrolledKey struct {
+	PublicKey  ed25519.PublicKey
+	EnrolledAt time.Time
+}
+
+// MemoryStore is a Store that lives as long as the process.
+type MemoryStore struct {
+	now func() time.Time
+
+	mu         sync.RWMutex
+	identities map[string]string        // public key -> identity
+	keys       map[string][]EnrolledKey // claimed identities, even with no keys left
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		now:        time.Now,
+		identities: make(map[string]string),
+		keys:       make(map[string][]EnrolledKey),
+	}
+}
+
+// Enroll lets publicKey sign in as identity.
+func (s *MemoryStore) Enroll(identity string, publicKey ed25519.PublicKey) error {
+	if identity == "" {
+		return ErrEmptyIdentity
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.identities[string(publicKey)]; ok {
+		return ErrAlreadyEnrolled
+	}
+	s.identities[string(publicKey)] = identity
+	s.keys[identity] = append(s.keys[identity], EnrolledKey{
+		PublicKey:  append(ed25519.PublicKey(nil), publicKey...),
+		EnrolledAt: s.now(),
+	})
+	return nil
+}
+
+// Unenroll stops publicKey signing in as identity, as when a device is lost.
+// The identity stays claimed when its last key goes.
+func (s *MemoryStore) Unenroll(identity string, publicKey ed25519.PublicKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enrolledAs, ok := s.identities[string(publicKey)]; !ok || enrolledAs != identity {
+		return ErrNotEnrolled
+	}
+	delete(s.identities, string(publicKey))
+	keys := s.keys[identity]
+	for i, k := range keys {
+		if k.PublicKey.Equal(publicKey) {
+			keys = append(keys[:i:i], keys[i+1:]...)
+			break
+		}
+	}
+	s.keys[identity] = keys
+	return nil
+}
+
+// Identity returns the identity publicKey is enrolled under.
+func (s *MemoryStore) Identity(publicKey ed25519.PublicKey) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identity, ok := s.identities[string(publicKey)]
+	return identity, ok
+}
+
+// Keys returns the keys enrolled under identity, oldest first.
+func (s *MemoryStore) Keys(identity string) []ed25519.PublicKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []ed25519.PublicKey
+	for _, k := range s.keys[identity] {
+		keys = append(keys, k.PublicKey)
+	}
+	return keys
+}
+
+// Enrolled is like Keys but also says when each key was enrolled.
+func (s *MemoryStore) Enrolled(identity string) []EnrolledKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]EnrolledKey(nil), s.keys[identity]...)
+}
+
+// Claimed reports whether a key was ever enrolled under identity.
+func (s *MemoryStore) Claimed(identity string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.keys[identity]
+	return ok
+}
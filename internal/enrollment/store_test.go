@@ -0,0 +1,109 @@
+package enrollment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	pub1, _ := testutil.DeterministicEd25519(7)
+	pub2, _ := testutil.DeterministicEd25519(8)
+
+	t.Run("Test enroll and resolve", func(t *testing.T) {
+		err := s.Enroll("alice", pub1)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		identity, ok := s.Identity(pub1)
+		if !ok || identity != "alice" {
+			t.Errorf("expected identity to be alice got %q, %v", identity, ok)
+		}
+	})
+
+	t.Run("Test unknown key", func(t *testing.T) {
+		_, ok := s.Identity(pub2)
+		if ok {
+			t.Errorf("expected key not to be enrolled")
+		}
+	})
+
+	t.Run("Test key enrolled twice", func(t *testing.T) {
+		err := s.Enroll("bob", pub1)
+		if !errors.Is(err, ErrAlreadyEnrolled) {
+			t.Errorf("expected error to be %v got %v", ErrAlreadyEnrolled, err)
+		}
+	})
+
+	t.Run("Test empty identity", func(t *testing.T) {
+		err := s.Enroll("", pub2)
+		if !errors.Is(err, ErrEmptyIdentity) {
+			t.Errorf("expected error to be %v got %v", ErrEmptyIdentity, err)
+		}
+	})
+
+	t.Run("Test keys of an identity", func(t *testing.T) {
+		s.Enroll("alice", pub2)
+		keys := s.Keys("alice")
+		if len(keys) != 2 || !keys[0].Equal(pub1) || !keys[1].Equal(pub2) {
+			t.Errorf("expected alice to have both keys got %d", len(keys))
+		}
+	})
+
+	t.Run("Test unenroll", func(t *testing.T) {
+		err := s.Unenroll("bob", pub1)
+		if !errors.Is(err, ErrNotEnrolled) {
+			t.Errorf("expected error to be %v got %v", ErrNotEnrolled, err)
+		}
+		err = s.Unenroll("alice", pub1)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if _, ok := s.Identity(pub1); ok {
+			t.Errorf("expected key not to be enrolled")
+		}
+		keys := s.Keys("alice")
+		if len(keys) != 1 || !keys[0].Equal(pub2) {
+			t.Errorf("expected alice to keep only her other key got %d", len(keys))
+		}
+		err = s.Unenroll("alice", pub1)
+		if !errors.Is(err, ErrNotEnrolled) {
+			t.Errorf("expected error to be %v got %v", ErrNotEnrolled, err)
+		}
+	})
+
+	t.Run("Test identity stays claimed without keys", func(t *testing.T) {
+		if s.Claimed("dave") {
+			t.Errorf("expected dave not to be claimed")
+		}
+		pub, _ := testutil.DeterministicEd25519(60)
+		s.Enroll("dave", pub)
+		err := s.Unenroll("dave", pub)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(s.Keys("dave")) != 0 {
+			t.Errorf("expected dave to have no keys got %d", len(s.Keys("dave")))
+		}
+		if !s.Claimed("dave") {
+			t.Errorf("expected dave to stay claimed")
+		}
+	})
+
+	t.Run("Test enrollment times", func(t *testing.T) {
+		at := time.Unix(1700000000, 0)
+		s := NewMemoryStore()
+		s.now = func() time.Time { return at }
+		s.Enroll("carol", pub1)
+		enrolled := s.Enrolled("carol")
+		if len(enrolled) != 1 || !enrolled[0].PublicKey.Equal(pub1) || !enrolled[0].EnrolledAt.Equal(at) {
+			t.Errorf("expected carol's key enrolled at %v got %+v", at, enrolled)
+		}
+		if enrolled := s.Enrolled("nobody"); len(enrolled) != 0 {
+			t.Errorf("expected no keys for an unknown identity got %d", len(enrolled))
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package introspect
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+// Config controls how long introspection results are kept and how many of
+// them the cache holds at most.
+type Config struct {
+	// TTL bounds how long an active result is cached. An active result is
+	// never cached past the token's exp, whichever comes first.
+	TTL time.Duration
+	// NegativeTTL bounds how long an inactive result is cached.
+	NegativeTTL time.Duration
+	// MaxEntries bounds the number of cached results. When the cache is
+	// full the oldest entry is evicted.
+	MaxEntries int
+}
+
+// DefaultConfig is a short-lived cache suitable for a busy /introspect.
+var DefaultConfig = Config{
+	TTL:         30 * time.Second,
+	NegativeTTL: 10 * time.Second,
+	MaxEntries:  1024,
+}
+
+// Validate reports whether c is usable: every field must be positive.
+func (c Config) Validate() error {
+	if c.TTL <= 0 {
+		return fmt.Errorf("introspect: ttl must be positive, got %s", c.TTL)
+	}
+	if c.NegativeTTL <= 0 {
+		return fmt.Errorf("introspect: negative ttl must be positive, got %s", c.NegativeTTL)
+	}
+	if c.MaxEntries <= 0 {
+		return fmt.Errorf("introspect: max entries must be positive, got %d", c.MaxEntries)
+	}
+	return nil
+}
+
+// Result is the outcome of introspecting a token.
+type Result struct {
+	Active bool
+	Claims *jws.ClaimSet
+}
+
+type entry struct {
+	key       [sha256.Size]byte
+	result    Result
+	expiresAt time.Time
+}
+
+// Cache is a bounded, concurrency-safe cache of introspection results keyed
+// by a digest of the token, so the cache doesn't retain the tokens themselves.
+type Cache struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // oldest entry at the front
+}
+
+func NewCache(cfg Config) *Cache {
+	return &Cache{
+		cfg:     cfg,
+		now:     time.Now,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the cached result for token if there is a live one.
+func (c *Cache) Get(token string) (Result, bool) {
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return Result{}, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expiresAt) {
+		c.remove(el)
+		return Result{}, false
+	}
+	return e.result, true
+}
+
+// Put caches res for token. Active results expire at the token's exp at the
+// latest; results that would already be expired are not cached.
+func (c *Cache) Put(token string, res Result) {
+	now := c.now()
+	expiresAt := now.Add(c.cfg.NegativeTTL)
+	if res.Active {
+		expiresAt = now.Add(c.cfg.TTL)
+		if res.Claims != nil && res.Claims.Exp != 0 {
+			exp := time.Unix(res.Claims.Exp, 0)
+			if exp.Before(expiresAt) {
+				expiresAt = exp
+			}
+		}
+	}
+	if !now.Before(expiresAt) || c.cfg.MaxEntries <= 0 {
+		return
+	}
+
+	key := sha256.Sum256([]byte(token))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	for c.order.Len() >= c.cfg.MaxEntries {
+		c.remove(c.order.Front())
+	}
+	c.entries[key] = c.order.PushBack(&entry{
+		key:       key,
+		result:    res,
+		expiresAt: expiresAt,
+	})
+}
+
+// Len returns the number of cached results, including expired ones that
+// haven't been evicted yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
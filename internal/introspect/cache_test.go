@@ -0,0 +1,112 @@
+package introspect
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/jws"
+)
+
+func newTestCache(cfg Config, now *time.Time) *Cache {
+	c := NewCache(cfg)
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func TestCache(t *testing.T) {
+	cfg := Config{TTL: time.Minute, NegativeTTL: 10 * time.Second, MaxEntries: 2}
+
+	t.Run("Test active result is cached", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		c := newTestCache(cfg, &now)
+		c.Put("token", Result{Active: true, Claims: &jws.ClaimSet{Exp: 2000}})
+		res, ok := c.Get("token")
+		if !ok || !res.Active {
+			t.Errorf("expected active result to be cached got %v %v", res, ok)
+		}
+
+		now = now.Add(time.Minute)
+		_, ok = c.Get("token")
+		if ok {
+			t.Errorf("expected result to expire after TTL")
+		}
+	})
+
+	t.Run("Test active result is not cached past exp", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		c := newTestCache(cfg, &now)
+		c.Put("token", Result{Active: true, Claims: &jws.ClaimSet{Exp: 1005}})
+
+		now = time.Unix(1004, 0)
+		_, ok := c.Get("token")
+		if !ok {
+			t.Errorf("expected result to be cached before exp")
+		}
+
+		now = time.Unix(1005, 0)
+		_, ok = c.Get("token")
+		if ok {
+			t.Errorf("expected result not to be served at exp")
+		}
+	})
+
+	t.Run("Test already expired token is not cached", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		c := newTestCache(cfg, &now)
+		c.Put("token", Result{Active: true, Claims: &jws.ClaimSet{Exp: 900}})
+		if c.Len() != 0 {
+			t.Errorf("expected cache to be empty got %d", c.Len())
+		}
+	})
+
+	t.Run("Test inactive result uses negative TTL", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		c := newTestCache(cfg, &now)
+		c.Put("garbage", Result{})
+		res, ok := c.Get("garbage")
+		if !ok || res.Active {
+			t.Errorf("expected inactive result to be cached got %v %v", res, ok)
+		}
+
+		now = now.Add(10 * time.Second)
+		_, ok = c.Get("garbage")
+		if ok {
+			t.Errorf("expected inactive result to expire after negative TTL")
+		}
+	})
+
+	t.Run("Test cache is bounded", func(t *testing.T) {
+		now := time.Unix(1000, 0)
+		c := newTestCache(cfg, &now)
+		for i := 0; i < 5; i++ {
+			c.Put(fmt.Sprintf("token-%d", i), Result{})
+		}
+		if c.Len() != cfg.MaxEntries {
+			t.Errorf("expected cache to hold %d entries got %d", cfg.MaxEntries, c.Len())
+		}
+		_, ok := c.Get("token-0")
+		if ok {
+			t.Errorf("expected oldest entry to be evicted")
+		}
+		_, ok = c.Get("token-4")
+		if !ok {
+			t.Errorf("expected newest entry to be cached")
+		}
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	if err := DefaultConfig.Validate(); err != nil {
+		t.Errorf("expected error to be nil got %v", err)
+	}
+	for _, cfg := range []Config{
+		{TTL: 0, NegativeTTL: time.Second, MaxEntries: 1},
+		{TTL: time.Second, NegativeTTL: -time.Second, MaxEntries: 1},
+		{TTL: time.Second, NegativeTTL: time.Second, MaxEntries: 0},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected %+v not to validate", cfg)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package jws
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMissingIssuer is returned by Build when no issuer was set.
+	ErrMissingIssuer = errors.New("jws: claim set has no issuer")
+	// ErrInvalidTTL is returned by Build when the TTL is under a second, which
+	// could make the token expire as it is issued.
+	ErrInvalidTTL = errors.New("jws: claim set TTL must be at least a second")
+)
+
+// ClaimSetBuilder builds a ClaimSet whose times are computed rather than
+// written by hand, so a relative value like Exp: 3600 can't slip through.
+type ClaimSetBuilder struct {
+	claims ClaimSet
+	ttl    time.Duration
+}
+
+// NewClaimSet starts a claim set with no claims and no TTL.
+func NewClaimSet() *ClaimSetBuilder {
+	return &ClaimSetBuilder{}
+}
+
+// Issuer sets iss.
+func (b *ClaimSetBuilder) Issuer(iss string) *ClaimSetBuilder {
+	b.claims.Iss = iss
+	return b
+}
+
+// Audience sets aud.
+func (b *ClaimSetBuilder) Audience(aud string) *ClaimSetBuilder {
+	b.claims.Aud = aud
+	return b
+}
+
+// Subject sets sub.
+func (b *ClaimSetBuilder) Subject(sub string) *ClaimSetBuilder {
+	b.claims.Sub = sub
+	return b
+}
+
+// Scope sets scope to scopes joined by spaces. Empty scopes are dropped.
+func (b *ClaimSetBuilder) Scope(scopes ...string) *ClaimSetBuilder {
+	b.claims.Scope = strings.Join(strings.Fields(strings.Join(scopes, " ")), " ")
+	return b
+}
+
+// TTL sets how long the token is valid for, counted from Build.
+func (b *ClaimSetBuilder) TTL(d time.Duration) *ClaimSetBuilder {
+	b.ttl = d
+	return b
+}
+
+// Build sets iat and nbf to now and exp to now plus the TTL, and returns the
+// claim set once it has an issuer and exp is after iat. Each call returns a
+// new ClaimSet.
+func (b *ClaimSetBuilder) Build() (*ClaimSet, error) {
+	if b.claims.Iss == "" {
+		return nil, ErrMissingIssuer
+	}
+	if b.ttl < time.Second {
+		return nil, ErrInvalidTTL
+	}
+	now := timeNow()
+	claims := b.claims
+	claims.Iat = now.Unix()
+	claims.Nbf = claims.Iat
+	claims.Exp = now.Add(b.ttl).Unix()
+	if claims.Exp <= claims.Iat {
+		return nil, ErrInvalidTTL
+	}
+	return &claims, nil
+}
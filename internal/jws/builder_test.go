@@ -0,0 +1,71 @@
+package jws
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClaimSetBuilder(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *ClaimSetBuilder
+		wantErr error
+	}{
+		{"Test complete claim set", NewClaimSet().Issuer("issuer").TTL(time.Hour), nil},
+		{"Test missing issuer", NewClaimSet().Subject("device").TTL(time.Hour), ErrMissingIssuer},
+		{"Test zero TTL", NewClaimSet().Issuer("issuer"), ErrInvalidTTL},
+		{"Test negative TTL", NewClaimSet().Issuer("issuer").TTL(-time.Hour), ErrInvalidTTL},
+		{"Test TTL under a second", NewClaimSet().Issuer("issuer").TTL(time.Millisecond), ErrInvalidTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.builder.Build()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil && claims != nil {
+				t.Errorf("expected no claims on failure got %v", claims)
+			}
+		})
+	}
+
+	t.Run("Test timestamps", func(t *testing.T) {
+		before := time.Now().Unix()
+		claims, err := NewClaimSet().Issuer("issuer").TTL(time.Hour).Build()
+		after := time.Now().Unix()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Iat < before || claims.Iat > after {
+			t.Errorf("expected iat to be between %d and %d got %d", before, after, claims.Iat)
+		}
+		if claims.Nbf != claims.Iat {
+			t.Errorf("expected nbf to be %d got %d", claims.Iat, claims.Nbf)
+		}
+		if claims.Exp != claims.Iat+3600 {
+			t.Errorf("expected exp to be %d got %d", claims.Iat+3600, claims.Exp)
+		}
+	})
+
+	t.Run("Test claims", func(t *testing.T) {
+		claims, err := NewClaimSet().Issuer("issuer").Audience("billing").Subject("device").Scope("read", " write ", "").TTL(time.Hour).Build()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if claims.Iss != "issuer" || claims.Aud != "billing" || claims.Sub != "device" {
+			t.Errorf("expected iss, aud and sub to be issuer, billing and device got %s, %s and %s", claims.Iss, claims.Aud, claims.Sub)
+		}
+		if claims.Scope != "read write" {
+			t.Errorf("expected scope to be \"read write\" got %q", claims.Scope)
+		}
+	})
+
+	t.Run("Test built claims encode", func(t *testing.T) {
+		claims, _ := NewClaimSet().Issuer("issuer").TTL(time.Hour).Build()
+		_, err := claims.encode()
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
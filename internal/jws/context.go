@@ -0,0 +1,99 @@
+package jws
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// KeySource describes where the key used to verify a token came from.
+type KeySource string
+
+const (
+	// KeySourceEmbedded means the public key was carried in the token's iss claim.
+	KeySourceEmbedded KeySource = "embedded"
+	// KeySourceProvided means the caller passed in the key to verify with.
+	KeySourceProvided KeySource = "provided"
+	// KeySourceJWKS means the key was looked up by kid in a key set, such as
+	// a JWKS document or a KeySet.
+	KeySourceJWKS KeySource = "jwks"
+	// KeySourceDenyList means a deny list rejected the token after its
+	// signature verified, e.g. because its jti or subject was revoked.
+	KeySourceDenyList KeySource = "deny-list"
+)
+
+// VerificationContext records what was verified and how, for audit and
+// debugging. It deliberately carries no signature bytes.
+type VerificationContext struct {
+	Kid       string
+	Alg       string
+	Iss       string
+	Sub       string
+	Exp       int64
+	KeySource KeySource
+	Err       error
+}
+
+// Outcome returns "ok" for a successful verification or the error message.
+func (vc *VerificationContext) Outcome() string {
+	if vc.Err != nil {
+		return vc.Err.Error()
+	}
+	return "ok"
+}
+
+// LogValue implements slog.LogValuer.
+func (vc *VerificationContext) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("kid", vc.Kid),
+		slog.String("alg", vc.Alg),
+		slog.String("iss", vc.Iss),
+		slog.String("sub", vc.Sub),
+		slog.Int64("exp", vc.Exp),
+		slog.String("keySource", string(vc.KeySource)),
+		slog.String("outcome", vc.Outcome()),
+	)
+}
+
+func (vc *VerificationContext) String() string {
+	return fmt.Sprintf("kid=%q alg=%q iss=%q sub=%q exp=%d keySource=%s outcome=%q",
+		vc.Kid, vc.Alg, vc.Iss, vc.Sub, vc.Exp, vc.KeySource, vc.Outcome())
+}
+
+// logger returns the logger verification contexts are emitted to.
+// Tests replace it to capture output.
+var logger = slog.Default
+
+// NewVerificationContext describes token, as far as it decodes, for a
+// verification with a key from source.
+func NewVerificationContext(token string, source KeySource) *VerificationContext {
+	vc := &VerificationContext{KeySource: source}
+	if header, err := DecodeHeader(token); err == nil {
+		vc.Kid = header.KeyID
+		vc.Alg = header.Algorithm
+	}
+	if claims, err := Decode(token); err == nil {
+		vc.Iss = claims.Iss
+		vc.Sub = claims.Sub
+		vc.Exp = claims.Exp
+	}
+	return vc
+}
+
+// logOutcome emits a VerificationContext for token, verified with a key from
+// source, with outcome err, and returns err.
+func logOutcome(token string, source KeySource, err error) error {
+	vc := NewVerificationContext(token, source)
+	vc.Err = err
+	LogVerification(vc)
+	return err
+}
+
+// LogVerification emits vc at info level on success and warn level on failure.
+func LogVerification(vc *VerificationContext) {
+	level := slog.LevelInfo
+	if vc.Err != nil {
+		level = slog.LevelWarn
+	}
+	logger().LogAttrs(context.Background(), level, "jws: verification", slog.Any("verification", vc))
+}
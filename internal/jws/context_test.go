@@ -0,0 +1,207 @@
+package jws
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// captureVerifications redirects verification logs to a buffer for the
+// duration of the test.
+func captureVerifications(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	old := logger
+	l := slog.New(slog.NewJSONHandler(buf, nil))
+	logger = func() *slog.Logger { return l }
+	t.Cleanup(func() { logger = old })
+	return buf
+}
+
+func TestVerificationContext(t *testing.T) {
+	t.Run("Test successful verification is logged", func(t *testing.T) {
+		buf := captureVerifications(t)
+		token, err := Generate()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = Validate(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+
+		entry := struct {
+			Level        string `json:"level"`
+			Verification struct {
+				Kid       string `json:"kid"`
+				Alg       string `json:"alg"`
+				Iss       string `json:"iss"`
+				Sub       string `json:"sub"`
+				Exp       int64  `json:"exp"`
+				KeySource string `json:"keySource"`
+				Outcome   string `json:"outcome"`
+			} `json:"verification"`
+		}{}
+		err = json.Unmarshal(buf.Bytes(), &entry)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		claims, _ := Decode(token)
+		v := entry.Verification
+		if entry.Level != "INFO" {
+			t.Errorf("expected level to be INFO got %s", entry.Level)
+		}
+		if v.Alg != "RS256" {
+			t.Errorf("expected alg to be RS256 got %s", v.Alg)
+		}
+		if v.Iss != claims.Iss {
+			t.Errorf("expected iss to be %s got %s", claims.Iss, v.Iss)
+		}
+		if v.Exp != claims.Exp {
+			t.Errorf("expected exp to be %d got %d", claims.Exp, v.Exp)
+		}
+		if v.KeySource != string(KeySourceEmbedded) {
+			t.Errorf("expected key source to be embedded got %s", v.KeySource)
+		}
+		if v.Outcome != "ok" {
+			t.Errorf("expected outcome to be ok got %s", v.Outcome)
+		}
+		parts := strings.Split(token, ".")
+		if strings.Contains(buf.String(), parts[2]) {
+			t.Errorf("expected signature not to be logged")
+		}
+	})
+
+	t.Run("Test failed verification is logged", func(t *testing.T) {
+		buf := captureVerifications(t)
+		token, _ := Generate()
+		parts := strings.Split(token, ".")
+		tampered := parts[0] + "." + parts[1] + ".AAAA"
+		err := Validate(tampered)
+		if err == nil {
+			t.Fatalf("expected error not to be nil")
+		}
+
+		out := buf.String()
+		if !strings.Contains(out, `"level":"WARN"`) {
+			t.Errorf("expected a warn entry got %s", out)
+		}
+		if !strings.Contains(out, `"outcome":"`+err.Error()) {
+			t.Errorf("expected outcome to contain %q got %s", err, out)
+		}
+		if !strings.Contains(out, `"alg":"RS256"`) {
+			t.Errorf("expected alg to be logged got %s", out)
+		}
+	})
+
+	t.Run("Test string form", func(t *testing.T) {
+		vc := &VerificationContext{Kid: "k1", Alg: "RS256", Sub: "device", Exp: 10, KeySource: KeySourceEmbedded}
+		s := vc.String()
+		for _, want := range []string{`kid="k1"`, `alg="RS256"`, `sub="device"`, `exp=10`, `keySource=embedded`, `outcome="ok"`} {
+			if !strings.Contains(s, want) {
+				t.Errorf("expected %q to contain %s", s, want)
+			}
+		}
+	})
+}
+
+// verificationEntries decodes the verification log entries in buf.
+func verificationEntries(t *testing.T, buf *bytes.Buffer) []map[string]string {
+	t.Helper()
+	entries := []map[string]string{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		entry := struct {
+			Level        string         `json:"level"`
+			Verification map[string]any `json:"verification"`
+		}{}
+		err := dec.Decode(&entry)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		fields := map[string]string{"level": entry.Level}
+		for k, v := range entry.Verification {
+			fields[k] = fmt.Sprint(v)
+		}
+		entries = append(entries, fields)
+	}
+	return entries
+}
+
+func TestVerificationContextKeySources(t *testing.T) {
+	_, key := testutil.DeterministicEd25519(41)
+	header, err := HeaderForKey(key.Public())
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	token, err := EncodeWithKey(&header, &ClaimSet{Sub: "device"}, key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	secret := bytes.Repeat([]byte("s"), MinHS256SecretSize)
+
+	tests := []struct {
+		name    string
+		verify  func() error
+		source  KeySource
+		outcome string
+	}{
+		{"Test KeySet.Verify", func() error { return NewKeySet(key, header).Verify(token) }, KeySourceJWKS, "ok"},
+		{"Test VerifyAny", func() error {
+			return VerifyAny(token, map[string]crypto.PublicKey{header.KeyID: key.Public()})
+		}, KeySourceJWKS, "ok"},
+		{"Test VerifyEd25519", func() error { return VerifyEd25519(token, key.Public().(ed25519.PublicKey)) }, KeySourceProvided, "ok"},
+		{"Test VerifyWithKey", func() error { return VerifyWithKey(token, key.Public()) }, KeySourceProvided, "ok"},
+		{"Test VerifyHS256", func() error { return VerifyHS256(token, secret) }, KeySourceProvided, ""},
+		{"Test VerifyRSA", func() error {
+			rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+			return VerifyRSA(token, &rsaKey.PublicKey, PaddingPKCS1v15)
+		}, KeySourceProvided, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := captureVerifications(t)
+			err := tc.verify()
+
+			entries := verificationEntries(t, buf)
+			if len(entries) != 1 {
+				t.Fatalf("expected 1 verification entry got %d: %v", len(entries), entries)
+			}
+			v := entries[0]
+			if v["keySource"] != string(tc.source) {
+				t.Errorf("expected key source to be %s got %s", tc.source, v["keySource"])
+			}
+			if v["kid"] != header.KeyID || v["alg"] != "EdDSA" || v["sub"] != "device" {
+				t.Errorf("expected kid %s, alg EdDSA and sub device got %v", header.KeyID, v)
+			}
+			if tc.outcome == "ok" {
+				if err != nil || v["outcome"] != "ok" || v["level"] != "INFO" {
+					t.Errorf("expected an ok info entry got %v (error %v)", v, err)
+				}
+			} else if err == nil || v["outcome"] != err.Error() || v["level"] != "WARN" {
+				t.Errorf("expected a warn entry with outcome %v got %v", err, v)
+			}
+		})
+	}
+
+	t.Run("Test Validate is logged once", func(t *testing.T) {
+		token, err := Generate()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		buf := captureVerifications(t)
+		Validate(token)
+		if entries := verificationEntries(t, buf); len(entries) != 1 {
+			t.Errorf("expected 1 verification entry got %d: %v", len(entries), entries)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package jws
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// EncodeEd25519 encodes a JWS signed with the given Ed25519 private key.
+// The header's alg is set to EdDSA and, per RFC 8037, the signing input is
+// signed directly, without pre-hashing.
+func EncodeEd25519(header *Header, c *ClaimSet, key ed25519.PrivateKey) (string, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return "", errors.New("jws: invalid Ed25519 private key")
+	}
+	return EncodeWithKeySigner(header, c, Ed25519Signer{Key: key})
+}
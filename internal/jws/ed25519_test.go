@@ -0,0 +1,62 @@
+package jws
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestEd25519(t *testing.T) {
+	pub, priv := testutil.DeterministicEd25519(14)
+	now := time.Now().Unix()
+	claims := &ClaimSet{Iss: "server", Sub: "device", Exp: now + 3600, Iat: now}
+
+	token, err := EncodeEd25519(&Header{Typ: "JWT"}, claims, priv)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	t.Run("Test round trip", func(t *testing.T) {
+		err := VerifyEd25519(token, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		h, _ := DecodeHeader(token)
+		if h.Algorithm != "EdDSA" {
+			t.Errorf("expected alg to be EdDSA got %s", h.Algorithm)
+		}
+		decoded, _ := Decode(token)
+		if decoded.Sub != "device" {
+			t.Errorf("expected sub to be device got %s", decoded.Sub)
+		}
+	})
+
+	t.Run("Test signature covers the raw signing input", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		if !ed25519.Verify(pub, []byte(parts[0]+"."+parts[1]), sig) {
+			t.Errorf("expected signature over the unhashed signing input")
+		}
+	})
+
+	t.Run("Test tampered payload is rejected", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"server","sub":"admin","exp":3610,"iat":10}`))
+		err := VerifyEd25519(parts[0]+"."+payload+"."+parts[2], pub)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test other key is rejected", func(t *testing.T) {
+		other, _ := testutil.DeterministicEd25519(15)
+		err := VerifyEd25519(token, other)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
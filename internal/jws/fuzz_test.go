@@ -0,0 +1,140 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// fuzzSeeds are well-formed and malformed tokens to start mutating from.
+func fuzzSeeds(f *testing.F) {
+	f.Helper()
+	token, err := Generate()
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range []string{
+		token,
+		"",
+		".",
+		"..",
+		"...",
+		"a.b.c",
+		"e30.e30.",
+		"eyJhbGciOiJub25lIn0.e30.",
+		"!!!.@@@.###",
+		"e30.W10.sig",
+	} {
+		f.Add(seed)
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	fuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, token string) {
+		claims, err := Decode(token)
+		if err == nil && claims == nil {
+			t.Errorf("expected claims or an error for %q", token)
+		}
+		_, _ = DecodeHeader(token)
+		_, _ = DecodeWithOptions(token, ParseOptions{LegacyEncoding: true})
+	})
+}
+
+func FuzzVerify(f *testing.F) {
+	fuzzSeeds(f)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		f.Fatal(err)
+	}
+	edPub, _ := testutil.DeterministicEd25519(1)
+	keys := map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ed": edPub}
+	f.Fuzz(func(t *testing.T, token string) {
+		// None of these tokens were signed by these keys.
+		if Verify(token, &rsaKey.PublicKey) == nil {
+			t.Errorf("expected Verify to fail for %q", token)
+		}
+		if VerifyEd25519(token, edPub) == nil {
+			t.Errorf("expected VerifyEd25519 to fail for %q", token)
+		}
+		if VerifyAny(token, keys) == nil {
+			t.Errorf("expected VerifyAny to fail for %q", token)
+		}
+		_ = Validate(token)
+	})
+}
+
+func TestDecodeSegmentCap(t *testing.T) {
+	// Segments are capped on their own for callers that raise MaxTokenSize.
+	old := MaxTokenSize
+	MaxTokenSize = 2 * maxSegmentLen
+	defer func() { MaxTokenSize = old }()
+	huge := strings.Repeat("A", maxSegmentLen+1)
+	for name, token := range map[string]string{
+		"header":  huge + ".e30.sig",
+		"payload": "e30." + huge + ".sig",
+	} {
+		t.Run("Test oversized "+name, func(t *testing.T) {
+			_, err := Decode(token)
+			_, headerErr := DecodeHeader(token)
+			if !errors.Is(err, ErrInvalidToken) && !errors.Is(headerErr, ErrInvalidToken) {
+				t.Errorf("expected error to be %v got %v and %v", ErrInvalidToken, err, headerErr)
+			}
+		})
+	}
+}
+
+func TestMaxTokenSize(t *testing.T) {
+	edPub, _ := testutil.DeterministicEd25519(1)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	oversized := "e30." + strings.Repeat("A", MaxTokenSize) + ".sig"
+	checks := map[string]func(string) error{
+		"Decode":        func(token string) error { _, err := Decode(token); return err },
+		"DecodeHeader":  func(token string) error { _, err := DecodeHeader(token); return err },
+		"Verify":        func(token string) error { return Verify(token, &rsaKey.PublicKey) },
+		"VerifyRSA":     func(token string) error { return VerifyRSA(token, &rsaKey.PublicKey, PaddingPSS) },
+		"VerifyEd25519": func(token string) error { return VerifyEd25519(token, edPub) },
+		"VerifyHS256":   func(token string) error { return VerifyHS256(token, make([]byte, MinHS256SecretSize)) },
+		"VerifyAny":     func(token string) error { return VerifyAny(token, map[string]crypto.PublicKey{"k": edPub}) },
+		"VerifyWithKey": func(token string) error { return VerifyWithKey(token, edPub) },
+	}
+	for name, check := range checks {
+		t.Run("Test "+name+" rejects an oversized token", func(t *testing.T) {
+			err := check(oversized)
+			if !errors.Is(err, ErrTokenTooLarge) {
+				t.Errorf("expected error to be %v got %v", ErrTokenTooLarge, err)
+			}
+		})
+	}
+
+	t.Run("Test extra segments stay unsplit", func(t *testing.T) {
+		parts, err := splitToken(strings.Repeat(".", MaxTokenSize))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(parts) != tokenSegments+1 {
+			t.Errorf("expected %d parts got %d", tokenSegments+1, len(parts))
+		}
+	})
+}
+
+// BenchmarkDecodeOversized shows an oversized token is rejected without
+// allocating in proportion to its size: allocs/op and B/op stay flat as the
+// token grows.
+func BenchmarkDecodeOversized(b *testing.B) {
+	for _, size := range []int{MaxTokenSize + 1, 1 << 20} {
+		token := "e30." + strings.Repeat("A", size) + ".sig"
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Decode(token)
+			}
+		})
+	}
+}
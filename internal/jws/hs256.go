@@ -0,0 +1,7 @@
+package jws
+
+// EncodeHS256 encodes a JWS MACed with secret, which must be at least
+// MinHS256SecretSize bytes. The header's alg is set to HS256.
+func EncodeHS256(header *Header, c *ClaimSet, secret []byte) (string, error) {
+	return EncodeWithKeySigner(header, c, HS256Signer{Secret: secret})
+}
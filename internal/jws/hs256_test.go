@@ -0,0 +1,81 @@
+package jws
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHS256(t *testing.T) {
+	secret := bytes.Repeat([]byte("s"), MinHS256SecretSize)
+	now := time.Now().Unix()
+	claims := &ClaimSet{Iss: "server", Sub: "device", Exp: now + 3600, Iat: now}
+
+	token, err := EncodeHS256(&Header{Typ: "JWT", KeyID: "shared"}, claims, secret)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	t.Run("Test round trip", func(t *testing.T) {
+		err := VerifyHS256(token, secret)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		h, _ := DecodeHeader(token)
+		if h.Algorithm != "HS256" {
+			t.Errorf("expected alg to be HS256 got %s", h.Algorithm)
+		}
+		decoded, _ := Decode(token)
+		if decoded.Sub != "device" {
+			t.Errorf("expected sub to be device got %s", decoded.Sub)
+		}
+	})
+
+	t.Run("Test wrong secret is rejected", func(t *testing.T) {
+		err := VerifyHS256(token, bytes.Repeat([]byte("o"), MinHS256SecretSize))
+		if !errors.Is(err, ErrBadSignature) {
+			t.Errorf("expected error to be %v got %v", ErrBadSignature, err)
+		}
+	})
+
+	t.Run("Test tampered payload is rejected", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		other, _ := EncodeHS256(&Header{Typ: "JWT"}, &ClaimSet{Sub: "admin"}, secret)
+		err := VerifyHS256(parts[0]+"."+strings.Split(other, ".")[1]+"."+parts[2], secret)
+		if !errors.Is(err, ErrBadSignature) {
+			t.Errorf("expected error to be %v got %v", ErrBadSignature, err)
+		}
+	})
+
+	t.Run("Test short secret is refused", func(t *testing.T) {
+		_, err := EncodeHS256(&Header{}, claims, []byte("short"))
+		if !errors.Is(err, ErrShortHS256Secret) {
+			t.Errorf("expected error to be %v got %v", ErrShortHS256Secret, err)
+		}
+		err = VerifyHS256(token, []byte("short"))
+		if !errors.Is(err, ErrShortHS256Secret) {
+			t.Errorf("expected error to be %v got %v", ErrShortHS256Secret, err)
+		}
+	})
+
+	t.Run("Test VerifyAny dispatches HS256 to a secret", func(t *testing.T) {
+		err := VerifyAny(token, map[string]crypto.PublicKey{"shared": secret})
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test VerifyAny never uses a public key as the secret", func(t *testing.T) {
+		edKey, _, err := LoadSigningKey("testdata/ed25519.pem")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = VerifyAny(token, map[string]crypto.PublicKey{"shared": edKey.Public()})
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
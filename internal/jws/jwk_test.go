@@ -0,0 +1,51 @@
+package jws
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestJWK(t *testing.T) {
+	edPub, _ := testutil.DeterministicEd25519(16)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	set := JWKS{}
+	for kid, pub := range map[string]any{"ed": edPub, "rsa": &rsaKey.PublicKey} {
+		jwk, err := NewJWK(pub, kid, "")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	t.Run("Test Ed25519 round trip", func(t *testing.T) {
+		pub, err := set.Key("ed")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !edPub.Equal(pub) {
+			t.Errorf("expected recovered key to equal the original")
+		}
+	})
+
+	t.Run("Test RSA round trip", func(t *testing.T) {
+		pub, err := set.Key("rsa")
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !rsaKey.PublicKey.Equal(pub) {
+			t.Errorf("expected recovered key to equal the original")
+		}
+	})
+
+	t.Run("Test unknown kid", func(t *testing.T) {
+		_, err := set.Key("missing")
+		if !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("expected error to be ErrUnknownKeyID got %v", err)
+		}
+	})
+}
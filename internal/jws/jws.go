@@ -2,10 +2,8 @@ package jws
 
 import (
 	"bytes"
-	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -23,6 +21,7 @@ type ClaimSet struct {
 	Aud   string `json:"aud"`             // descriptor of the intended target of the assertion (Optional).
 	Exp   int64  `json:"exp"`             // the expiration time of the assertion (seconds since Unix epoch)
 	Iat   int64  `json:"iat"`             // the time the assertion was issued (seconds since Unix epoch)
+	Nbf   int64  `json:"nbf,omitempty"`   // the time before which the assertion must not be accepted (Optional).
 	Typ   string `json:"typ,omitempty"`   // token type (Optional).
 
 	// Email for which the application is requesting delegated access (Optional).
@@ -32,22 +31,38 @@ type ClaimSet struct {
 	// complaint with legacy OAuth 2.0 providers. (Optional)
 	Prn string `json:"prn,omitempty"`
 
+	// Unique identifier of the token, for single-use tokens and revocation
+	// (Optional).
+	Jti string `json:"jti,omitempty"`
+
 	// See http://tools.ietf.org/html/draft-jones-json-web-token-10#section-4.3
 	// This array is marshalled using custom code (see (c *ClaimSet) encode()).
 	PrivateClaims map[string]interface{} `json:"-"`
 }
 
+// timeNow is the clock every time the package reads comes from: defaults in
+// encode, Generate and ClaimSetBuilder, and the checks in checkTimes. Tests
+// replace it to pin the time.
+var timeNow = time.Now
+
+// minPlausibleExp is the earliest Exp encode accepts. Anything before it is
+// almost certainly a relative value like 3600 mistaken for a Unix time.
+var minPlausibleExp = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
 func (c *ClaimSet) encode() (string, error) {
 	// Reverting time back for machines whose time is not perfectly in sync.
 	// If client machine's time is in the future according
 	// to Google servers, an access token will not be issued.
-	now := time.Now().Add(-10 * time.Second)
+	now := timeNow().Add(-10 * time.Second)
 	if c.Iat == 0 {
 		c.Iat = now.Unix()
 	}
 	if c.Exp == 0 {
 		c.Exp = now.Add(time.Hour).Unix()
 	}
+	if c.Exp < minPlausibleExp {
+		return "", fmt.Errorf("jws: invalid Exp = %v; must be a Unix time, not a duration", c.Exp)
+	}
 	if c.Exp < c.Iat {
 		return "", fmt.Errorf("jws: invalid Exp = %v; must be later than Iat = %v", c.Exp, c.Iat)
 	}
@@ -76,9 +91,107 @@ func (c *ClaimSet) encode() (string, error) {
 	}
 	b[len(b)-1] = ','         // Replace closing curly brace with a comma.
 	b = append(b, prv[1:]...) // Append private claims.
+
+	// A private claim must not shadow a registered one, or Decode would reject
+	// the token we just minted.
+	err = checkDuplicateClaims(b)
+	if err != nil {
+		return "", err
+	}
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// registeredClaims are the JSON names of the ClaimSet fields.
+var registeredClaims = map[string]bool{
+	"iss": true, "scope": true, "aud": true, "exp": true,
+	"iat": true, "nbf": true, "typ": true, "sub": true, "prn": true, "jti": true,
+}
+
+// isRegisteredClaim reports whether encoding/json decodes key into a
+// ClaimSet field, which it matches case-insensitively: "EXP" and "ſub" are
+// registered claims too.
+func isRegisteredClaim(key string) bool {
+	return registeredClaims[strings.ToLower(foldKey(key))]
+}
+
+// UnmarshalJSON decodes the registered claims into their fields and every
+// other claim into PrivateClaims, so a decoded claim set re-encodes to the
+// same claims.
+func (c *ClaimSet) UnmarshalJSON(b []byte) error {
+	type claimSet ClaimSet
+	err := json.Unmarshal(b, (*claimSet)(c))
+	if err != nil {
+		return err
+	}
+
+	all := map[string]interface{}{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err = dec.Decode(&all)
+	if err != nil {
+		return err
+	}
+	c.PrivateClaims = nil
+	for k, v := range all {
+		if isRegisteredClaim(k) {
+			continue
+		}
+		if c.PrivateClaims == nil {
+			c.PrivateClaims = make(map[string]interface{})
+		}
+		c.PrivateClaims[k] = v
+	}
+	return nil
+}
+
+// AllClaims returns the registered claims that are set merged with the
+// private claims, keyed by their JSON names. Registered claims take
+// precedence over private claims with the same name.
+func (c *ClaimSet) AllClaims() map[string]interface{} {
+	m := make(map[string]interface{}, len(c.PrivateClaims)+8)
+	for k, v := range c.PrivateClaims {
+		m[k] = v
+	}
+	for k, v := range map[string]string{
+		"iss":   c.Iss,
+		"scope": c.Scope,
+		"aud":   c.Aud,
+		"typ":   c.Typ,
+		"sub":   c.Sub,
+		"prn":   c.Prn,
+		"jti":   c.Jti,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if c.Exp != 0 {
+		m["exp"] = c.Exp
+	}
+	if c.Iat != 0 {
+		m["iat"] = c.Iat
+	}
+	if c.Nbf != 0 {
+		m["nbf"] = c.Nbf
+	}
+	return m
+}
+
+// Scopes returns the space-delimited scopes in c.Scope.
+func (c *ClaimSet) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether name is one of c's scopes.
+func (c *ClaimSet) HasScope(name string) bool {
+	for _, s := range c.Scopes() {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Header represents the header for the signed JWS payloads.
 type Header struct {
 	// The algorithm used for signature.
@@ -99,28 +212,12 @@ func (h *Header) encode() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-// Decode decodes a claim set from a JWS payload.
-func Decode(payload string) (*ClaimSet, error) {
-	// decode returned id token to get expiry
-	s := strings.Split(payload, ".")
-	if len(s) < 2 {
-		// TODO(jbd): Provide more context about the error.
-		return nil, errors.New("jws: invalid token received")
-	}
-	decoded, err := base64.RawURLEncoding.DecodeString(s[1])
-	if err != nil {
-		return nil, err
-	}
-	c := &ClaimSet{}
-	err = json.NewDecoder(bytes.NewBuffer(decoded)).Decode(c)
-	return c, err
-}
-
 // Signer returns a signature for the given data.
 type Signer func(data []byte) (sig []byte, err error)
 
-// EncodeWithSigner encodes a header and claim set with the provided signer.
-func EncodeWithSigner(header *Header, c *ClaimSet, sg Signer) (string, error) {
+// SigningInput returns the exact string that is signed for header and c:
+// the base64url-encoded header and claim set joined by a dot.
+func SigningInput(header *Header, c *ClaimSet) (string, error) {
 	head, err := header.encode()
 	if err != nil {
 		return "", err
@@ -129,7 +226,15 @@ func EncodeWithSigner(header *Header, c *ClaimSet, sg Signer) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	ss := fmt.Sprintf("%s.%s", head, cs)
+	return fmt.Sprintf("%s.%s", head, cs), nil
+}
+
+// EncodeWithSigner encodes a header and claim set with the provided signer.
+func EncodeWithSigner(header *Header, c *ClaimSet, sg Signer) (string, error) {
+	ss, err := SigningInput(header, c)
+	if err != nil {
+		return "", err
+	}
 	sig, err := sg([]byte(ss))
 	if err != nil {
 		return "", err
@@ -138,36 +243,24 @@ func EncodeWithSigner(header *Header, c *ClaimSet, sg Signer) (string, error) {
 }
 
 // Encode encodes a signed JWS with provided header and claim set.
-// This invokes EncodeWithSigner using crypto/rsa.SignPKCS1v15 with the given RSA private key.
+// This invokes EncodeRSA with DefaultRSAPadding and the given RSA private key.
 func Encode(header *Header, c *ClaimSet, key *rsa.PrivateKey) (string, error) {
-	sg := func(data []byte) (sig []byte, err error) {
-		h := sha256.New()
-		h.Write(data)
-		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h.Sum(nil))
-	}
-	return EncodeWithSigner(header, c, sg)
+	return EncodeRSA(header, c, key, DefaultRSAPadding)
 }
 
-// Verify tests whether the provided JWT token's signature was produced by the private key
-// associated with the supplied public key.
-func Verify(token string, key *rsa.PublicKey) error {
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return errors.New("jws: invalid token received, token must have 3 parts")
-	}
-
-	signedContent := parts[0] + "." + parts[1]
-	signatureString, err := base64.RawURLEncoding.DecodeString(parts[2])
-	if err != nil {
-		return err
-	}
-
-	h := sha256.New()
-	h.Write([]byte(signedContent))
-	return rsa.VerifyPKCS1v15(key, crypto.SHA256, h.Sum(nil), signatureString)
+func Generate() (string, error) {
+	now := timeNow()
+	return GenerateWithClaims(&ClaimSet{
+		Aud: "",
+		Iat: now.Unix(),
+		Exp: now.Add(time.Hour).Unix(),
+	})
 }
 
-func Generate() (string, error) {
+// GenerateWithClaims signs payload with a fresh RSA key whose public key is
+// embedded in the iss claim, overwriting any iss already set, so the token
+// can later be checked with Validate.
+func GenerateWithClaims(payload *ClaimSet) (string, error) {
 	header := &Header{
 		Algorithm: "RS256",
 		Typ:       "JWT",
@@ -178,55 +271,140 @@ func Generate() (string, error) {
 		return "", err
 	}
 
-	publicKey := &privateKey.PublicKey
-	publicKeyBytes, err := json.Marshal(publicKey)
+	payload.Iss, err = EmbeddedIssuer(&privateKey.PublicKey)
 	if err != nil {
 		return "", err
 	}
 
-	pkEncoded := base64.StdEncoding.EncodeToString(publicKeyBytes)
+	return Encode(header, payload, privateKey)
+}
 
-	payload := &ClaimSet{
-		Iss: pkEncoded,
-		Aud: "",
-		Exp: 3610,
-		Iat: 10,
-	}
+// MinIssuerKeyBits is the smallest RSA modulus, in bits, that Validate
+// accepts for the key embedded in a token's iss claim. Without it a token
+// could carry a trivially factorable key and still validate.
+var MinIssuerKeyBits = 2048
+
+// ErrWeakIssuerKey is returned when the embedded iss key is below MinIssuerKeyBits.
+var ErrWeakIssuerKey = errors.New("jws: issuer key is too small")
+
+// Validate verifies token against the public key embedded in its iss claim
+// and checks its exp and iat. It is ValidateWithOptions with no audience or
+// issuer checks.
+func Validate(token string) error {
+	_, err := ValidateWithOptions(token, ValidateOptions{})
+	return err
+}
+
+// ValidateOptions adds claim checks on top of signature and time validation.
+type ValidateOptions struct {
+	// ExpectedAudience, when set, must equal the token's aud.
+	ExpectedAudience string
+	// AllowedIssuers, when non-empty, must contain the token's iss.
+	AllowedIssuers []string
+}
 
-	token, err := Encode(header, payload, privateKey)
+var (
+	// ErrAudienceMismatch is returned when aud isn't the expected audience.
+	ErrAudienceMismatch = errors.New("jws: token audience mismatch")
+	// ErrIssuerNotAllowed is returned when iss isn't an allowed issuer.
+	ErrIssuerNotAllowed = errors.New("jws: token issuer not allowed")
+)
+
+// ValidateWithOptions is like Validate but also applies opts, and returns the
+// decoded claim set on success. Every call emits a VerificationContext
+// describing the outcome.
+func ValidateWithOptions(token string, opts ValidateOptions) (*ClaimSet, error) {
+	vc := &VerificationContext{KeySource: KeySourceEmbedded}
+	claims, err := validate(token, opts, vc)
+	vc.Err = err
+	LogVerification(vc)
 	if err != nil {
-		fmt.Println(err)
-		return "", err
+		return nil, err
 	}
-
-	return token, nil
+	return claims, nil
 }
 
-func Validate(token string) error {
+func validate(token string, opts ValidateOptions, vc *VerificationContext) (*ClaimSet, error) {
+	header, err := DecodeHeader(token)
+	if err == nil {
+		vc.Kid = header.KeyID
+		vc.Alg = header.Algorithm
+	}
+
 	claims, err := Decode(token)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
 	}
+	vc.Iss = claims.Iss
+	vc.Sub = claims.Sub
+	vc.Exp = claims.Exp
 
-	pkDecoed, err := base64.StdEncoding.DecodeString(claims.Iss)
+	pk, err := issuerKey(claims.Iss)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
+	}
+	if pk.N.BitLen() < MinIssuerKeyBits {
+		return nil, fmt.Errorf("%w: %d bits, want at least %d", ErrWeakIssuerKey, pk.N.BitLen(), MinIssuerKeyBits)
 	}
 
-	pk := &rsa.PublicKey{}
-	err = json.Unmarshal(pkDecoed, &pk)
+	err = verifyRSAToken(token, pk, PaddingPKCS1v15, ParseOptions{})
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
 	}
-
-	err = Verify(token, pk)
+	err = checkTimes(claims)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, err
 	}
-	return nil
+	err = checkClaims(claims, opts)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
 
+// checkClaims applies the audience and issuer checks in opts.
+func checkClaims(c *ClaimSet, opts ValidateOptions) error {
+	if opts.ExpectedAudience != "" && c.Aud != opts.ExpectedAudience {
+		return fmt.Errorf("%w: got %q, want %q", ErrAudienceMismatch, c.Aud, opts.ExpectedAudience)
+	}
+	if len(opts.AllowedIssuers) == 0 {
+		return nil
+	}
+	for _, iss := range opts.AllowedIssuers {
+		if c.Iss == iss {
+			return nil
+		}
+	}
+	return ErrIssuerNotAllowed
+}
+
+// ClockSkew is the tolerance allowed between the issuer's clock and ours
+// when checking exp and iat.
+var ClockSkew = 30 * time.Second
+
+var (
+	// ErrTokenExpired is returned for a token at or past its exp.
+	ErrTokenExpired = errors.New("jws: token is expired")
+	// ErrTokenIssuedInFuture is returned for a token whose iat is ahead of now.
+	ErrTokenIssuedInFuture = errors.New("jws: token is not valid yet")
+	// ErrTokenNotBefore is returned for a token used before its nbf.
+	ErrTokenNotBefore = errors.New("jws: token is used before nbf")
+)
+
+// checkTimes enforces exp, iat and nbf, allowing ClockSkew either way.
+func checkTimes(c *ClaimSet) error {
+	now := timeNow()
+	if c.Exp == 0 {
+		return fmt.Errorf("%w: no exp", ErrInvalidToken)
+	}
+	if !now.Before(time.Unix(c.Exp, 0).Add(ClockSkew)) {
+		return fmt.Errorf("%w: exp %d", ErrTokenExpired, c.Exp)
+	}
+	if time.Unix(c.Iat, 0).After(now.Add(ClockSkew)) {
+		return fmt.Errorf("%w: iat %d", ErrTokenIssuedInFuture, c.Iat)
+	}
+	if time.Unix(c.Nbf, 0).After(now.Add(ClockSkew)) {
+		return fmt.Errorf("%w: nbf %d", ErrTokenNotBefore, c.Nbf)
+	}
+	return nil
 }
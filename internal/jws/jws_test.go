@@ -1,6 +1,19 @@
 package jws
 
-import "testing"
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
 
 func TestGenerate(t *testing.T) {
 	token, err := Generate()
@@ -13,3 +26,430 @@ func TestGenerate(t *testing.T) {
 		t.Errorf("expected error to be nil got %v", err)
 	}
 }
+
+func TestGenerateTimes(t *testing.T) {
+	token, err := Generate()
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	claims, err := Decode(token)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if claims.Exp <= time.Now().Unix() {
+		t.Errorf("expected exp to be in the future got %d", claims.Exp)
+	}
+	if claims.Iat > time.Now().Unix() {
+		t.Errorf("expected iat not to be in the future got %d", claims.Iat)
+	}
+}
+
+func TestEncodeRejectsRelativeExp(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err := Encode(&Header{Algorithm: "RS256", Typ: "JWT"}, &ClaimSet{Exp: 3610, Iat: 10}, key)
+	if err == nil {
+		t.Errorf("expected error not to be nil")
+	}
+}
+
+func TestDecodeDuplicateClaims(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	tests := []struct {
+		name    string
+		payload string
+		wantErr bool
+	}{
+		{"Test unique claims", `{"iss":"a","exp":1,"iat":0}`, false},
+		{"Test duplicate exp", `{"iss":"a","exp":1,"iat":0,"exp":9999999999}`, true},
+		{"Test duplicate exp with different case", `{"iss":"a","exp":1,"EXP":9999999999}`, true},
+		{"Test duplicate sub with a long s", `{"sub":"alice","ſub":"admin"}`, true},
+		{"Test duplicate kid with a Kelvin sign", `{"kid":"a","\u212Aid":"b"}`, true},
+		{"Test duplicate private claim", `{"iss":"a","role":"user","role":"admin"}`, true},
+		{"Test duplicate nested key is allowed", `{"iss":"a","ctx":{"k":1,"k":2}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := header + "." + base64.RawURLEncoding.EncodeToString([]byte(tt.payload)) + ".sig"
+			_, err := Decode(token)
+			if tt.wantErr && !errors.Is(err, ErrDuplicateClaim) {
+				t.Errorf("expected error to be ErrDuplicateClaim got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+		})
+	}
+
+	t.Run("Test validate rejects duplicate exp", func(t *testing.T) {
+		token := header + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"a","exp":1,"exp":9999999999}`)) + ".sig"
+		err := Validate(token)
+		if !errors.Is(err, ErrDuplicateClaim) {
+			t.Errorf("expected error to be ErrDuplicateClaim got %v", err)
+		}
+	})
+}
+
+func TestEncodeRejectsShadowedClaim(t *testing.T) {
+	c := &ClaimSet{
+		Iss:           "a",
+		PrivateClaims: map[string]interface{}{"exp": 9999999999},
+	}
+	_, err := c.encode()
+	if !errors.Is(err, ErrDuplicateClaim) {
+		t.Errorf("expected error to be ErrDuplicateClaim got %v", err)
+	}
+}
+
+func TestAllClaims(t *testing.T) {
+	c := &ClaimSet{
+		Iss:           "issuer",
+		Sub:           "device",
+		Exp:           3600,
+		Iat:           10,
+		PrivateClaims: map[string]interface{}{"role": "admin", "tier": 2},
+	}
+	claims := c.AllClaims()
+
+	want := map[string]interface{}{
+		"iss":  "issuer",
+		"sub":  "device",
+		"exp":  int64(3600),
+		"iat":  int64(10),
+		"role": "admin",
+		"tier": 2,
+	}
+	if len(claims) != len(want) {
+		t.Errorf("expected %d claims got %d: %v", len(want), len(claims), claims)
+	}
+	for k, v := range want {
+		if claims[k] != v {
+			t.Errorf("expected claim %s to be %v got %v", k, v, claims[k])
+		}
+	}
+	for _, k := range []string{"aud", "scope", "typ", "prn", "jti", "nbf"} {
+		if _, ok := claims[k]; ok {
+			t.Errorf("expected unset claim %s to be omitted", k)
+		}
+	}
+}
+
+func TestDecodePrivateClaims(t *testing.T) {
+	c := &ClaimSet{
+		Iss:           "issuer",
+		PrivateClaims: map[string]interface{}{"role": "admin"},
+	}
+	token, err := EncodeWithSigner(&Header{Algorithm: "RS256", Typ: "JWT"}, c, func(data []byte) ([]byte, error) {
+		return []byte("sig"), nil
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	decoded, err := Decode(token)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if decoded.Iss != "issuer" {
+		t.Errorf("expected iss to be issuer got %s", decoded.Iss)
+	}
+	if decoded.PrivateClaims["role"] != "admin" {
+		t.Errorf("expected role claim to be admin got %v", decoded.PrivateClaims["role"])
+	}
+	if _, ok := decoded.PrivateClaims["iss"]; ok {
+		t.Errorf("expected registered claims not to be private claims")
+	}
+}
+
+func TestDecodeFoldedRegisteredClaims(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"issuer","EXP":1792175245,"ſub":"device"}`))
+	decoded, err := Decode(header + "." + payload + ".c2ln")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	if decoded.Exp != 1792175245 || decoded.Sub != "device" {
+		t.Errorf("expected exp 1792175245 and sub device got %d and %s", decoded.Exp, decoded.Sub)
+	}
+	if len(decoded.PrivateClaims) != 0 {
+		t.Errorf("expected no private claims got %v", decoded.PrivateClaims)
+	}
+
+	_, err = EncodeWithSigner(&Header{Algorithm: "RS256", Typ: "JWT"}, decoded, func(data []byte) ([]byte, error) {
+		return []byte("sig"), nil
+	})
+	if err != nil {
+		t.Errorf("expected decoded claims to re-encode got %v", err)
+	}
+}
+
+func TestValidateIssuerKeySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		bits    int
+		wantErr error
+	}{
+		{"Test 1024-bit embedded key is rejected", 1024, ErrWeakIssuerKey},
+		{"Test 2048-bit embedded key is accepted", 2048, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := rsa.GenerateKey(rand.Reader, tt.bits)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			pub, _ := json.Marshal(&key.PublicKey)
+			claims := &ClaimSet{Iss: base64.StdEncoding.EncodeToString(pub)}
+			token, err := Encode(&Header{Algorithm: "RS256", Typ: "JWT"}, claims, key)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+
+			err = Validate(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateTimes(t *testing.T) {
+	old := ClockSkew
+	ClockSkew = 0
+	defer func() { ClockSkew = old }()
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name    string
+		iat     int64
+		exp     int64
+		nbf     int64
+		wantErr error
+	}{
+		{"Test currently valid token", now - 60, now + 3600, 0, nil},
+		{"Test expired token", now - 7200, now - 3600, 0, ErrTokenExpired},
+		{"Test token expiring now", now - 3600, now, 0, ErrTokenExpired},
+		{"Test not yet valid token", now + 3600, now + 7200, 0, ErrTokenIssuedInFuture},
+		{"Test token past its nbf", now - 60, now + 3600, now - 30, nil},
+		{"Test token used before its nbf", now - 60, now + 3600, now + 600, ErrTokenNotBefore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GenerateWithClaims(&ClaimSet{Iat: tt.iat, Exp: tt.exp, Nbf: tt.nbf})
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = Validate(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Test skew tolerates a slightly early iat", func(t *testing.T) {
+		ClockSkew = time.Minute
+		defer func() { ClockSkew = 0 }()
+		token, _ := GenerateWithClaims(&ClaimSet{Iat: now + 30, Exp: now + 3600})
+		err := Validate(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
+
+// setClock pins the package clock to at until the test ends.
+func setClock(t *testing.T, at time.Time) {
+	t.Helper()
+	old := timeNow
+	timeNow = func() time.Time { return at }
+	t.Cleanup(func() { timeNow = old })
+}
+
+func TestClock(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	setClock(t, at)
+	exp := at.Add(time.Hour)
+	nbf := at.Add(time.Minute)
+	token, err := GenerateWithClaims(&ClaimSet{Iat: at.Unix(), Nbf: nbf.Unix(), Exp: exp.Unix()})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		wantErr error
+	}{
+		{"Test valid the second before exp plus skew", exp.Add(ClockSkew - time.Second), nil},
+		{"Test expired at exp plus skew", exp.Add(ClockSkew), ErrTokenExpired},
+		{"Test valid at nbf minus skew", nbf.Add(-ClockSkew), nil},
+		{"Test not valid the second before nbf minus skew", nbf.Add(-ClockSkew - time.Second), ErrTokenNotBefore},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setClock(t, tt.now)
+			err := Validate(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Test defaults come from the clock", func(t *testing.T) {
+		setClock(t, at)
+		token, _ := Generate()
+		claims, _ := Decode(token)
+		if claims.Iat != at.Unix() || claims.Exp != at.Add(time.Hour).Unix() {
+			t.Errorf("expected iat %d and exp %d got %d and %d", at.Unix(), at.Add(time.Hour).Unix(), claims.Iat, claims.Exp)
+		}
+		built, _ := NewClaimSet().Issuer("server").TTL(time.Minute).Build()
+		if built.Iat != at.Unix() || built.Exp != at.Add(time.Minute).Unix() {
+			t.Errorf("expected iat %d and exp %d got %d and %d", at.Unix(), at.Add(time.Minute).Unix(), built.Iat, built.Exp)
+		}
+	})
+}
+
+func TestValidateWithOptions(t *testing.T) {
+	token, err := GenerateWithClaims(&ClaimSet{Aud: "billing", Scope: "read"})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	issued, _ := Decode(token)
+
+	tests := []struct {
+		name    string
+		opts    ValidateOptions
+		wantErr error
+	}{
+		{"Test no options", ValidateOptions{}, nil},
+		{"Test matching audience", ValidateOptions{ExpectedAudience: "billing"}, nil},
+		{"Test mismatched audience", ValidateOptions{ExpectedAudience: "payments"}, ErrAudienceMismatch},
+		{"Test allowed issuer", ValidateOptions{AllowedIssuers: []string{"other", issued.Iss}}, nil},
+		{"Test disallowed issuer", ValidateOptions{AllowedIssuers: []string{"other"}}, ErrIssuerNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ValidateWithOptions(token, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && (claims == nil || claims.Scope != "read") {
+				t.Errorf("expected decoded claims with scope read got %v", claims)
+			}
+			if tt.wantErr != nil && claims != nil {
+				t.Errorf("expected no claims on failure got %v", claims)
+			}
+		})
+	}
+}
+
+func TestScopes(t *testing.T) {
+	c := &ClaimSet{Scope: " read  write "}
+	t.Run("Test scopes are split on spaces", func(t *testing.T) {
+		scopes := c.Scopes()
+		if len(scopes) != 2 || scopes[0] != "read" || scopes[1] != "write" {
+			t.Errorf("expected [read write] got %q", scopes)
+		}
+	})
+
+	t.Run("Test has scope", func(t *testing.T) {
+		if !c.HasScope("write") {
+			t.Errorf("expected write scope")
+		}
+		if c.HasScope("admin") || c.HasScope("") {
+			t.Errorf("expected no admin or empty scope")
+		}
+	})
+}
+
+// resolverFunc adapts a function to KeyResolver.
+type resolverFunc func(kid string) (crypto.PublicKey, error)
+
+func (f resolverFunc) ResolveKey(kid string) (crypto.PublicKey, error) { return f(kid) }
+
+func TestRejectAlgNone(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	iss, _ := EmbeddedIssuer(&key.PublicKey)
+	payload, _ := (&ClaimSet{Iss: iss, Sub: "device"}).encode()
+
+	for _, alg := range []string{"none", "None", ""} {
+		header, _ := (&Header{Algorithm: alg, Typ: "JWT", KeyID: "k1"}).encode()
+		token := header + "." + payload + "."
+
+		verifiers := map[string]func() error{
+			"Verify":    func() error { return Verify(token, &key.PublicKey) },
+			"VerifyRSA": func() error { return VerifyRSA(token, &key.PublicKey, PaddingPKCS1v15) },
+			"VerifyEd25519": func() error {
+				pub, _ := testutil.DeterministicEd25519(2)
+				return VerifyEd25519(token, pub)
+			},
+			"VerifyAny": func() error {
+				return VerifyAny(token, map[string]crypto.PublicKey{"k1": &key.PublicKey})
+			},
+			"Validate": func() error { return Validate(token) },
+			"VerifyWithResolver": func() error {
+				return VerifyWithResolver(token, resolverFunc(func(string) (crypto.PublicKey, error) { return &key.PublicKey, nil }))
+			},
+		}
+		for name, verify := range verifiers {
+			t.Run("Test "+name+" rejects alg "+strconv.Quote(alg), func(t *testing.T) {
+				err := verify()
+				if !errors.Is(err, ErrUnsupportedAlgorithm) {
+					t.Errorf("expected error to be %v got %v", ErrUnsupportedAlgorithm, err)
+				}
+			})
+		}
+	}
+
+	t.Run("Test DecodeHeader", func(t *testing.T) {
+		token, _ := Generate()
+		h, err := DecodeHeader(token)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if h.Algorithm != "RS256" {
+			t.Errorf("expected alg to be RS256 got %s", h.Algorithm)
+		}
+	})
+}
+
+func TestSentinelErrors(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, _ := testutil.DeterministicEd25519(3)
+	_, otherEdKey := testutil.DeterministicEd25519(4)
+	otherRSAKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	now := time.Now().Unix()
+
+	valid, _ := GenerateWithClaims(&ClaimSet{Sub: "device"})
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(make([]byte, 256))
+	expired, _ := GenerateWithClaims(&ClaimSet{Iat: now - 7200, Exp: now - 3600})
+	edToken, _ := EncodeEd25519(&Header{Typ: "JWT"}, &ClaimSet{Sub: "device"}, otherEdKey)
+	rsaToken, _ := Encode(&Header{Typ: "JWT"}, &ClaimSet{Sub: "device"}, rsaKey)
+
+	tests := []struct {
+		name    string
+		verify  func() error
+		wantErr error
+	}{
+		{"Test Validate with too few segments", func() error { return Validate("onlyone") }, ErrInvalidToken},
+		{"Test Validate with bad base64", func() error { return Validate("!!!.@@@.###") }, ErrInvalidToken},
+		{"Test Validate with a non-JSON claim set", func() error {
+			return Validate(parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte("nope")) + ".sig")
+		}, ErrInvalidToken},
+		{"Test Validate with a tampered signature", func() error { return Validate(tampered) }, ErrBadSignature},
+		{"Test Validate with an expired token", func() error { return Validate(expired) }, ErrTokenExpired},
+		{"Test Verify with two segments", func() error { return Verify(parts[0]+"."+parts[1], &rsaKey.PublicKey) }, ErrInvalidToken},
+		{"Test Verify with the wrong key", func() error { return Verify(rsaToken, &otherRSAKey.PublicKey) }, ErrBadSignature},
+		{"Test VerifyEd25519 with the wrong key", func() error { return VerifyEd25519(edToken, edPub) }, ErrBadSignature},
+		{"Test VerifyEd25519 with an RSA token", func() error { return VerifyEd25519(rsaToken, edPub) }, ErrUnsupportedAlgorithm},
+		{"Test VerifyRSA with an EdDSA token", func() error { return VerifyRSA(edToken, &rsaKey.PublicKey, PaddingPKCS1v15) }, ErrUnsupportedAlgorithm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verify()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error to be %v got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
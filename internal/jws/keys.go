@@ -0,0 +1,216 @@
+package jws
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// LoadSigningKey reads a PEM-encoded private key from path, either an Ed25519
+// key in PKCS#8 or an RSA key in PKCS#1 or PKCS#8, and returns it along with
+// a Header carrying the matching alg and a kid derived from the public key.
+func LoadSigningKey(path string) (crypto.Signer, Header, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Header{}, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, Header{}, fmt.Errorf("jws: no PEM block found in %s", path)
+	}
+
+	var key crypto.Signer
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		var parsed any
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			var ok bool
+			key, ok = parsed.(crypto.Signer)
+			if !ok {
+				err = fmt.Errorf("jws: unsupported private key type %T", parsed)
+			}
+		}
+	default:
+		err = fmt.Errorf("jws: unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, Header{}, err
+	}
+
+	header, err := HeaderForKey(key.Public())
+	if err != nil {
+		return nil, Header{}, err
+	}
+	return key, header, nil
+}
+
+// HeaderForKey returns the Header for tokens signed by the private key of
+// pub: the alg for its type and its Thumbprint as kid.
+func HeaderForKey(pub crypto.PublicKey) (Header, error) {
+	kid, err := Thumbprint(pub)
+	if err != nil {
+		return Header{}, err
+	}
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return Header{Algorithm: DefaultRSAPadding.Algorithm(), Typ: "JWT", KeyID: kid}, nil
+	case ed25519.PublicKey:
+		return Header{Algorithm: "EdDSA", Typ: "JWT", KeyID: kid}, nil
+	default:
+		return Header{}, fmt.Errorf("jws: unsupported public key type %T", pub)
+	}
+}
+
+// Thumbprint returns the RFC 7638 JWK thumbprint of pub, base64url-encoded.
+// It is stable for a given key, so it serves as the key's kid.
+func Thumbprint(pub crypto.PublicKey) (string, error) {
+	var members any
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		// Members in lexicographic order, as RFC 7638 requires.
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+		}
+	case ed25519.PublicKey:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{
+			Crv: "Ed25519",
+			Kty: "OKP",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}
+	default:
+		return "", fmt.Errorf("jws: unsupported public key type %T", pub)
+	}
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// EncodeWithKey encodes a JWS signed by key, which must be an RSA or Ed25519
+// private key, or a crypto.Signer with an RSA or Ed25519 public key such as
+// an HSM key. RSA keys sign with DefaultRSAPadding.
+func EncodeWithKey(header *Header, c *ClaimSet, key crypto.Signer) (string, error) {
+	return EncodeWithKeyContext(context.Background(), header, c, key)
+}
+
+// EncodeWithKeyContext is EncodeWithKey giving up once ctx is done, when key
+// is a ContextKey. In-memory keys ignore ctx.
+func EncodeWithKeyContext(ctx context.Context, header *Header, c *ClaimSet, key crypto.Signer) (string, error) {
+	ks, err := keySigner(key)
+	if err != nil {
+		return "", err
+	}
+	return EncodeWithKeySignerContext(ctx, header, c, ks)
+}
+
+// keySigner returns the KeySigner for key.
+func keySigner(key crypto.Signer) (KeySigner, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return RSASigner{Key: k, Padding: DefaultRSAPadding}, nil
+	case ed25519.PrivateKey:
+		return Ed25519Signer{Key: k}, nil
+	}
+	switch key.Public().(type) {
+	case *rsa.PublicKey, ed25519.PublicKey:
+		return opaqueSigner{key: key}, nil
+	default:
+		return nil, fmt.Errorf("jws: unsupported private key type %T", key)
+	}
+}
+
+// SignDetached signs data with key, which must be an RSA or Ed25519 private
+// key, the way EncodeWithKey signs a token's signing input. VerifyDetached
+// checks the result.
+func SignDetached(key crypto.Signer, data []byte) ([]byte, error) {
+	ks, err := keySigner(key)
+	if err != nil {
+		return nil, err
+	}
+	return ks.Sign(data)
+}
+
+// EmbeddedIssuer returns the iss value Validate expects for tokens signed by
+// the private key of pub: the key's SPKI DER, base64-encoded. That is about
+// half the size of the JSON encoding earlier tokens carry, which Validate
+// still accepts.
+func EmbeddedIssuer(pub *rsa.PublicKey) (string, error) {
+	b, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// issuerKey parses the RSA public key embedded in iss by EmbeddedIssuer, or
+// by earlier versions as JSON.
+func issuerKey(iss string) (*rsa.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(iss)
+	if err != nil {
+		return nil, fmt.Errorf("%w: iss: %v", ErrInvalidToken, err)
+	}
+	if len(b) > 0 && b[0] == '{' {
+		pk := &rsa.PublicKey{}
+		err = json.Unmarshal(b, pk)
+		if err != nil {
+			return nil, fmt.Errorf("%w: iss: %v", ErrInvalidToken, err)
+		}
+		if pk.N == nil {
+			return nil, fmt.Errorf("%w: issuer is not an RSA public key", ErrInvalidToken)
+		}
+		return pk, nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: iss: %v", ErrInvalidToken, err)
+	}
+	pk, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: issuer is not an RSA public key", ErrInvalidToken)
+	}
+	return pk, nil
+}
+
+// EmbeddedEd25519Issuer returns an iss naming pub itself: its 32 bytes,
+// base64url-encoded. PublicKeyFromClaims reverses it. Where verifiers can
+// fetch the JWKS, a kid is smaller still.
+func EmbeddedEd25519Issuer(pub ed25519.PublicKey) string {
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// PublicKeyFromClaims returns the Ed25519 public key embedded in c.Iss by
+// EmbeddedEd25519Issuer.
+func PublicKeyFromClaims(c *ClaimSet) (ed25519.PublicKey, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c.Iss)
+	if err != nil {
+		return nil, fmt.Errorf("%w: iss: %v", ErrInvalidToken, err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: iss is %d bytes, not an Ed25519 public key", ErrInvalidToken, len(b))
+	}
+	return ed25519.PublicKey(b), nil
+}
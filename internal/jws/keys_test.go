@@ -0,0 +1,276 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestLoadSigningKey(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		alg  string
+	}{
+		{"Test Ed25519 PKCS#8 key", "testdata/ed25519.pem", "EdDSA"},
+		{"Test RSA PKCS#1 key", "testdata/rsa_pkcs1.pem", "RS256"},
+		{"Test RSA PKCS#8 key", "testdata/rsa_pkcs8.pem", "RS256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, header, err := LoadSigningKey(tt.path)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if header.Algorithm != tt.alg {
+				t.Errorf("expected alg to be %s got %s", tt.alg, header.Algorithm)
+			}
+			kid, _ := Thumbprint(key.Public())
+			if header.KeyID == "" || header.KeyID != kid {
+				t.Errorf("expected kid to be %s got %s", kid, header.KeyID)
+			}
+
+			token, err := EncodeWithKey(&header, &ClaimSet{Iss: "server"}, key)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = VerifyWithKey(token, key.Public())
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+			h, _ := DecodeHeader(token)
+			if h.KeyID != header.KeyID {
+				t.Errorf("expected token kid to be %s got %s", header.KeyID, h.KeyID)
+			}
+		})
+	}
+
+	t.Run("Test key types", func(t *testing.T) {
+		ed, _, _ := LoadSigningKey("testdata/ed25519.pem")
+		if _, ok := ed.(ed25519.PrivateKey); !ok {
+			t.Errorf("expected an Ed25519 key got %T", ed)
+		}
+		pkcs1, h1, _ := LoadSigningKey("testdata/rsa_pkcs1.pem")
+		if _, ok := pkcs1.(*rsa.PrivateKey); !ok {
+			t.Errorf("expected an RSA key got %T", pkcs1)
+		}
+		_, h8, _ := LoadSigningKey("testdata/rsa_pkcs8.pem")
+		if h1.KeyID != h8.KeyID {
+			t.Errorf("expected the same key in both encodings to share a kid got %s and %s", h1.KeyID, h8.KeyID)
+		}
+	})
+
+	t.Run("Test missing file", func(t *testing.T) {
+		_, _, err := LoadSigningKey("testdata/missing.pem")
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+func TestVerifyAny(t *testing.T) {
+	rsaKey, rsaHeader, err := LoadSigningKey("testdata/rsa_pkcs8.pem")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	edKey, edHeader, err := LoadSigningKey("testdata/ed25519.pem")
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	keys := map[string]crypto.PublicKey{
+		rsaHeader.KeyID: rsaKey.Public(),
+		edHeader.KeyID:  edKey.Public(),
+	}
+
+	tests := []struct {
+		name   string
+		key    crypto.Signer
+		header Header
+	}{
+		{"Test RS256 token", rsaKey, rsaHeader},
+		{"Test EdDSA token", edKey, edHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := EncodeWithKey(&tt.header, &ClaimSet{Sub: "device"}, tt.key)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = VerifyAny(token, keys)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+		})
+	}
+
+	t.Run("Test PS256 token", func(t *testing.T) {
+		token, _ := EncodeRSA(&rsaHeader, &ClaimSet{Sub: "device"}, rsaKey.(*rsa.PrivateKey), PaddingPSS)
+		err := VerifyAny(token, keys)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test unknown alg", func(t *testing.T) {
+		header := edHeader
+		header.Algorithm = "HS512"
+		token, _ := EncodeWithSigner(&header, &ClaimSet{Sub: "device"}, func(data []byte) ([]byte, error) {
+			return []byte("sig"), nil
+		})
+		err := VerifyAny(token, keys)
+		if !errors.Is(err, ErrUnsupportedAlgorithm) {
+			t.Errorf("expected error to be %v got %v", ErrUnsupportedAlgorithm, err)
+		}
+	})
+
+	t.Run("Test unknown kid", func(t *testing.T) {
+		_, other := testutil.DeterministicEd25519(5)
+		header, _ := HeaderForKey(other.Public())
+		token, _ := EncodeWithKey(&header, &ClaimSet{Sub: "device"}, other)
+		err := VerifyAny(token, keys)
+		if !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("expected error to be %v got %v", ErrUnknownKeyID, err)
+		}
+	})
+
+	t.Run("Test wrong kid", func(t *testing.T) {
+		header := edHeader
+		header.KeyID = rsaHeader.KeyID
+		token, _ := EncodeWithKey(&header, &ClaimSet{Sub: "device"}, edKey)
+		err := VerifyAny(token, keys)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test missing kid falls back to every key", func(t *testing.T) {
+		for _, tt := range tests {
+			header := tt.header
+			header.KeyID = ""
+			token, _ := EncodeWithKey(&header, &ClaimSet{Sub: "device"}, tt.key)
+			err := VerifyAny(token, keys)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+		}
+
+		_, other := testutil.DeterministicEd25519(6)
+		token, _ := EncodeWithKey(&Header{Algorithm: "EdDSA", Typ: "JWT"}, &ClaimSet{Sub: "device"}, other)
+		err := VerifyAny(token, keys)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test alg and key type mismatch", func(t *testing.T) {
+		header := edHeader
+		token, _ := EncodeRSA(&header, &ClaimSet{Sub: "device"}, rsaKey.(*rsa.PrivateKey), PaddingPKCS1v15)
+		err := VerifyAny(token, keys)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+}
+
+func TestEmbeddedIssuers(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey := testutil.DeterministicEd25519(7)
+
+	t.Run("Test Ed25519 issuer round trip", func(t *testing.T) {
+		token, err := EncodeEd25519(&Header{Typ: "JWT"}, &ClaimSet{Iss: EmbeddedEd25519Issuer(edPub)}, edKey)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		claims, _ := Decode(token)
+		pub, err := PublicKeyFromClaims(claims)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !pub.Equal(edPub) {
+			t.Errorf("expected the embedded key to round trip")
+		}
+		err = VerifyEd25519(token, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test Ed25519 issuer of the wrong size", func(t *testing.T) {
+		_, err := PublicKeyFromClaims(&ClaimSet{Iss: EmbeddedEd25519Issuer(edPub[:16])})
+		if !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("expected error to be %v got %v", ErrInvalidToken, err)
+		}
+	})
+
+	t.Run("Test RSA SPKI issuer round trip", func(t *testing.T) {
+		iss, err := EmbeddedIssuer(&rsaKey.PublicKey)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		pub, err := issuerKey(iss)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if !pub.Equal(&rsaKey.PublicKey) {
+			t.Errorf("expected the embedded key to round trip")
+		}
+	})
+
+	t.Run("Test legacy JSON issuer still parses", func(t *testing.T) {
+		b, _ := json.Marshal(&rsaKey.PublicKey)
+		pub, err := issuerKey(base64.StdEncoding.EncodeToString(b))
+		if err != nil || !pub.Equal(&rsaKey.PublicKey) {
+			t.Errorf("expected the legacy key to parse got %v", err)
+		}
+	})
+
+	t.Run("Test sizes", func(t *testing.T) {
+		legacy, _ := json.Marshal(&rsaKey.PublicKey)
+		legacyIss := base64.StdEncoding.EncodeToString(legacy)
+		spkiIss, _ := EmbeddedIssuer(&rsaKey.PublicKey)
+		edIss := EmbeddedEd25519Issuer(edPub)
+		if len(spkiIss) >= len(legacyIss) {
+			t.Errorf("expected SPKI iss (%d) to be smaller than JSON iss (%d)", len(spkiIss), len(legacyIss))
+		}
+		if len(edIss)*10 > len(legacyIss) {
+			t.Errorf("expected Ed25519 iss (%d) to be under a tenth of JSON iss (%d)", len(edIss), len(legacyIss))
+		}
+	})
+}
+
+func TestSignDetached(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey := testutil.DeterministicEd25519(8)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+	}{
+		{"Test RSA key", rsaKey},
+		{"Test Ed25519 key", edKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			sig, err := SignDetached(key, []byte("data"))
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = VerifyDetached(key.Public(), []byte("data"), sig)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+			err = VerifyDetached(key.Public(), []byte("tampered"), sig)
+			if !errors.Is(err, ErrBadSignature) {
+				t.Errorf("expected error to be %v got %v", ErrBadSignature, err)
+			}
+		})
+	}
+}
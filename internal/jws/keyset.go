@@ -0,0 +1,114 @@
+package jws
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrActiveKey is returned when retiring the key a KeySet signs with.
+var ErrActiveKey = errors.New("jws: cannot retire the active key")
+
+// KeySet is a signing key plus the keys still trusted for verification. On
+// rotation the new key becomes active and the old one stays trusted, so
+// tokens it signed keep verifying until it is retired.
+type KeySet struct {
+	mu      sync.RWMutex
+	active  crypto.Signer
+	header  Header
+	trusted []trustedKey
+}
+
+// trustedKey is a verification key and the header of tokens it verifies.
+type trustedKey struct {
+	header Header
+	pub    crypto.PublicKey
+}
+
+// NewKeySet returns a KeySet that signs with key, using header for the
+// tokens it signs. A header without a kid gets the key's Thumbprint, so every
+// token names its key. A nil key yields an empty set.
+func NewKeySet(key crypto.Signer, header Header) *KeySet {
+	ks := &KeySet{}
+	if key != nil {
+		ks.promote(key, header)
+	}
+	return ks
+}
+
+// Active returns the signing key and the header tokens are signed with.
+func (ks *KeySet) Active() (crypto.Signer, Header) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.active, ks.header
+}
+
+// Promote makes key the active signing key. The previous key stays trusted.
+func (ks *KeySet) Promote(key crypto.Signer, header Header) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.promote(key, header)
+}
+
+func (ks *KeySet) promote(key crypto.Signer, header Header) {
+	if header.KeyID == "" {
+		header.KeyID, _ = Thumbprint(key.Public())
+	}
+	ks.active = key
+	ks.header = header
+	for _, k := range ks.trusted {
+		if k.header.KeyID == header.KeyID {
+			return
+		}
+	}
+	ks.trusted = append(ks.trusted, trustedKey{header: header, pub: key.Public()})
+}
+
+// Retire stops trusting the key with the given kid. The active key can't be
+// retired.
+func (ks *KeySet) Retire(kid string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.active != nil && ks.header.KeyID == kid {
+		return ErrActiveKey
+	}
+	for i, k := range ks.trusted {
+		if k.header.KeyID == kid {
+			ks.trusted = append(ks.trusted[:i:i], ks.trusted[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+}
+
+// Keys returns the trusted verification keys by kid, for VerifyAny.
+func (ks *KeySet) Keys() map[string]crypto.PublicKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make(map[string]crypto.PublicKey, len(ks.trusted))
+	for _, k := range ks.trusted {
+		keys[k.header.KeyID] = k.pub
+	}
+	return keys
+}
+
+// JWKS returns the trusted verification keys as a JWKS document.
+func (ks *KeySet) JWKS() (JWKS, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	set := JWKS{Keys: make([]JWK, 0, len(ks.trusted))}
+	for _, k := range ks.trusted {
+		key, err := NewJWK(k.pub, k.header.KeyID, k.header.Algorithm)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	return set, nil
+}
+
+// Verify verifies token against the trusted key named by its kid.
+func (ks *KeySet) Verify(token string) error {
+	return VerifyAny(token, ks.Keys())
+}
@@ -0,0 +1,97 @@
+package jws
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func TestKeySet(t *testing.T) {
+	newKey := func(t *testing.T, seed byte) (ed25519.PrivateKey, Header) {
+		t.Helper()
+		_, key := testutil.DeterministicEd25519(seed)
+		header, err := HeaderForKey(key.Public())
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		return key, header
+	}
+	sign := func(t *testing.T, ks *KeySet) string {
+		t.Helper()
+		key, header := ks.Active()
+		token, err := EncodeWithKey(&header, &ClaimSet{Sub: "device"}, key)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		return token
+	}
+
+	t.Run("Test token signed before rotation still verifies", func(t *testing.T) {
+		oldKey, oldHeader := newKey(t, 1)
+		ks := NewKeySet(oldKey, oldHeader)
+		token := sign(t, ks)
+
+		newKey, newHeader := newKey(t, 2)
+		ks.Promote(newKey, newHeader)
+		err := ks.Verify(token)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		if _, header := ks.Active(); header.KeyID != newHeader.KeyID {
+			t.Errorf("expected active kid to be %s got %s", newHeader.KeyID, header.KeyID)
+		}
+		if err := ks.Verify(sign(t, ks)); err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+
+		set, err := ks.JWKS()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(set.Keys) != 2 {
+			t.Errorf("expected 2 keys in the JWKS got %d", len(set.Keys))
+		}
+	})
+
+	t.Run("Test token signed by a retired key is rejected", func(t *testing.T) {
+		oldKey, oldHeader := newKey(t, 3)
+		ks := NewKeySet(oldKey, oldHeader)
+		token := sign(t, ks)
+
+		newKey, newHeader := newKey(t, 4)
+		ks.Promote(newKey, newHeader)
+		err := ks.Retire(oldHeader.KeyID)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = ks.Verify(token)
+		if !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("expected error to be %v got %v", ErrUnknownKeyID, err)
+		}
+	})
+
+	t.Run("Test active key cannot be retired", func(t *testing.T) {
+		key, header := newKey(t, 5)
+		ks := NewKeySet(key, header)
+		err := ks.Retire(header.KeyID)
+		if !errors.Is(err, ErrActiveKey) {
+			t.Errorf("expected error to be %v got %v", ErrActiveKey, err)
+		}
+	})
+	t.Run("Test header without a kid gets the key's thumbprint", func(t *testing.T) {
+		key, header := newKey(t, 6)
+		kid := header.KeyID
+		header.KeyID = ""
+		ks := NewKeySet(key, header)
+		token := sign(t, ks)
+		h, _ := DecodeHeader(token)
+		if h.KeyID != kid {
+			t.Errorf("expected kid to be %s got %s", kid, h.KeyID)
+		}
+		if err := ks.Verify(token); err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
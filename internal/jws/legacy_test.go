@@ -0,0 +1,74 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// legacyToken signs a token whose header is base64url-encoded but whose
+// payload is standard base64 with padding, like the legacy system produces.
+func legacyToken(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"iss":"legacy>>system??","aud":"","exp":4102444800,"iat":0}`))
+	if !strings.ContainsAny(payload, "+/=") {
+		t.Fatalf("expected payload %s to use standard base64 characters", payload)
+	}
+	ss := header + "." + payload
+	h := sha256.Sum256([]byte(ss))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return ss + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestLegacyEncoding(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	token := legacyToken(t, key)
+	legacy := ParseOptions{LegacyEncoding: true}
+
+	t.Run("Test legacy token verifies with option", func(t *testing.T) {
+		err := VerifyWithOptions(token, &key.PublicKey, legacy)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		claims, err := DecodeWithOptions(token, legacy)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		if claims.Iss != "legacy>>system??" {
+			t.Errorf("expected iss to be legacy>>system?? got %s", claims.Iss)
+		}
+	})
+
+	t.Run("Test legacy token is rejected without option", func(t *testing.T) {
+		err := Verify(token, &key.PublicKey)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+		_, err = Decode(token)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test standard token still verifies with option", func(t *testing.T) {
+		std, err := Encode(&Header{Algorithm: "RS256", Typ: "JWT"}, &ClaimSet{Iss: "a"}, key)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = VerifyWithOptions(std, &key.PublicKey, legacy)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
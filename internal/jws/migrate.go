@@ -0,0 +1,34 @@
+package jws
+
+import "errors"
+
+// ErrNoLegacyIssuers is returned by MigrateToken when it isn't told which
+// legacy issuers to trust.
+var ErrNoLegacyIssuers = errors.New("jws: migrating tokens needs the allowed legacy issuers")
+
+// MigrateToken re-issues a legacy token, whose verification key is embedded in
+// its iss claim, as a token signed by newSigner under newHeader. newHeader must
+// carry the kid verifiers use to resolve the new key. The old token is
+// validated first and its iss must be one of legacyIssuers: a legacy token
+// vouches for itself, so without that anyone's self-made token would be
+// re-signed with the trusted key. The claims, including exp, are carried
+// over, except iss, which becomes the new kid.
+func MigrateToken(old string, legacyIssuers []string, newSigner Signer, newHeader *Header) (string, error) {
+	if len(legacyIssuers) == 0 {
+		return "", ErrNoLegacyIssuers
+	}
+	if newHeader == nil || newHeader.KeyID == "" {
+		return "", errors.New("jws: migrated tokens need a header with a kid")
+	}
+	err := checkAlgorithm(newHeader)
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := ValidateWithOptions(old, ValidateOptions{AllowedIssuers: legacyIssuers})
+	if err != nil {
+		return "", err
+	}
+	claims.Iss = newHeader.KeyID
+	return EncodeWithSigner(newHeader, claims, newSigner)
+}
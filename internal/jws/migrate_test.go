@@ -0,0 +1,91 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrateToken(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	ts, err := NewTrustStore(fstest.MapFS{
+		"server-1.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})},
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	signer := func(data []byte) ([]byte, error) {
+		h := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	}
+	header := &Header{Algorithm: "RS256", Typ: "JWT", KeyID: "server-1"}
+	legacy, err := Generate()
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	legacyClaims, _ := Decode(legacy)
+	legacyIssuers := []string{legacyClaims.Iss}
+
+	t.Run("Test legacy token is migrated", func(t *testing.T) {
+		old := legacy
+		migrated, err := MigrateToken(old, legacyIssuers, signer, header)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+
+		err = VerifyWithResolver(migrated, ts)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		oldClaims, _ := Decode(old)
+		newClaims, _ := Decode(migrated)
+		if oldClaims.Exp != newClaims.Exp || oldClaims.Iat != newClaims.Iat {
+			t.Errorf("expected claims to be carried over got %v want %v", newClaims, oldClaims)
+		}
+		if newClaims.Iss != "server-1" {
+			t.Errorf("expected iss to be server-1 got %s", newClaims.Iss)
+		}
+		newHeader, _ := DecodeHeader(migrated)
+		if newHeader.KeyID != "server-1" {
+			t.Errorf("expected kid to be server-1 got %s", newHeader.KeyID)
+		}
+	})
+
+	t.Run("Test tampered legacy token is not migrated", func(t *testing.T) {
+		parts := strings.Split(legacy, ".")
+		_, err := MigrateToken(parts[0]+"."+parts[1]+".AAAA", legacyIssuers, signer, header)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test header without kid is rejected", func(t *testing.T) {
+		_, err := MigrateToken(legacy, legacyIssuers, signer, &Header{Algorithm: "RS256", Typ: "JWT"})
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test legacy token from a foreign key is refused", func(t *testing.T) {
+		forged, _ := Generate()
+		_, err := MigrateToken(forged, legacyIssuers, signer, header)
+		if !errors.Is(err, ErrIssuerNotAllowed) {
+			t.Errorf("expected error to be %v got %v", ErrIssuerNotAllowed, err)
+		}
+	})
+
+	t.Run("Test migration without legacy issuers is refused", func(t *testing.T) {
+		_, err := MigrateToken(legacy, nil, signer, header)
+		if !errors.Is(err, ErrNoLegacyIssuers) {
+			t.Errorf("expected error to be %v got %v", ErrNoLegacyIssuers, err)
+		}
+	})
+}
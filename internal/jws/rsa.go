@@ -0,0 +1,76 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+)
+
+// RSAPadding selects the RSA signature scheme.
+type RSAPadding int
+
+const (
+	// PaddingPKCS1v15 signs with RSASSA-PKCS1-v1_5 and SHA-256 (RS256).
+	PaddingPKCS1v15 RSAPadding = iota
+	// PaddingPSS signs with RSASSA-PSS and SHA-256 (PS256).
+	PaddingPSS
+	// PaddingPSS384 signs with RSASSA-PSS and SHA-384 (PS384).
+	PaddingPSS384
+)
+
+// DefaultRSAPadding is the padding Encode signs with.
+//
+// It stays PKCS#1 v1.5: BenchmarkEncodeRSA and BenchmarkVerifyRSA show PSS
+// within a few percent of it for both signing and verification at 2048,
+// 3072 and 4096 bits (the modular exponentiation dominates; PSS adds about
+// five allocations), so performance doesn't argue for switching, and every
+// existing verifier of these tokens expects RS256. PSS remains available
+// through EncodeRSA and VerifyRSA for verifiers that require it.
+const DefaultRSAPadding = PaddingPKCS1v15
+
+// Algorithm returns the JWS alg name for the padding.
+func (p RSAPadding) Algorithm() string {
+	switch p {
+	case PaddingPSS:
+		return "PS256"
+	case PaddingPSS384:
+		return "PS384"
+	}
+	return "RS256"
+}
+
+// rsaPaddingFor returns the padding whose Algorithm is alg.
+func rsaPaddingFor(alg string) (RSAPadding, bool) {
+	for _, p := range []RSAPadding{PaddingPKCS1v15, PaddingPSS, PaddingPSS384} {
+		if p.Algorithm() == alg {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// hash returns the hash the padding signs and its digest of data.
+func (p RSAPadding) hash(data []byte) (crypto.Hash, []byte) {
+	if p == PaddingPSS384 {
+		digest := sha512.Sum384(data)
+		return crypto.SHA384, digest[:]
+	}
+	digest := sha256.Sum256(data)
+	return crypto.SHA256, digest[:]
+}
+
+// pssOptions returns nil for PKCS#1 v1.5. For PSS it salts signatures with
+// as many bytes as the digest, as JWA requires.
+func (p RSAPadding) pssOptions(hash crypto.Hash) *rsa.PSSOptions {
+	if p != PaddingPSS && p != PaddingPSS384 {
+		return nil
+	}
+	return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+}
+
+// EncodeRSA encodes a signed JWS with the given RSA private key and padding.
+// The header's alg is set to match the padding.
+func EncodeRSA(header *Header, c *ClaimSet, key *rsa.PrivateKey, padding RSAPadding) (string, error) {
+	return EncodeWithKeySigner(header, c, RSASigner{Key: key, Padding: padding})
+}
@@ -0,0 +1,126 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"testing"
+)
+
+func TestEncodeRSA(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	header := &Header{Typ: "JWT"}
+
+	for _, padding := range []RSAPadding{PaddingPKCS1v15, PaddingPSS, PaddingPSS384} {
+		t.Run("Test round trip "+padding.Algorithm(), func(t *testing.T) {
+			token, err := EncodeRSA(header, &ClaimSet{Iss: "a"}, key, padding)
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			err = VerifyRSA(token, &key.PublicKey, padding)
+			if err != nil {
+				t.Errorf("expected error to be nil got %v", err)
+			}
+			h, _ := DecodeHeader(token)
+			if h.Algorithm != padding.Algorithm() {
+				t.Errorf("expected alg to be %s got %s", padding.Algorithm(), h.Algorithm)
+			}
+		})
+	}
+
+	t.Run("Test PSS token is rejected as PKCS1v15", func(t *testing.T) {
+		token, _ := EncodeRSA(header, &ClaimSet{Iss: "a"}, key, PaddingPSS)
+		err := VerifyRSA(token, &key.PublicKey, PaddingPKCS1v15)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+		err = Verify(token, &key.PublicKey)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test PKCS1v15 token is rejected as PSS", func(t *testing.T) {
+		token, _ := EncodeRSA(header, &ClaimSet{Iss: "a"}, key, PaddingPKCS1v15)
+		err := VerifyRSA(token, &key.PublicKey, PaddingPSS)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test signatures don't verify under another padding", func(t *testing.T) {
+		paddings := []RSAPadding{PaddingPKCS1v15, PaddingPSS, PaddingPSS384}
+		for _, signed := range paddings {
+			sig, _ := RSASigner{Key: key, Padding: signed}.Sign([]byte("a.b"))
+			for _, verified := range paddings {
+				err := verifyRSA([]byte("a.b"), sig, &key.PublicKey, verified)
+				if (err == nil) != (signed == verified) {
+					t.Errorf("expected %s signature checked as %s to verify %v got %v", signed.Algorithm(), verified.Algorithm(), signed == verified, err)
+				}
+			}
+		}
+	})
+
+	t.Run("Test VerifyAny dispatches on alg", func(t *testing.T) {
+		for _, padding := range []RSAPadding{PaddingPKCS1v15, PaddingPSS, PaddingPSS384} {
+			token, _ := EncodeRSA(&Header{Typ: "JWT", KeyID: "k"}, &ClaimSet{Iss: "a"}, key, padding)
+			err := VerifyAny(token, map[string]crypto.PublicKey{"k": &key.PublicKey})
+			if err != nil {
+				t.Errorf("expected %s token to verify got %v", padding.Algorithm(), err)
+			}
+		}
+	})
+
+	t.Run("Test Encode honors the default padding", func(t *testing.T) {
+		token, err := Encode(header, &ClaimSet{Iss: "a"}, key)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		h, _ := DecodeHeader(token)
+		if h.Algorithm != DefaultRSAPadding.Algorithm() {
+			t.Errorf("expected alg to be %s got %s", DefaultRSAPadding.Algorithm(), h.Algorithm)
+		}
+		err = VerifyRSA(token, &key.PublicKey, DefaultRSAPadding)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
+
+var benchKeySizes = []int{2048, 3072, 4096}
+
+func BenchmarkEncodeRSA(b *testing.B) {
+	for _, bits := range benchKeySizes {
+		key, _ := rsa.GenerateKey(rand.Reader, bits)
+		for _, padding := range []RSAPadding{PaddingPKCS1v15, PaddingPSS} {
+			b.Run(fmt.Sprintf("%s/%d", padding.Algorithm(), bits), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					_, err := EncodeRSA(&Header{Typ: "JWT"}, &ClaimSet{Iss: "a"}, key, padding)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkVerifyRSA(b *testing.B) {
+	for _, bits := range benchKeySizes {
+		key, _ := rsa.GenerateKey(rand.Reader, bits)
+		for _, padding := range []RSAPadding{PaddingPKCS1v15, PaddingPSS} {
+			token, _ := EncodeRSA(&Header{Typ: "JWT"}, &ClaimSet{Iss: "a"}, key, padding)
+			b.Run(fmt.Sprintf("%s/%d", padding.Algorithm(), bits), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					err := VerifyRSA(token, &key.PublicKey, padding)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
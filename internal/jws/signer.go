@@ -0,0 +1,177 @@
+package jws
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+)
+
+// KeySigner signs tokens with a key it may never expose, such as one held in
+// a cloud KMS or a hardware token.
+type KeySigner interface {
+	// Sign returns the signature of data, the JWS signing input.
+	Sign(data []byte) ([]byte, error)
+	// SignContext is Sign giving up once ctx is done, for signers that may
+	// block on a remote call. In-memory signers ignore ctx.
+	SignContext(ctx context.Context, data []byte) ([]byte, error)
+	// Header returns the header of the tokens the signer produces. Its alg is
+	// always used; its kid only when the caller's header has none.
+	Header() Header
+}
+
+// EncodeWithKeySigner encodes a JWS signed by ks.
+func EncodeWithKeySigner(header *Header, c *ClaimSet, ks KeySigner) (string, error) {
+	return EncodeWithSigner(signerHeader(header, ks), c, ks.Sign)
+}
+
+// EncodeWithKeySignerContext is EncodeWithKeySigner giving up once ctx is
+// done.
+func EncodeWithKeySignerContext(ctx context.Context, header *Header, c *ClaimSet, ks KeySigner) (string, error) {
+	return EncodeWithSigner(signerHeader(header, ks), c, func(data []byte) ([]byte, error) {
+		return ks.SignContext(ctx, data)
+	})
+}
+
+// signerHeader returns header with the alg of ks and, when header has none,
+// its kid.
+func signerHeader(header *Header, ks KeySigner) *Header {
+	h := *header
+	sh := ks.Header()
+	h.Algorithm = sh.Algorithm
+	if h.KeyID == "" {
+		h.KeyID = sh.KeyID
+	}
+	return &h
+}
+
+// RSASigner is a KeySigner for an in-memory RSA private key.
+type RSASigner struct {
+	Key     *rsa.PrivateKey
+	Padding RSAPadding
+	KeyID   string
+}
+
+// Sign signs the digest of data with the configured padding and its hash.
+func (s RSASigner) Sign(data []byte) ([]byte, error) {
+	hash, digest := s.Padding.hash(data)
+	if opts := s.Padding.pssOptions(hash); opts != nil {
+		return rsa.SignPSS(rand.Reader, s.Key, hash, digest, opts)
+	}
+	return rsa.SignPKCS1v15(rand.Reader, s.Key, hash, digest)
+}
+
+// SignContext is Sign; signing in memory doesn't block.
+func (s RSASigner) SignContext(_ context.Context, data []byte) ([]byte, error) {
+	return s.Sign(data)
+}
+
+// Header returns an RS256, PS256 or PS384 header, depending on the padding.
+func (s RSASigner) Header() Header {
+	return Header{Algorithm: s.Padding.Algorithm(), Typ: "JWT", KeyID: s.KeyID}
+}
+
+// Ed25519Signer is a KeySigner for an in-memory Ed25519 private key.
+type Ed25519Signer struct {
+	Key   ed25519.PrivateKey
+	KeyID string
+}
+
+// Sign signs data directly, without pre-hashing, as RFC 8037 requires.
+func (s Ed25519Signer) Sign(data []byte) ([]byte, error) {
+	if len(s.Key) != ed25519.PrivateKeySize {
+		return nil, errors.New("jws: invalid Ed25519 private key")
+	}
+	return ed25519.Sign(s.Key, data), nil
+}
+
+// SignContext is Sign; signing in memory doesn't block.
+func (s Ed25519Signer) SignContext(_ context.Context, data []byte) ([]byte, error) {
+	return s.Sign(data)
+}
+
+// Header returns an EdDSA header.
+func (s Ed25519Signer) Header() Header {
+	return Header{Algorithm: "EdDSA", Typ: "JWT", KeyID: s.KeyID}
+}
+
+// HS256Signer is a KeySigner for a shared HMAC-SHA256 secret. Anyone holding
+// the secret can both sign and verify, so it only suits parties that trust
+// each other.
+type HS256Signer struct {
+	Secret []byte
+	KeyID  string
+}
+
+// hs256 returns the HMAC-SHA256 of data under secret.
+func hs256(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// Sign returns the HMAC-SHA256 of data under the secret.
+func (s HS256Signer) Sign(data []byte) ([]byte, error) {
+	if len(s.Secret) < MinHS256SecretSize {
+		return nil, ErrShortHS256Secret
+	}
+	return hs256(s.Secret, data), nil
+}
+
+// SignContext is Sign; signing in memory doesn't block.
+func (s HS256Signer) SignContext(_ context.Context, data []byte) ([]byte, error) {
+	return s.Sign(data)
+}
+
+// Header returns an HS256 header.
+func (s HS256Signer) Header() Header {
+	return Header{Algorithm: "HS256", Typ: "JWT", KeyID: s.KeyID}
+}
+
+// ContextKey is a crypto.Signer, such as a KMS or HSM client, whose signing
+// can be cancelled. EncodeWithKeyContext hands it the JWS signing input,
+// which it hashes as the alg for its public key requires.
+type ContextKey interface {
+	crypto.Signer
+	SignContext(ctx context.Context, data []byte) ([]byte, error)
+}
+
+// opaqueSigner is a KeySigner for a crypto.Signer whose private key isn't in
+// memory, signing with DefaultRSAPadding for RSA keys.
+type opaqueSigner struct {
+	key crypto.Signer
+}
+
+func (s opaqueSigner) Sign(data []byte) ([]byte, error) {
+	if _, ok := s.key.Public().(ed25519.PublicKey); ok {
+		return s.key.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	hash, digest := DefaultRSAPadding.hash(data)
+	var opts crypto.SignerOpts = hash
+	if pss := DefaultRSAPadding.pssOptions(hash); pss != nil {
+		opts = pss
+	}
+	return s.key.Sign(rand.Reader, digest, opts)
+}
+
+// SignContext hands ctx to the key if it is a ContextKey. Other keys can't
+// be interrupted, so ctx is only checked before signing.
+func (s opaqueSigner) SignContext(ctx context.Context, data []byte) ([]byte, error) {
+	if key, ok := s.key.(ContextKey); ok {
+		return key.SignContext(ctx, data)
+	}
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(data)
+}
+
+func (s opaqueSigner) Header() Header {
+	header, _ := HeaderForKey(s.key.Public())
+	return header
+}
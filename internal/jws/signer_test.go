@@ -0,0 +1,168 @@
+package jws
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+// mockKMS stands in for a remote signer: it never hands out its key and
+// records every signing input it is asked to sign.
+type mockKMS struct {
+	key    ed25519.PrivateKey
+	kid    string
+	signed [][]byte
+}
+
+func (m *mockKMS) Sign(data []byte) ([]byte, error) {
+	m.signed = append(m.signed, append([]byte(nil), data...))
+	return ed25519.Sign(m.key, data), nil
+}
+
+func (m *mockKMS) SignContext(_ context.Context, data []byte) ([]byte, error) {
+	return m.Sign(data)
+}
+
+func (m *mockKMS) Header() Header {
+	return Header{Algorithm: "EdDSA", Typ: "JWT", KeyID: m.kid}
+}
+
+func TestKeySigner(t *testing.T) {
+	t.Run("Test mock KMS signer", func(t *testing.T) {
+		pub, priv := testutil.DeterministicEd25519(10)
+		kms := &mockKMS{key: priv, kid: "kms-1"}
+		claims := &ClaimSet{Sub: "device"}
+
+		token, err := EncodeWithKeySigner(&Header{Typ: "JWT"}, claims, kms)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		if len(kms.signed) != 1 {
+			t.Fatalf("expected 1 signing request got %d", len(kms.signed))
+		}
+		want, _ := SigningInput(&Header{Algorithm: "EdDSA", Typ: "JWT", KeyID: "kms-1"}, claims)
+		if string(kms.signed[0]) != want {
+			t.Errorf("expected signing input %s got %s", want, kms.signed[0])
+		}
+		err = VerifyEd25519(token, pub)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+		h, _ := DecodeHeader(token)
+		if h.KeyID != "kms-1" {
+			t.Errorf("expected kid to be kms-1 got %s", h.KeyID)
+		}
+	})
+
+	t.Run("Test caller's kid wins", func(t *testing.T) {
+		_, priv := testutil.DeterministicEd25519(11)
+		token, _ := EncodeWithKeySigner(&Header{Typ: "JWT", KeyID: "mine"}, &ClaimSet{}, &mockKMS{key: priv, kid: "kms-1"})
+		h, _ := DecodeHeader(token)
+		if h.KeyID != "mine" {
+			t.Errorf("expected kid to be mine got %s", h.KeyID)
+		}
+	})
+
+	t.Run("Test in-memory signers", func(t *testing.T) {
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		edPub, edKey := testutil.DeterministicEd25519(12)
+		tests := []struct {
+			name   string
+			signer KeySigner
+			verify func(token string) error
+		}{
+			{"Test RS256", RSASigner{Key: rsaKey}, func(token string) error { return VerifyRSA(token, &rsaKey.PublicKey, PaddingPKCS1v15) }},
+			{"Test PS256", RSASigner{Key: rsaKey, Padding: PaddingPSS}, func(token string) error { return VerifyRSA(token, &rsaKey.PublicKey, PaddingPSS) }},
+			{"Test EdDSA", Ed25519Signer{Key: edKey}, func(token string) error { return VerifyEd25519(token, edPub) }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				token, err := EncodeWithKeySigner(&Header{Typ: "JWT"}, &ClaimSet{Sub: "device"}, tt.signer)
+				if err != nil {
+					t.Fatalf("expected error to be nil got %v", err)
+				}
+				err = tt.verify(token)
+				if err != nil {
+					t.Errorf("expected error to be nil got %v", err)
+				}
+			})
+		}
+	})
+}
+
+// hsmKey stands in for an HSM key: a crypto.Signer that never exposes its
+// private key.
+type hsmKey struct {
+	key crypto.Signer
+}
+
+func (k hsmKey) Public() crypto.PublicKey { return k.key.Public() }
+
+func (k hsmKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.key.Sign(rand, digest, opts)
+}
+
+// stuckKey is a ContextKey whose signing never finishes on its own.
+type stuckKey struct {
+	hsmKey
+}
+
+func (k stuckKey) SignContext(ctx context.Context, data []byte) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestEncodeWithKeyContext(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey := testutil.DeterministicEd25519(13)
+	header := Header{Typ: "JWT"}
+
+	t.Run("Test opaque keys", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			key    crypto.Signer
+			verify func(token string) error
+		}{
+			{"Test RSA", hsmKey{key: rsaKey}, func(token string) error { return VerifyRSA(token, &rsaKey.PublicKey, DefaultRSAPadding) }},
+			{"Test Ed25519", hsmKey{key: edKey}, func(token string) error { return VerifyEd25519(token, edPub) }},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				token, err := EncodeWithKeyContext(context.Background(), &header, &ClaimSet{Sub: "device"}, tt.key)
+				if err != nil {
+					t.Fatalf("expected error to be nil got %v", err)
+				}
+				err = tt.verify(token)
+				if err != nil {
+					t.Errorf("expected error to be nil got %v", err)
+				}
+			})
+		}
+	})
+
+	t.Run("Test cancelled context key", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := EncodeWithKeyContext(ctx, &header, &ClaimSet{Sub: "device"}, stuckKey{hsmKey{key: edKey}})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected error to be %v got %v", context.DeadlineExceeded, err)
+		}
+	})
+
+	t.Run("Test in-memory key ignores the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := EncodeWithKeyContext(ctx, &header, &ClaimSet{Sub: "device"}, rsaKey)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
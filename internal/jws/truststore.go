@@ -0,0 +1,227 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// KeySourceTrustStore means the key was resolved from a local trust store.
+const KeySourceTrustStore KeySource = "trust-store"
+
+// ErrUnknownKeyID is returned when a token names a kid the resolver doesn't know.
+var ErrUnknownKeyID = errors.New("jws: unknown key id")
+
+// KeyResolver looks up the verification key for a key ID: an *rsa.PublicKey
+// for RS256, PS256 and PS384, an ed25519.PublicKey for EdDSA or a []byte
+// secret for HS256.
+type KeyResolver interface {
+	ResolveKey(kid string) (crypto.PublicKey, error)
+}
+
+// VerifyWithResolver verifies token with the key named by its kid header,
+// as resolved by r, dispatching on its alg as VerifyAny does. Every call
+// emits a VerificationContext.
+func VerifyWithResolver(token string, r KeyResolver) error {
+	vc := &VerificationContext{KeySource: KeySourceTrustStore}
+	vc.Err = verifyWithResolver(token, r, vc)
+	LogVerification(vc)
+	return vc.Err
+}
+
+func verifyWithResolver(token string, r KeyResolver, vc *VerificationContext) error {
+	header, err := DecodeHeader(token)
+	if err != nil {
+		return err
+	}
+	vc.Kid = header.KeyID
+	vc.Alg = header.Algorithm
+	if claims, err := Decode(token); err == nil {
+		vc.Iss = claims.Iss
+		vc.Sub = claims.Sub
+		vc.Exp = claims.Exp
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	if header.KeyID == "" {
+		return errors.New("jws: token has no kid")
+	}
+
+	key, err := r.ResolveKey(header.KeyID)
+	if err != nil {
+		return err
+	}
+	return verifyWithAlgorithm(token, header, key)
+}
+
+// TrustStore is a KeyResolver backed by a fixed set of keys, for verifying
+// tokens fully offline. Keys are read from PEM files of RSA or Ed25519 public
+// keys (the kid is the file name without its extension) and JWK files with a
+// .json extension: RSA, OKP Ed25519, or oct for an HS256 secret (the kid is
+// the JWK kid, or the file name when absent). Two files naming the same kid
+// fail the load. `sign verify -trust-store` verifies tokens against one.
+type TrustStore struct {
+	fsys fs.FS
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+// LoadTrustStore loads the keys found in dir.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	return NewTrustStore(os.DirFS(dir))
+}
+
+// NewTrustStore loads the keys found at the root of fsys, which may be an
+// embedded bundle.
+func NewTrustStore(fsys fs.FS) (*TrustStore, error) {
+	ts := &TrustStore{fsys: fsys}
+	err := ts.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// Reload re-reads the keys. On error the previously loaded keys are kept.
+func (ts *TrustStore) Reload() error {
+	keys, err := readTrustStore(ts.fsys)
+	if err != nil {
+		return err
+	}
+	ts.mu.Lock()
+	ts.keys = keys
+	ts.mu.Unlock()
+	return nil
+}
+
+// ReloadOnSignal reloads the store each time a value is received on c, for
+// example after signal.Notify(c, syscall.SIGHUP). It returns when c is closed.
+func (ts *TrustStore) ReloadOnSignal(c <-chan os.Signal) {
+	for range c {
+		err := ts.Reload()
+		if err != nil {
+			logger().Error("jws: trust store reload failed", "error", err)
+		}
+	}
+}
+
+// ResolveKey implements KeyResolver.
+func (ts *TrustStore) ResolveKey(kid string) (crypto.PublicKey, error) {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	key, ok := ts.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKeyID, kid)
+	}
+	return key, nil
+}
+
+func readTrustStore(fsys fs.FS) (map[string]crypto.PublicKey, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	files := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		ext := path.Ext(name)
+		if ext != ".pem" && ext != ".json" {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(name, ext)
+		var key crypto.PublicKey
+		if ext == ".pem" {
+			key, err = parsePublicKeyPEM(b)
+		} else {
+			kid, key, err = parseJWK(b, kid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jws: trust store %s: %w", name, err)
+		}
+		if other, ok := files[kid]; ok {
+			return nil, fmt.Errorf("jws: trust store %s: kid %q is already used by %s", name, kid, other)
+		}
+		keys[kid] = key
+		files[kid] = name
+	}
+	return keys, nil
+}
+
+func parsePublicKeyPEM(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch pub.(type) {
+		case *rsa.PublicKey, ed25519.PublicKey:
+			return pub, nil
+		}
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// trustedJWK is a JWK that may also be an oct key, whose k member is an
+// HS256 secret. Only a trust store holds those; a published JWKS never does.
+type trustedJWK struct {
+	JWK
+	K string `json:"k,omitempty"`
+}
+
+func parseJWK(b []byte, kid string) (string, crypto.PublicKey, error) {
+	jwk := trustedJWK{}
+	err := json.Unmarshal(b, &jwk)
+	if err != nil {
+		return "", nil, err
+	}
+	if jwk.Kid != "" {
+		kid = jwk.Kid
+	}
+	if jwk.Kty == "oct" {
+		secret, err := base64.RawURLEncoding.DecodeString(jwk.K)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(secret) < MinHS256SecretSize {
+			return "", nil, ErrShortHS256Secret
+		}
+		return kid, secret, nil
+	}
+	key, err := jwk.PublicKey()
+	if err != nil {
+		return "", nil, err
+	}
+	return kid, key, nil
+}
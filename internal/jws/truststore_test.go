@@ -0,0 +1,198 @@
+package jws
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/martinsaporiti/ed25519-poc/internal/testutil"
+)
+
+func writePublicKeyPEM(t *testing.T, path string, key *rsa.PublicKey) {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+}
+
+func encodeWithKid(t *testing.T, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+	token, err := Encode(&Header{Algorithm: "RS256", Typ: "JWT", KeyID: kid}, &ClaimSet{Iss: "server"}, key)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	return token
+}
+
+func TestTrustStore(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	t.Run("Test verify, reload without key, verify fails", func(t *testing.T) {
+		dir := t.TempDir()
+		writePublicKeyPEM(t, filepath.Join(dir, "k1.pem"), &key.PublicKey)
+		writePublicKeyPEM(t, filepath.Join(dir, "k2.pem"), &other.PublicKey)
+
+		ts, err := LoadTrustStore(dir)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		token := encodeWithKid(t, "k1", key)
+		err = VerifyWithResolver(token, ts)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+
+		err = os.Remove(filepath.Join(dir, "k1.pem"))
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = ts.Reload()
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = VerifyWithResolver(token, ts)
+		if !errors.Is(err, ErrUnknownKeyID) {
+			t.Errorf("expected error to be ErrUnknownKeyID got %v", err)
+		}
+	})
+
+	t.Run("Test token signed by another key is rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		writePublicKeyPEM(t, filepath.Join(dir, "k1.pem"), &key.PublicKey)
+		ts, err := LoadTrustStore(dir)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = VerifyWithResolver(encodeWithKid(t, "k1", other), ts)
+		if err == nil {
+			t.Errorf("expected error not to be nil")
+		}
+	})
+
+	t.Run("Test embedded bundle with JWK", func(t *testing.T) {
+		jwk, _ := json.Marshal(map[string]string{
+			"kty": "RSA",
+			"kid": "bundled",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+		ts, err := NewTrustStore(fstest.MapFS{"key.json": {Data: jwk}})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		err = VerifyWithResolver(encodeWithKid(t, "bundled", key), ts)
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+
+	t.Run("Test every algorithm verifies", func(t *testing.T) {
+		edPub, edPriv := testutil.DeterministicEd25519(42)
+		edDER, _ := x509.MarshalPKIXPublicKey(edPub)
+		okp, _ := json.Marshal(map[string]string{
+			"kty": "OKP",
+			"crv": "Ed25519",
+			"kid": "ed-jwk",
+			"x":   base64.RawURLEncoding.EncodeToString(edPub),
+		})
+		secret := bytes.Repeat([]byte("s"), MinHS256SecretSize)
+		oct, _ := json.Marshal(map[string]string{"kty": "oct", "k": base64.RawURLEncoding.EncodeToString(secret)})
+		rsaDER, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		ts, err := NewTrustStore(fstest.MapFS{
+			"rsa.pem":    {Data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaDER})},
+			"ed-pem.pem": {Data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: edDER})},
+			"okp.json":   {Data: okp},
+			"hs.json":    {Data: oct},
+		})
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+
+		claims := &ClaimSet{Iss: "server"}
+		tokens := map[string]func() (string, error){
+			"RS256": func() (string, error) {
+				return EncodeRSA(&Header{Typ: "JWT", KeyID: "rsa"}, claims, key, PaddingPKCS1v15)
+			},
+			"PS256": func() (string, error) { return EncodeRSA(&Header{Typ: "JWT", KeyID: "rsa"}, claims, key, PaddingPSS) },
+			"PS384": func() (string, error) {
+				return EncodeRSA(&Header{Typ: "JWT", KeyID: "rsa"}, claims, key, PaddingPSS384)
+			},
+			"EdDSA PEM": func() (string, error) { return EncodeEd25519(&Header{Typ: "JWT", KeyID: "ed-pem"}, claims, edPriv) },
+			"EdDSA JWK": func() (string, error) { return EncodeEd25519(&Header{Typ: "JWT", KeyID: "ed-jwk"}, claims, edPriv) },
+			"HS256":     func() (string, error) { return EncodeHS256(&Header{Typ: "JWT", KeyID: "hs"}, claims, secret) },
+		}
+		for name, encode := range tokens {
+			token, err := encode()
+			if err != nil {
+				t.Fatalf("expected error to be nil got %v", err)
+			}
+			if err := VerifyWithResolver(token, ts); err != nil {
+				t.Errorf("expected %s token to verify got %v", name, err)
+			}
+		}
+
+		// An RSA key is never used as an HMAC secret.
+		forged, _ := EncodeHS256(&Header{Typ: "JWT", KeyID: "rsa"}, claims, secret)
+		if VerifyWithResolver(forged, ts) == nil {
+			t.Errorf("expected an HS256 token naming an RSA kid to be rejected")
+		}
+	})
+
+	t.Run("Test duplicate kid fails the load", func(t *testing.T) {
+		jwk, _ := json.Marshal(map[string]string{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(other.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(other.E)).Bytes()),
+		})
+		der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		_, err := NewTrustStore(fstest.MapFS{
+			"k1.pem":     {Data: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})},
+			"other.json": {Data: jwk},
+		})
+		if err == nil || !strings.Contains(err.Error(), `kid "k1"`) {
+			t.Errorf("expected a duplicate kid error got %v", err)
+		}
+	})
+
+	t.Run("Test reload on signal", func(t *testing.T) {
+		dir := t.TempDir()
+		ts, err := LoadTrustStore(dir)
+		if err != nil {
+			t.Fatalf("expected error to be nil got %v", err)
+		}
+		writePublicKeyPEM(t, filepath.Join(dir, "k1.pem"), &key.PublicKey)
+
+		c := make(chan os.Signal)
+		done := make(chan struct{})
+		go func() {
+			ts.ReloadOnSignal(c)
+			close(done)
+		}()
+		c <- os.Interrupt
+		close(c)
+		<-done
+
+		_, err = ts.ResolveKey("k1")
+		if err != nil {
+			t.Errorf("expected error to be nil got %v", err)
+		}
+	})
+}
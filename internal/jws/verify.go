@@ -0,0 +1,501 @@
+package jws
+
+// This file is the verification surface of the package: parsing tokens and
+// checking signatures with public keys. It must not import crypto/rand or
+// anything that signs or generates keys, so relying parties only need what
+// is here; TestVerifyImports enforces that. HS256 is the exception: checking
+// a MAC means computing it, so VerifyHS256 uses hs256 from signer.go.
+//
+// Each exported Verify function emits one VerificationContext; the
+// unexported helpers they share log nothing, so a token is logged once.
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrDuplicateClaim is returned when a claim set contains the same key more
+// than once. encoding/json silently keeps the last value while other parsers
+// may keep the first, so such tokens are rejected rather than guessed at.
+var ErrDuplicateClaim = errors.New("jws: duplicate claim")
+
+// foldKey folds a JSON object key the way encoding/json does when it matches
+// a key to a struct field: ASCII letters by case and every other rune to the
+// smallest of its Unicode simple fold set, so "ſub" folds like "sub".
+func foldKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if r < utf8.RuneSelf {
+			if 'a' <= r && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			b.WriteRune(r)
+			continue
+		}
+		for {
+			next := unicode.SimpleFold(r)
+			if next <= r {
+				r = next
+				break
+			}
+			r = next
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// checkDuplicateClaims scans the top level of a JSON claim set and returns
+// ErrDuplicateClaim if any key repeats. Keys are compared with foldKey
+// because encoding/json matches struct fields that way.
+func checkDuplicateClaims(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	t, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("%w: claim set: %v", ErrInvalidToken, err)
+	}
+	if d, ok := t.(json.Delim); !ok || d != '{' {
+		return fmt.Errorf("%w: claim set is not a JSON object", ErrInvalidToken)
+	}
+	seen := make(map[string]bool)
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("%w: claim set: %v", ErrInvalidToken, err)
+		}
+		key, ok := t.(string)
+		if !ok {
+			return fmt.Errorf("%w: invalid claim set", ErrInvalidToken)
+		}
+		folded := foldKey(key)
+		if seen[folded] {
+			return fmt.Errorf("%w: %q", ErrDuplicateClaim, key)
+		}
+		seen[folded] = true
+
+		var value json.RawMessage
+		err = dec.Decode(&value)
+		if err != nil {
+			return fmt.Errorf("%w: claim set: %v", ErrInvalidToken, err)
+		}
+	}
+	return nil
+}
+
+// ParseOptions controls how strictly token segments are parsed.
+type ParseOptions struct {
+	// LegacyEncoding tolerates segments encoded with standard base64 (with or
+	// without padding) when they don't parse as base64url, for systems that
+	// mix the two encodings. Normal tokens should be parsed strictly.
+	LegacyEncoding bool
+}
+
+// maxSegmentLen caps the encoded length of a token segment. Decoding
+// allocates in proportion to the input, so without it a client could make
+// every parse of its token allocate megabytes; real segments are a few
+// hundred bytes, or a few KB with an embedded RSA issuer.
+const maxSegmentLen = 64 << 10
+
+// MaxTokenSize caps the length, in bytes, of a token the package parses or
+// verifies. Longer tokens are rejected with ErrTokenTooLarge before any
+// decoding, so an oversized token costs no allocation. Server tokens are
+// well under 2KB, even with an RSA key embedded in iss.
+var MaxTokenSize = 8 << 10
+
+// ErrTokenTooLarge is returned for a token longer than MaxTokenSize.
+var ErrTokenTooLarge = errors.New("jws: token is too large")
+
+// tokenSegments is how many segments a JWS has. splitToken stops there, so
+// a token of many dots doesn't allocate a slice per dot.
+const tokenSegments = 3
+
+// splitToken checks token against MaxTokenSize and splits it into at most
+// tokenSegments segments plus whatever follows them, unsplit.
+func splitToken(token string) ([]string, error) {
+	if len(token) > MaxTokenSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrTokenTooLarge, len(token), MaxTokenSize)
+	}
+	return strings.SplitN(token, ".", tokenSegments+1), nil
+}
+
+// decodeSegment decodes a base64url token segment, falling back to the other
+// base64 alphabets and padding only when opts.LegacyEncoding is set.
+func decodeSegment(seg string, opts ParseOptions) ([]byte, error) {
+	if len(seg) > maxSegmentLen {
+		return nil, fmt.Errorf("%w: segment of %d bytes exceeds %d", ErrInvalidToken, len(seg), maxSegmentLen)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(seg)
+	if err == nil {
+		return decoded, nil
+	}
+	if opts.LegacyEncoding {
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding} {
+			decoded, legacyErr := enc.DecodeString(seg)
+			if legacyErr == nil {
+				return decoded, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+}
+
+var (
+	// ErrInvalidToken is returned for a token that can't be parsed: wrong
+	// number of segments, bad base64 or JSON, or missing required claims.
+	ErrInvalidToken = errors.New("jws: invalid token")
+	// ErrBadSignature is returned for a well-formed token whose signature
+	// doesn't verify with the key it was checked against.
+	ErrBadSignature = errors.New("jws: signature does not verify")
+	// ErrUnsupportedAlgorithm is returned for a token whose alg is empty,
+	// "none" or otherwise not one this package verifies.
+	ErrUnsupportedAlgorithm = errors.New("jws: unsupported algorithm")
+)
+
+// DecodeHeader decodes the header segment of a token without verifying it.
+func DecodeHeader(token string) (*Header, error) {
+	return decodeHeaderWithOptions(token, ParseOptions{})
+}
+
+func decodeHeaderWithOptions(token string, opts ParseOptions) (*Header, error) {
+	s, err := splitToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) < 2 {
+		return nil, fmt.Errorf("%w: no header and payload", ErrInvalidToken)
+	}
+	decoded, err := decodeSegment(s[0], opts)
+	if err != nil {
+		return nil, err
+	}
+	h := &Header{}
+	err = json.Unmarshal(decoded, h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	return h, nil
+}
+
+// checkAlgorithm rejects unsigned tokens. Every verification path calls it
+// before any crypto so a token can't downgrade itself to alg "none".
+func checkAlgorithm(h *Header) error {
+	if h.Algorithm == "" || strings.EqualFold(h.Algorithm, "none") {
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Algorithm)
+	}
+	return nil
+}
+
+// Decode decodes a claim set from a JWS payload.
+// Claim sets with duplicate keys are rejected with ErrDuplicateClaim.
+func Decode(payload string) (*ClaimSet, error) {
+	return DecodeWithOptions(payload, ParseOptions{})
+}
+
+// DecodeWithOptions is like Decode but parses the payload according to opts.
+func DecodeWithOptions(payload string, opts ParseOptions) (*ClaimSet, error) {
+	// decode returned id token to get expiry
+	s, err := splitToken(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) < 2 {
+		return nil, fmt.Errorf("%w: no header and payload", ErrInvalidToken)
+	}
+	decoded, err := decodeSegment(s[1], opts)
+	if err != nil {
+		return nil, err
+	}
+	err = checkDuplicateClaims(decoded)
+	if err != nil {
+		return nil, err
+	}
+	c := &ClaimSet{}
+	err = json.NewDecoder(bytes.NewBuffer(decoded)).Decode(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: claim set: %v", ErrInvalidToken, err)
+	}
+	return c, nil
+}
+
+// Verify tests whether the provided JWT token's signature was produced by the private key
+// associated with the supplied public key.
+func Verify(token string, key *rsa.PublicKey) error {
+	return VerifyWithOptions(token, key, ParseOptions{})
+}
+
+// VerifyWithOptions is like Verify but parses the token segments according to
+// opts. The signature always covers the segments exactly as transmitted.
+func VerifyWithOptions(token string, key *rsa.PublicKey, opts ParseOptions) error {
+	return logOutcome(token, KeySourceProvided, verifyRSAToken(token, key, PaddingPKCS1v15, opts))
+}
+
+// VerifyRSA tests whether token was signed by the private key associated with
+// key using the given padding.
+func VerifyRSA(token string, key *rsa.PublicKey, padding RSAPadding) error {
+	return logOutcome(token, KeySourceProvided, verifyRSAWithAlgorithm(token, key, padding))
+}
+
+// verifyRSAWithAlgorithm is VerifyRSA without the log entry.
+func verifyRSAWithAlgorithm(token string, key *rsa.PublicKey, padding RSAPadding) error {
+	header, err := DecodeHeader(token)
+	if err != nil {
+		return err
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	if header.Algorithm != padding.Algorithm() {
+		return fmt.Errorf("%w: token alg %q does not match %q", ErrUnsupportedAlgorithm, header.Algorithm, padding.Algorithm())
+	}
+	return verifyRSAToken(token, key, padding, ParseOptions{})
+}
+
+func verifyRSAToken(token string, key *rsa.PublicKey, padding RSAPadding, opts ParseOptions) error {
+	parts, err := splitToken(token)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: token must have 3 parts", ErrInvalidToken)
+	}
+	header, err := decodeHeaderWithOptions(token, opts)
+	if err != nil {
+		return err
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	for _, seg := range parts[:2] {
+		_, err := decodeSegment(seg, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	signedContent := parts[0] + "." + parts[1]
+	signatureString, err := decodeSegment(parts[2], opts)
+	if err != nil {
+		return err
+	}
+	return verifyRSA([]byte(signedContent), signatureString, key, padding)
+}
+
+func verifyRSA(signedContent, sig []byte, key *rsa.PublicKey, padding RSAPadding) error {
+	hash, digest := padding.hash(signedContent)
+	var err error
+	if opts := padding.pssOptions(hash); opts != nil {
+		err = rsa.VerifyPSS(key, hash, digest, sig, opts)
+	} else {
+		err = rsa.VerifyPKCS1v15(key, hash, digest, sig)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return nil
+}
+
+// VerifyEd25519 tests whether token is an EdDSA JWS signed by the private key
+// associated with the supplied Ed25519 public key.
+func VerifyEd25519(token string, key ed25519.PublicKey) error {
+	return logOutcome(token, KeySourceProvided, verifyEd25519(token, key))
+}
+
+// verifyEd25519 is VerifyEd25519 without the log entry.
+func verifyEd25519(token string, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return errors.New("jws: invalid Ed25519 public key")
+	}
+	header, err := DecodeHeader(token)
+	if err != nil {
+		return err
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	if header.Algorithm != "EdDSA" {
+		return fmt.Errorf("%w: token alg %q does not match %q", ErrUnsupportedAlgorithm, header.Algorithm, "EdDSA")
+	}
+
+	parts, err := splitToken(token)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: token must have 3 parts", ErrInvalidToken)
+	}
+	_, err = decodeSegment(parts[1], ParseOptions{})
+	if err != nil {
+		return err
+	}
+	sig, err := decodeSegment(parts[2], ParseOptions{})
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, []byte(parts[0]+"."+parts[1]), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// MinHS256SecretSize is the shortest HS256 secret accepted: RFC 7518 asks
+// for a key at least as long as the hash output.
+const MinHS256SecretSize = sha256.Size
+
+// ErrShortHS256Secret is returned when an HS256 secret is under
+// MinHS256SecretSize bytes.
+var ErrShortHS256Secret = errors.New("jws: HS256 secret must be at least 32 bytes")
+
+// VerifyHS256 tests whether token is an HS256 JWS MACed with secret. The MAC
+// is compared in constant time.
+func VerifyHS256(token string, secret []byte) error {
+	return logOutcome(token, KeySourceProvided, verifyHS256(token, secret))
+}
+
+// verifyHS256 is VerifyHS256 without the log entry.
+func verifyHS256(token string, secret []byte) error {
+	if len(secret) < MinHS256SecretSize {
+		return ErrShortHS256Secret
+	}
+	header, err := DecodeHeader(token)
+	if err != nil {
+		return err
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	if header.Algorithm != "HS256" {
+		return fmt.Errorf("%w: token alg %q does not match %q", ErrUnsupportedAlgorithm, header.Algorithm, "HS256")
+	}
+
+	parts, err := splitToken(token)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: token must have 3 parts", ErrInvalidToken)
+	}
+	_, err = decodeSegment(parts[1], ParseOptions{})
+	if err != nil {
+		return err
+	}
+	sig, err := decodeSegment(parts[2], ParseOptions{})
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, hs256(secret, []byte(parts[0]+"."+parts[1]))) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyWithKey verifies token with pub, which must be an RSA or Ed25519
+// public key, dispatching to VerifyRSA or VerifyEd25519.
+func VerifyWithKey(token string, pub crypto.PublicKey) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return VerifyRSA(token, k, DefaultRSAPadding)
+	case ed25519.PublicKey:
+		return VerifyEd25519(token, k)
+	default:
+		return errors.New("jws: unsupported public key type")
+	}
+}
+
+// VerifyDetached checks a signature SignDetached made over data with the
+// private key of pub.
+func VerifyDetached(pub crypto.PublicKey, data, sig []byte) error {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return verifyRSA(data, sig, k, DefaultRSAPadding)
+	case ed25519.PublicKey:
+		if len(k) != ed25519.PublicKeySize || !ed25519.Verify(k, data, sig) {
+			return ErrBadSignature
+		}
+		return nil
+	default:
+		return errors.New("jws: unsupported public key type")
+	}
+}
+
+// VerifyAny verifies token with the key in keys named by the kid in its
+// header, dispatching on the header's alg. RS256, PS256 and PS384 need an RSA key,
+// EdDSA an Ed25519 key and HS256 a []byte secret; a public key is never used
+// as an HMAC secret. A kid not in keys yields ErrUnknownKeyID without
+// trying the others; a token with no kid is tried against every key. The
+// VerificationContext it emits has KeySourceJWKS.
+func VerifyAny(token string, keys map[string]crypto.PublicKey) error {
+	return logOutcome(token, KeySourceJWKS, verifyAny(token, keys))
+}
+
+// verifyAny is VerifyAny without the log entry.
+func verifyAny(token string, keys map[string]crypto.PublicKey) error {
+	header, err := DecodeHeader(token)
+	if err != nil {
+		return err
+	}
+	err = checkAlgorithm(header)
+	if err != nil {
+		return err
+	}
+	if header.KeyID != "" {
+		pub, ok := keys[header.KeyID]
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownKeyID, header.KeyID)
+		}
+		return verifyWithAlgorithm(token, header, pub)
+	}
+
+	err = fmt.Errorf("%w: token has no kid and no key verifies it", ErrBadSignature)
+	for _, pub := range keys {
+		keyErr := verifyWithAlgorithm(token, header, pub)
+		if keyErr == nil {
+			return nil
+		}
+		if errors.Is(keyErr, ErrUnsupportedAlgorithm) {
+			return keyErr
+		}
+	}
+	return err
+}
+
+// verifyWithAlgorithm verifies token with pub using the alg in header.
+func verifyWithAlgorithm(token string, header *Header, pub crypto.PublicKey) error {
+	if padding, ok := rsaPaddingFor(header.Algorithm); ok {
+		k, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("jws: alg %s needs an RSA key, kid %q is %T", header.Algorithm, header.KeyID, pub)
+		}
+		return verifyRSAWithAlgorithm(token, k, padding)
+	}
+	switch header.Algorithm {
+	case "EdDSA":
+		k, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("jws: alg EdDSA needs an Ed25519 key, kid %q is %T", header.KeyID, pub)
+		}
+		return verifyEd25519(token, k)
+	case "HS256":
+		k, ok := pub.([]byte)
+		if !ok {
+			return fmt.Errorf("jws: alg HS256 needs a []byte secret, kid %q is %T", header.KeyID, pub)
+		}
+		return verifyHS256(token, k)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Algorithm)
+	}
+}
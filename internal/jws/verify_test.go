@@ -0,0 +1,56 @@
+package jws
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+)
+
+// TestVerifyImports keeps verify.go free of signing code, so the functions a
+// relying party needs never depend on key generation or a signer.
+func TestVerifyImports(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "verify.go", nil, 0)
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+
+	t.Run("Test no signing imports", func(t *testing.T) {
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if path == "crypto/rand" || path == "crypto/x509" || path == "encoding/pem" || path == "os" {
+				t.Errorf("expected verify.go not to import %s", path)
+			}
+		}
+	})
+
+	t.Run("Test verification functions live in verify.go", func(t *testing.T) {
+		declared := map[string]bool{}
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+				declared[fn.Name.Name] = true
+			}
+		}
+		for _, name := range []string{"Decode", "DecodeHeader", "Verify", "VerifyRSA", "VerifyEd25519", "VerifyHS256", "VerifyAny", "VerifyWithKey"} {
+			if !declared[name] {
+				t.Errorf("expected %s to be declared in verify.go", name)
+			}
+		}
+	})
+
+	t.Run("Test no references to signing code", func(t *testing.T) {
+		signing := map[string]bool{
+			"Signer": true, "KeySigner": true, "RSASigner": true, "Ed25519Signer": true, "HS256Signer": true,
+			"EncodeWithSigner": true, "EncodeWithKeySigner": true, "EncodeWithKey": true,
+			"Encode": true, "EncodeRSA": true, "EncodeEd25519": true, "EncodeHS256": true,
+			"Generate": true, "GenerateWithClaims": true, "LoadSigningKey": true,
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && signing[id.Name] {
+				t.Errorf("expected verify.go not to use %s", id.Name)
+			}
+			return true
+		})
+	})
+}
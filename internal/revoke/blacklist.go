@@ -0,0 +1,111 @@
+package revoke
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBlacklist remembers revoked token IDs (jti), and subjects whose
+// tokens were all revoked, until the tokens expire, after which they would
+// be rejected anyway and are forgotten.
+type TokenBlacklist struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	revoked  map[string]time.Time // jti -> token expiry
+	subjects map[string]subjectRevocation
+}
+
+// subjectRevocation revokes a subject's tokens with an iat under before; it is
+// kept until the last of them expires.
+type subjectRevocation struct {
+	before int64
+	until  time.Time
+}
+
+func NewTokenBlacklist() *TokenBlacklist {
+	return &TokenBlacklist{
+		now:      time.Now,
+		revoked:  make(map[string]time.Time),
+		subjects: make(map[string]subjectRevocation),
+	}
+}
+
+// Revoke blacklists jti until exp, the Unix time its token expires.
+func (b *TokenBlacklist) Revoke(jti string, exp int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep()
+	b.revoked[jti] = time.Unix(exp, 0)
+}
+
+// IsRevoked reports whether jti has been revoked and its token hasn't
+// expired yet.
+func (b *TokenBlacklist) IsRevoked(jti string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	exp, ok := b.revoked[jti]
+	if !ok {
+		return false
+	}
+	if !b.now().Before(exp) {
+		delete(b.revoked, jti)
+		return false
+	}
+	return true
+}
+
+// RevokeSubject revokes every token for sub issued before the current
+// second, remembering it until exp, the Unix time the last of those tokens
+// expires. iat has whole seconds, so a token issued earlier in this second
+// can't be told from one issued just after and is spared along with it.
+func (b *TokenBlacklist) RevokeSubject(sub string, exp int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep()
+	r := b.subjects[sub]
+	r.before = b.now().Unix()
+	if until := time.Unix(exp, 0); until.After(r.until) {
+		r.until = until
+	}
+	b.subjects[sub] = r
+}
+
+// IsSubjectRevoked reports whether the token for sub issued at iat was
+// revoked by RevokeSubject.
+func (b *TokenBlacklist) IsSubjectRevoked(sub string, iat int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r, ok := b.subjects[sub]
+	if !ok {
+		return false
+	}
+	if !b.now().Before(r.until) {
+		delete(b.subjects, sub)
+		return false
+	}
+	return iat < r.before
+}
+
+// Len returns the number of revoked IDs held, including expired ones that
+// haven't been swept yet.
+func (b *TokenBlacklist) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.revoked)
+}
+
+// sweep forgets every expired entry. b.mu must be held.
+func (b *TokenBlacklist) sweep() {
+	now := b.now()
+	for jti, exp := range b.revoked {
+		if !now.Before(exp) {
+			delete(b.revoked, jti)
+		}
+	}
+	for sub, r := range b.subjects {
+		if !now.Before(r.until) {
+			delete(b.subjects, sub)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package revoke
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBlacklist(t *testing.T) {
+	t.Run("Test revoked jti is reported", func(t *testing.T) {
+		b := NewTokenBlacklist()
+		b.Revoke("a", time.Now().Add(time.Hour).Unix())
+		if !b.IsRevoked("a") {
+			t.Errorf("expected a to be revoked")
+		}
+		if b.IsRevoked("b") {
+			t.Errorf("expected b not to be revoked")
+		}
+	})
+
+	t.Run("Test entries are forgotten once the token expires", func(t *testing.T) {
+		b := NewTokenBlacklist()
+		now := time.Now()
+		b.now = func() time.Time { return now }
+
+		b.Revoke("a", now.Add(time.Minute).Unix())
+		b.Revoke("b", now.Add(time.Hour).Unix())
+		now = now.Add(2 * time.Minute)
+		if b.IsRevoked("a") {
+			t.Errorf("expected expired a not to be revoked")
+		}
+		b.Revoke("c", now.Add(time.Hour).Unix())
+		if b.Len() != 2 {
+			t.Errorf("expected 2 entries got %d", b.Len())
+		}
+	})
+	t.Run("Test subject revokes the tokens issued before", func(t *testing.T) {
+		b := NewTokenBlacklist()
+		now := time.Now()
+		b.now = func() time.Time { return now }
+
+		b.RevokeSubject("alice", now.Add(time.Hour).Unix())
+		if !b.IsSubjectRevoked("alice", now.Add(-time.Minute).Unix()) {
+			t.Errorf("expected alice's earlier token to be revoked")
+		}
+		if b.IsSubjectRevoked("alice", now.Unix()) {
+			t.Errorf("expected alice's token from this second not to be revoked")
+		}
+		if b.IsSubjectRevoked("bob", now.Unix()) {
+			t.Errorf("expected bob's token not to be revoked")
+		}
+		now = now.Add(time.Minute)
+		if b.IsSubjectRevoked("alice", now.Unix()) {
+			t.Errorf("expected alice's later token not to be revoked")
+		}
+		now = now.Add(time.Hour)
+		if b.IsSubjectRevoked("alice", 0) {
+			t.Errorf("expected the revocation to be forgotten once the tokens expire")
+		}
+	})
+}
@@ -0,0 +1,27 @@
+// Package testutil has helpers shared by the repo's tests.
+package testutil
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// DeterministicEd25519 returns the Ed25519 key pair whose seed is seed
+// repeated, so a test gets the same key on every run. Different seeds give
+// different keys.
+func DeterministicEd25519(seed byte) (ed25519.PublicKey, ed25519.PrivateKey) {
+	s := make([]byte, ed25519.SeedSize)
+	for i := range s {
+		s[i] = seed
+	}
+	priv := ed25519.NewKeyFromSeed(s)
+	return priv.Public().(ed25519.PublicKey), priv
+}
+
+// SignChallenge signs message as clients do in the server's default sign
+// mode, over its SHA-256, and returns the signature in std base64.
+func SignChallenge(priv ed25519.PrivateKey, message string) string {
+	digest := sha256.Sum256([]byte(message))
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, digest[:]))
+}
@@ -0,0 +1,41 @@
+package testutil
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestDeterministicEd25519(t *testing.T) {
+	t.Run("Test same seed gives the same key", func(t *testing.T) {
+		pub1, priv1 := DeterministicEd25519(7)
+		pub2, priv2 := DeterministicEd25519(7)
+		if !pub1.Equal(pub2) || !priv1.Equal(priv2) {
+			t.Errorf("expected the same key pair for the same seed")
+		}
+	})
+
+	t.Run("Test different seeds give different keys", func(t *testing.T) {
+		pub1, _ := DeterministicEd25519(1)
+		pub2, _ := DeterministicEd25519(2)
+		if pub1.Equal(pub2) {
+			t.Errorf("expected different keys for different seeds")
+		}
+	})
+}
+
+func TestSignChallenge(t *testing.T) {
+	pub, priv := DeterministicEd25519(1)
+	sig, err := base64.StdEncoding.DecodeString(SignChallenge(priv, "challenge"))
+	if err != nil {
+		t.Fatalf("expected error to be nil got %v", err)
+	}
+	digest := sha256.Sum256([]byte("challenge"))
+	if !ed25519.Verify(pub, digest[:], sig) {
+		t.Errorf("expected signature over the challenge's SHA-256 to verify")
+	}
+	if SignChallenge(priv, "challenge") != SignChallenge(priv, "challenge") {
+		t.Errorf("expected signatures to be reproducible")
+	}
+}